@@ -20,7 +20,7 @@ if [[ "$1" = "-wasm" ]]; then
     cp "wasm/wasm_exec.js" "build/wasm_exec.js"
     cp "wasm/index.html" "build/index.html"
 else
-    go build -o build/nilang$ext src/main.go
+    go build -o build/nilang$ext ./src
 fi
 
 tar -czvf build/nilang-$platform.tar.gz --directory=build nilang$ext $additional_files
@@ -0,0 +1,21 @@
+# checks that build compiles bots of a directory and skips files of domains they use
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+
+go build -o "$dir/nilang" ./src || exit 1
+mkdir -p "$dir/bots"
+printf 'Domain helpers\n\nFun Double::Int$ x Int:\n    Return x * 2\n' > "$dir/bots/helpers.nil"
+printf 'Using helpers\n\nbot::WriteMemory$ Double$ 2\n' > "$dir/bots/bot.nil"
+
+if ! "$dir/nilang" build "$dir/bots/..." > /dev/null; then
+    echo "ERROR: nilang build failed on a bot using a domain"
+    exit 1
+fi
+if [[ ! -f "$dir/bots/bot.tor" ]]; then
+    echo "ERROR: nilang build hasn't written bot.tor"
+    exit 1
+fi
+if [[ -f "$dir/bots/helpers.tor" ]]; then
+    echo "ERROR: nilang build has compiled the file of a domain as a bot"
+    exit 1
+fi
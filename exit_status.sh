@@ -0,0 +1,30 @@
+# checks that commands exit with 1 if the program doesn't compile and with 0 otherwise, -Werror fails on warnings
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+
+go build -o "$dir/nilang" ./src || exit 1
+printf 'Int x = True\n' > "$dir/error.nil"
+printf 'Int x = 1\n' > "$dir/bot.nil"
+printf 'If 1 > 2:\n    bot::Sleep\n' > "$dir/warning.nil"
+
+expect() {
+    status=$1
+    shift
+    "$dir/nilang" "$@" > /dev/null
+    exited=$?
+    if [[ $exited -ne $status ]]; then
+        echo "ERROR: nilang $* exited with $exited, expected $status"
+        exit 1
+    fi
+}
+
+expect 1 -o "$dir/error.tor" "$dir/error.nil"
+expect 1 -Werror -diagnostics=json -o "$dir/error.tor" "$dir/error.nil"
+expect 1 run "$dir/error.nil"
+expect 1 sim -steps 1 "$dir/error.nil"
+expect 0 -o "$dir/bot.tor" "$dir/bot.nil"
+expect 0 run "$dir/bot.nil"
+expect 0 -o "$dir/warning.tor" "$dir/warning.nil"
+expect 1 -Werror -o "$dir/warning.tor" "$dir/warning.nil"
+expect 0 -Werror -Wno=NL0110 -o "$dir/warning.tor" "$dir/warning.nil"
+expect 1 build -Werror "$dir/warning.nil"
@@ -43,6 +43,7 @@ func (ti *Variable) String() string {
 
 type Program struct {
 	Statements []Statement
+	Comments   []tokens.Token // comments don't affect the program, they are kept for tools like formatter
 }
 
 func (p *Program) TokenLiteral() string {
@@ -102,6 +103,25 @@ func (us *UsingStatement) String() string {
 	return out.String()
 }
 
+// DomainStatement names the domain of a file, e.g. `Domain helpers` in helpers.nil
+type DomainStatement struct {
+	Token tokens.Token
+	Name  Expression
+}
+
+func (ds *DomainStatement) statementNode()       {}
+func (ds *DomainStatement) TokenLiteral() string { return ds.Token.Literal }
+
+func (ds *DomainStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ds.TokenLiteral() + " ")
+	if ds.Name != nil {
+		out.WriteString(ds.Name.String())
+	}
+	return out.String()
+}
+
 type AssignmentStatement struct {
 	Name  *Identifier
 	Value Expression
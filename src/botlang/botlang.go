@@ -0,0 +1,194 @@
+package botlang
+
+import "fmt"
+
+type Opcode = string
+type Register = string
+
+const (
+	// COMPARE [a] [b] ?a>=b ?a==b ?a<b ?a!=b
+	COMPARE            = "cmp"
+	COMPARE_WITH_VALUE = "cmpv"
+
+	//JUMP [label]
+	JUMP                 = "jmp"
+	JUMP_IF_EQUAL        = "jme"
+	JUMP_IF_NOT_EQUAL    = "jne"
+	JUMP_IF_LESS_THAN    = "jml"
+	JUMP_IF_GREATER_THAN = "jmg"
+
+	JUMP_IF_LESS_EQUAL_THAN    = "jle"
+	JUMP_IF_GREATER_EQUAL_THAN = "jge"
+
+	JUMP_IF_EMPTY   = "jmf"
+	JUMP_IF_FRIEND  = "jmc"
+	JUMP_IF_SIBLING = "jmb"
+
+	// LOAD_TO_REG_FROM_REG [target] [source]
+	LOAD_TO_REG_FROM_REG = "ld"
+	LOAD_TO_REG_FROM_VAL = "ldv"
+	LOAD_TO_MEM_FROM_REG = "ldr"
+	LOAD_TO_REG_FROM_MEM = "ldm"
+
+	//CALL [label]
+	CALL = "call"
+
+	RETURN = "ret"
+
+	MOVE = "mov"
+	FACE = "rot"
+
+	FORK             = "fork"
+	SPLIT            = "split"
+	BITE             = "bite"
+	CONSUME_SUNLIGHT = "eatsun"
+	ABSORB_MINERALS  = "absorb"
+	CHECK            = "chk"
+	SKIP_CYCLE       = "nop"
+
+	NEGATE   = "neg"
+	ADD      = "add"
+	SUBTRACT = "sub"
+	DIVIDE   = "div"
+	MULTIPLY = "mul"
+	MOD      = "mod"
+	POWER    = "pow"
+)
+
+const (
+	AX = "AX"
+	BX = "BX"
+	CX = "CX" // flag for bot's memory being ready for reading
+	DX = "DX" // bot's memory
+
+	SD = "SD"
+	MD = "MD"
+	EN = "EN"
+	AG = "AG"
+)
+
+var Registers = [...]Register{AX, BX, CX, DX, SD, MD, EN, AG}
+
+type Direction int
+
+// directions are relative to the bot and listed clockwise
+const (
+	FRONT Direction = iota
+	FRONT_RIGHT
+	RIGHT
+	BACK_RIGHT
+	BACK
+	BACK_LEFT
+	LEFT
+	FRONT_LEFT
+	DIRECTIONS
+)
+
+var directionNames = [DIRECTIONS]string{"front", "frontright", "right", "backright", "back", "backleft", "left", "frontleft"}
+
+func (d Direction) String() string {
+	if d < 0 || d >= DIRECTIONS {
+		return "unknown"
+	}
+	return directionNames[d]
+}
+
+func ParseDirection(s string) (Direction, bool) {
+	for i, name := range directionNames {
+		if name == s {
+			return Direction(i), true
+		}
+	}
+	return 0, false
+}
+
+func IsRegister(name string) bool {
+	for _, register := range Registers {
+		if register == name {
+			return true
+		}
+	}
+	return false
+}
+
+var signatures = map[Opcode][]OperandKind{
+	COMPARE:            {REGISTER, REGISTER},
+	COMPARE_WITH_VALUE: {REGISTER, VALUE},
+
+	JUMP:                       {LABEL},
+	JUMP_IF_EQUAL:              {LABEL},
+	JUMP_IF_NOT_EQUAL:          {LABEL},
+	JUMP_IF_LESS_THAN:          {LABEL},
+	JUMP_IF_GREATER_THAN:       {LABEL},
+	JUMP_IF_LESS_EQUAL_THAN:    {LABEL},
+	JUMP_IF_GREATER_EQUAL_THAN: {LABEL},
+	JUMP_IF_EMPTY:              {LABEL},
+	JUMP_IF_FRIEND:             {LABEL},
+	JUMP_IF_SIBLING:            {LABEL},
+
+	LOAD_TO_REG_FROM_REG: {REGISTER, REGISTER},
+	LOAD_TO_REG_FROM_VAL: {REGISTER, VALUE},
+	LOAD_TO_MEM_FROM_REG: {MEMORY, REGISTER},
+	LOAD_TO_REG_FROM_MEM: {REGISTER, MEMORY},
+
+	CALL:   {LABEL},
+	RETURN: {},
+
+	MOVE:             {DIRECTION},
+	FACE:             {DIRECTION},
+	FORK:             {DIRECTION, LABEL},
+	SPLIT:            {DIRECTION, LABEL},
+	BITE:             {DIRECTION},
+	CONSUME_SUNLIGHT: {},
+	ABSORB_MINERALS:  {},
+	CHECK:            {DIRECTION},
+	SKIP_CYCLE:       {},
+
+	NEGATE:   {REGISTER},
+	ADD:      {REGISTER, REGISTER},
+	SUBTRACT: {REGISTER, REGISTER},
+	DIVIDE:   {REGISTER, REGISTER},
+	MULTIPLY: {REGISTER, REGISTER},
+	MOD:      {REGISTER, REGISTER},
+	POWER:    {REGISTER, REGISTER},
+}
+
+// Signature returns kinds of operands expected by the opcode
+func Signature(op Opcode) ([]OperandKind, bool) {
+	signature, ok := signatures[op]
+	return signature, ok
+}
+
+// IsJump reports whether the opcode transfers control to its label operand
+func IsJump(op Opcode) bool {
+	switch op {
+	case JUMP, JUMP_IF_EQUAL, JUMP_IF_NOT_EQUAL, JUMP_IF_LESS_THAN, JUMP_IF_GREATER_THAN,
+		JUMP_IF_LESS_EQUAL_THAN, JUMP_IF_GREATER_EQUAL_THAN, JUMP_IF_EMPTY, JUMP_IF_FRIEND, JUMP_IF_SIBLING:
+		return true
+	}
+	return false
+}
+
+// IsAction reports whether the opcode makes the bot spend its turn in the world
+func IsAction(op Opcode) bool {
+	switch op {
+	case MOVE, FACE, FORK, SPLIT, BITE, CONSUME_SUNLIGHT, ABSORB_MINERALS, SKIP_CYCLE:
+		return true
+	}
+	return false
+}
+
+// Power raises the base to the exponent as pow does, the compiler folds constants with it to get the same values
+func Power(base int, exponent int) (int, error) {
+	if exponent < 0 {
+		return 0, fmt.Errorf("negative exponent %d", exponent)
+	}
+	result := 1
+	for ; exponent > 0; exponent >>= 1 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+	}
+	return result, nil
+}
@@ -0,0 +1,130 @@
+package botlang_test
+
+import (
+	"NiLang/src/botlang"
+	"testing"
+)
+
+func TestParse(test *testing.T) {
+	input := []byte(`BEGIN:
+ldv AX 1
+ldr [129] AX
+ldm BX [129]
+cmpv BX -5
+jme lbl_a
+chk frontleft
+split backright BEGIN
+lbl_a:
+eatsun
+`)
+
+	instructions, err := botlang.Parse(input)
+	if err != nil {
+		test.Fatalf("botlang.Parse() has failed: %s", err)
+	}
+
+	tests := []struct {
+		label    string
+		opcode   botlang.Opcode
+		operands []botlang.Operand
+	}{
+		{"BEGIN", "", nil},
+		{"", botlang.LOAD_TO_REG_FROM_VAL, []botlang.Operand{botlang.Reg(botlang.AX), botlang.Val(1)}},
+		{"", botlang.LOAD_TO_MEM_FROM_REG, []botlang.Operand{botlang.Mem(129), botlang.Reg(botlang.AX)}},
+		{"", botlang.LOAD_TO_REG_FROM_MEM, []botlang.Operand{botlang.Reg(botlang.BX), botlang.Mem(129)}},
+		{"", botlang.COMPARE_WITH_VALUE, []botlang.Operand{botlang.Reg(botlang.BX), botlang.Val(-5)}},
+		{"", botlang.JUMP_IF_EQUAL, []botlang.Operand{botlang.Lbl("lbl_a")}},
+		{"", botlang.CHECK, []botlang.Operand{botlang.Dir(botlang.FRONT_LEFT)}},
+		{"", botlang.SPLIT, []botlang.Operand{botlang.Dir(botlang.BACK_RIGHT), botlang.Lbl("BEGIN")}},
+		{"lbl_a", "", nil},
+		{"", botlang.CONSUME_SUNLIGHT, nil},
+	}
+
+	if len(instructions) != len(tests) {
+		test.Fatalf("expected %d instructions, got=%d", len(tests), len(instructions))
+	}
+
+	for i, t := range tests {
+		instruction := instructions[i]
+		if instruction.Label != t.label || instruction.Opcode != t.opcode || len(instruction.Operands) != len(t.operands) {
+			test.Fatalf("tests[%d] - expected label=%q, opcode=%q, operands=%v, got=%+v", i, t.label, t.opcode, t.operands, instruction)
+		}
+
+		for j, operand := range t.operands {
+			if instruction.Operands[j] != operand {
+				test.Fatalf("tests[%d] - operand %d expected=%+v, got=%+v", i, j, operand, instruction.Operands[j])
+			}
+		}
+
+		if instruction.Line != i+1 {
+			test.Errorf("tests[%d] - expected line=%d, got=%d", i, i+1, instruction.Line)
+		}
+	}
+
+	if output := string(botlang.Format(instructions)); output != string(input) {
+		test.Fatalf("botlang.Format() wrong, expected=%q, got=%q", input, output)
+	}
+}
+
+func TestParseErrors(test *testing.T) {
+	tests := []string{
+		"jmp nowhere",
+		"ldv XX 1",
+		"ldv AX one",
+		"ldr 10 AX",
+		"ldm AX [x]",
+		"mov up",
+		"add AX",
+		"fly",
+		"a:\na:\n",
+		":",
+	}
+
+	for i, t := range tests {
+		if _, err := botlang.Parse([]byte(t)); err == nil {
+			test.Errorf("tests[%d] - successfully parsed ill-formed code %q", i, t)
+		}
+	}
+}
+
+func TestBuildInstructions(test *testing.T) {
+	instructions := []botlang.Instruction{
+		botlang.NewLabel("loop"),
+		botlang.New(botlang.COMPARE, botlang.Reg(botlang.AX), botlang.Reg(botlang.BX)),
+		botlang.New(botlang.FORK, botlang.Dir(botlang.LEFT), botlang.Lbl("loop")),
+		botlang.New(botlang.RETURN),
+	}
+
+	expected := "loop:\ncmp AX BX\nfork left loop\nret\n"
+	if output := string(botlang.Format(instructions)); output != expected {
+		test.Fatalf("botlang.Format() wrong, expected=%q, got=%q", expected, output)
+	}
+
+	if label, ok := instructions[2].Target(); !ok || label != "loop" {
+		test.Fatalf("expected target %q, got=%q", "loop", label)
+	}
+	if _, ok := instructions[1].Target(); ok {
+		test.Fatalf("unexpected target of %q", instructions[1])
+	}
+}
+
+func TestPower(test *testing.T) {
+	tests := []struct {
+		base, exponent, expected int
+	}{
+		{2, 10, 1024},
+		{-3, 3, -27},
+		{7, 0, 1},
+		{0, 0, 1},
+		{10, 1, 10},
+	}
+
+	for _, tt := range tests {
+		if power, err := botlang.Power(tt.base, tt.exponent); err != nil || power != tt.expected {
+			test.Errorf("%d ** %d - expected %d, got %d %v", tt.base, tt.exponent, tt.expected, power, err)
+		}
+	}
+	if _, err := botlang.Power(2, -1); err == nil {
+		test.Errorf("expected error for negative exponent")
+	}
+}
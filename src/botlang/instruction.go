@@ -0,0 +1,104 @@
+package botlang
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+type OperandKind int
+
+const (
+	REGISTER OperandKind = iota
+	VALUE
+	MEMORY
+	LABEL
+	DIRECTION
+)
+
+type Operand struct {
+	Kind      OperandKind
+	Register  Register
+	Value     int // holds a number or a memory address
+	Label     string
+	Direction Direction
+}
+
+func Reg(register Register) Operand   { return Operand{Kind: REGISTER, Register: register} }
+func Val(value int) Operand           { return Operand{Kind: VALUE, Value: value} }
+func Mem(addr int) Operand            { return Operand{Kind: MEMORY, Value: addr} }
+func Lbl(label string) Operand        { return Operand{Kind: LABEL, Label: label} }
+func Dir(direction Direction) Operand { return Operand{Kind: DIRECTION, Direction: direction} }
+
+func (o Operand) String() string {
+	switch o.Kind {
+	case REGISTER:
+		return o.Register
+	case VALUE:
+		return strconv.Itoa(o.Value)
+	case MEMORY:
+		return "[" + strconv.Itoa(o.Value) + "]"
+	case LABEL:
+		return o.Label
+	case DIRECTION:
+		return o.Direction.String()
+	default:
+		return "?"
+	}
+}
+
+// Instruction is either a command with its operands or a label,
+// which marks the position of the next command
+type Instruction struct {
+	Label    string // non-empty only for labels
+	Opcode   Opcode
+	Operands []Operand
+	Line     int // line in the parsed text, zero for generated instructions
+}
+
+func New(op Opcode, operands ...Operand) Instruction {
+	return Instruction{Opcode: op, Operands: operands}
+}
+
+func NewLabel(label string) Instruction {
+	return Instruction{Label: label}
+}
+
+func (i Instruction) IsLabel() bool {
+	return i.Label != ""
+}
+
+// Target returns label operand of jumps, calls, splits and forks
+func (i Instruction) Target() (string, bool) {
+	for _, operand := range i.Operands {
+		if operand.Kind == LABEL {
+			return operand.Label, true
+		}
+	}
+	return "", false
+}
+
+func (i Instruction) String() string {
+	if i.IsLabel() {
+		return i.Label + ":"
+	}
+
+	var out strings.Builder
+	out.WriteString(i.Opcode)
+	for _, operand := range i.Operands {
+		out.WriteString(" ")
+		out.WriteString(operand.String())
+	}
+	return out.String()
+}
+
+// Format writes instructions as botlang text, one per line
+func Format(instructions []Instruction) []byte {
+	var out bytes.Buffer
+
+	for _, instruction := range instructions {
+		out.WriteString(instruction.String())
+		out.WriteString("\n")
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,116 @@
+package botlang
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Error struct {
+	Line        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Description)
+}
+
+// Parse reads botlang text and checks that operands match their opcodes
+// and every referenced label is declared exactly once
+func Parse(code []byte) ([]Instruction, error) {
+	instructions := make([]Instruction, 0)
+	declared := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(code))
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) == 1 && strings.HasSuffix(fields[0], ":") {
+			label := strings.TrimSuffix(fields[0], ":")
+			if label == "" {
+				return nil, &Error{line, "empty label"}
+			}
+			if declared[label] {
+				return nil, &Error{line, fmt.Sprintf("redeclaration of label %q", label)}
+			}
+			declared[label] = true
+			instructions = append(instructions, Instruction{Label: label, Line: line})
+			continue
+		}
+
+		op := fields[0]
+		signature, ok := Signature(op)
+		if !ok {
+			return nil, &Error{line, fmt.Sprintf("unknown opcode %q", op)}
+		}
+
+		if len(fields)-1 != len(signature) {
+			return nil, &Error{line, fmt.Sprintf("unexpected number of operands for %q expected=%d, got=%d", op, len(signature), len(fields)-1)}
+		}
+
+		instruction := Instruction{Opcode: op, Operands: make([]Operand, len(signature)), Line: line}
+		for i, kind := range signature {
+			operand, err := parseOperand(kind, fields[i+1])
+			if err != nil {
+				return nil, &Error{line, err.Error()}
+			}
+			instruction.Operands[i] = operand
+		}
+
+		instructions = append(instructions, instruction)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, instruction := range instructions {
+		if label, ok := instruction.Target(); ok && !declared[label] {
+			return nil, &Error{instruction.Line, fmt.Sprintf("undeclared label %q", label)}
+		}
+	}
+
+	return instructions, nil
+}
+
+func parseOperand(kind OperandKind, field string) (Operand, error) {
+	switch kind {
+	case REGISTER:
+		if !IsRegister(field) {
+			return Operand{}, fmt.Errorf("expected register, got %q", field)
+		}
+		return Reg(field), nil
+	case VALUE:
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return Operand{}, fmt.Errorf("expected number, got %q", field)
+		}
+		return Val(value), nil
+	case MEMORY:
+		if !strings.HasPrefix(field, "[") || !strings.HasSuffix(field, "]") {
+			return Operand{}, fmt.Errorf("expected memory address in brackets, got %q", field)
+		}
+		addr, err := strconv.Atoi(field[1 : len(field)-1])
+		if err != nil {
+			return Operand{}, fmt.Errorf("expected memory address, got %q", field)
+		}
+		return Mem(addr), nil
+	case LABEL:
+		return Lbl(field), nil
+	case DIRECTION:
+		direction, ok := ParseDirection(field)
+		if !ok {
+			return Operand{}, fmt.Errorf("expected direction, got %q", field)
+		}
+		return Dir(direction), nil
+	default:
+		return Operand{}, fmt.Errorf("unknown kind of operand %d", kind)
+	}
+}
@@ -0,0 +1,140 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/test"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// build is the outcome of compilation of one file
+type build struct {
+	fileName string
+	name     string // absolute, used in errors
+	input    []byte
+	output   string
+	usage    compiler.Usage
+	errors   []helper.Error
+	warnings []helper.Error
+	err      error // of reading or writing files
+}
+
+// buildFiles compiles .nil files of the directories on a pool of workers and writes a .tor file for each of them,
+// errors are reported in the order of the files whatever order the workers finish in
+func buildFiles(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	stackSize := flags.Int("s", compiler.AUTO_STACK_SIZE, "stack size in bytes, measured from the program if 0")
+	memorySize := flags.Int("m", compiler.TARGET_MEMORY_SIZE, "memory size of the target bot in bytes, a program taking more is an error, 0 for no limit")
+	optimization := flags.Int("O", compiler.DEFAULT_OPTIMIZATION, "optimization level, 0 keeps code as emitted, 1 removes redundant commands, 2 also keeps operands in registers")
+	frames := flags.Int("frames", compiler.DEFAULT_FRAMES, "calls of a recursive function which may run at once, each copies the memory of the function, 0 makes recursion an error")
+	outputDirectory := flags.String("o", "", "directory for .tor files keeping paths of the sources, next to the sources if empty")
+	workers := flags.Int("j", runtime.NumCPU(), "number of files compiled at once")
+	verbose := flags.Bool("v", false, "print names of written files with stack, memory and commands of frame routines taken by their programs")
+	directories := domainFlags(flags)
+	diagnostics := diagnosticsFlag(flags)
+	patterns := parseFlags(flags, args)
+
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	if *workers < 1 {
+		log.Fatal("Expected at least one worker")
+	}
+
+	// files of domains are compiled with the programs using them
+	fileNames := findFiles(patterns, func(path string) bool {
+		return strings.HasSuffix(path, compiler.EXTENSION) && !strings.HasSuffix(path, test.SUFFIX) && !isDomain(path)
+	})
+	if len(fileNames) == 0 {
+		log.Fatal("No files to build")
+	}
+
+	builds := make([]build, len(fileNames))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(*workers, len(fileNames)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := compiler.New(*stackSize)
+			c.LimitMemory(*memorySize)
+			c.Optimize(*optimization)
+			c.LimitFrames(*frames)
+			for i := range jobs {
+				builds[i] = buildFile(c, fileNames[i], directories(fileNames[i]), *outputDirectory)
+			}
+		}()
+	}
+	for i := range fileNames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := diagnostics()
+	failed := 0
+	for _, b := range builds {
+		reported := report.Errors()
+		reportErrors(report, append(b.errors, b.warnings...), b.name, b.input)
+		switch {
+		case b.err != nil:
+			fmt.Fprintln(os.Stderr, b.err)
+		case len(b.errors) == 0 && *verbose:
+			fmt.Printf("%s -> %s: %s\n", b.fileName, b.output, summary(b.usage))
+		}
+		if b.err != nil || report.Errors() != reported { // warnings are errors with -Werror
+			failed++
+		}
+	}
+
+	if err := report.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if failed != 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d files failed to build\n", failed, len(builds))
+		os.Exit(1)
+	}
+}
+
+// isDomain reads the file, a file which can't be read is built to report the error
+func isDomain(fileName string) bool {
+	input, err := os.ReadFile(fileName)
+	return err == nil && compiler.IsDomain(input)
+}
+
+// buildFile compiles the file with the compiler of the worker and writes its code
+func buildFile(c *compiler.Compiler, fileName string, directories []string, outputDirectory string) build {
+	b := build{fileName: fileName, name: absolute(fileName)}
+
+	b.input, b.err = os.ReadFile(fileName)
+	if b.err != nil {
+		return b
+	}
+
+	code, errors := c.CompileFiles(b.name, b.input, directories)
+	b.warnings = c.Warnings()
+	if len(errors) != 0 {
+		b.errors = errors
+		return b
+	}
+
+	b.usage = c.Usage()
+	b.output = strings.TrimSuffix(fileName, compiler.EXTENSION) + ".tor"
+	if outputDirectory != "" {
+		b.output = filepath.Join(outputDirectory, b.output)
+		if b.err = os.MkdirAll(filepath.Dir(b.output), 0755); b.err != nil {
+			return b
+		}
+	}
+	b.err = os.WriteFile(b.output, code, 0666)
+	return b
+}
@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"fmt"
+	"slices"
+)
+
+const ASSERT = "Assert"
+
+// Assertion is a call of the Assert builtin
+type Assertion struct {
+	File   string
+	Line   int
+	Offset int
+}
+
+// EnableAssertions declares `Fun Assert$ condition Bool` in the global scope for tests, it has to be called before
+// compilation. A failed assertion stores its number counted from 1 at AssertionAddress and stops the program
+func (c *Compiler) EnableAssertions() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.assert {
+		c.assert = true
+		c.reset(c.stackSize)
+	}
+}
+
+// AssertionAddress returns the address of the number of the failed assertion, it follows the stack,
+// so it's known after compilation only
+func (c *Compiler) AssertionAddress() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return int(c.assertAddress)
+}
+
+// Assertions lists calls of Assert in the order of their numbers
+func (c *Compiler) Assertions() []Assertion {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.assertions)
+}
+
+func (c *Compiler) initAssert(globalScope *scope) {
+	globalScope.functions[ASSERT] = function{
+		Name:      ASSERT,
+		Label:     ASSERT,
+		Type:      VOID,
+		Arguments: make([]variable, 1),
+		IsBuiltin: true}
+
+	c.define(ASSERT, FUNCTION_DEFINITION, ASSERT, VOID.String(), "Fun Assert$ condition Bool", tokens.Token{})
+}
+
+func (c *Compiler) compileAssert(expression *ast.CallExpression) (Type, register) {
+	t, register := c.compileExpression(expression.Arguments[0])
+	if t != builtIn(Bool) {
+		err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH,
+			fmt.Sprintf("unexpected type of an argument expected %q, got %q", Bool, t.String()))
+		c.addError(err)
+		return VOID, ""
+	}
+
+	token := expression.Token
+	if identifier, ok := expression.Function.(*ast.Identifier); ok {
+		token = identifier.Token
+	}
+	c.assertions = append(c.assertions, Assertion{File: c.file, Line: token.Line, Offset: token.Offset})
+	passed := c.getUniqueLabel()
+
+	c.emit(COMPARE_WITH_VALUE, register, BOOL_TRUE)
+	c.emit(JUMP_IF_EQUAL, passed)
+	c.emit(LOAD_TO_REG_FROM_VAL, AX, len(c.assertions))
+	c.emit(LOAD_TO_MEM_FROM_REG, c.assertAddress, AX)
+	c.emit(JUMP, END_LABEL)
+	c.emitLabel(passed)
+
+	return VOID, ""
+}
+
+// emitEnd marks the end of the program where failed assertions and pushes to full frames jump
+func (c *Compiler) emitEnd() {
+	if c.assertAddress != 0 || len(c.recursive) != 0 {
+		c.emitLabel(END_LABEL)
+	}
+}
@@ -2,68 +2,103 @@ package compiler
 
 import (
 	"NiLang/src/ast"
+	"NiLang/src/botlang"
+	"NiLang/src/diagnostic"
 	"NiLang/src/helper"
+	"NiLang/src/tokens"
 	"fmt"
-	"log"
+	"strings"
 )
 
-func (c *Compiler) initBuiltin(globalScope *scope) {
-	builtins := []struct {
-		name              string
-		numberOfArguments int
-	}{
-		{"Fork", 1},
-		{"Split", 1},
-		{"Bite", 1},
-		{"ConsumeSunlight", 0},
-		{"AbsorbMinerals", 0},
-		{"IsEmpty", 1},
-		{"IsSibling", 1},
-		{"IsFriend", 1},
-		{"GetLuminosity", 1},
-		{"GetMineralization", 1},
-		{"Sleep", 0},
-		{"Move", 1},
-		{"Face", 1},
-		{"GetAge", 0},
-		{"GetEnergy", 0},
-		{"IsMemoryReady", 0},
-		{"ReadMemory", 0},
-		{"WriteMemory", 1},
+type Builtin struct {
+	Name              string
+	NumberOfArguments int
+	Signature         string
+}
+
+// BUILTIN_FUNCTIONS are functions of the scope bot, e.g. bot::Move$ dir::front
+var BUILTIN_FUNCTIONS = []Builtin{
+	{"Fork", 1, "Fun Fork$ direction Dir"},
+	{"Split", 1, "Fun Split$ direction Dir"},
+	{"Bite", 1, "Fun Bite$ direction Dir"},
+	{"ConsumeSunlight", 0, "Fun ConsumeSunlight"},
+	{"AbsorbMinerals", 0, "Fun AbsorbMinerals"},
+	{"IsEmpty", 1, "Fun IsEmpty::Bool$ direction Dir"},
+	{"IsSibling", 1, "Fun IsSibling::Bool$ direction Dir"},
+	{"IsFriend", 1, "Fun IsFriend::Bool$ direction Dir"},
+	{"GetLuminosity", 1, "Fun GetLuminosity::Int$ direction Dir"},
+	{"GetMineralization", 1, "Fun GetMineralization::Int$ direction Dir"},
+	{"Sleep", 0, "Fun Sleep"},
+	{"Move", 1, "Fun Move$ direction Dir"},
+	{"Face", 1, "Fun Face$ direction Dir"},
+	{"GetAge", 0, "Fun GetAge::Int"},
+	{"GetEnergy", 0, "Fun GetEnergy::Int"},
+	{"IsMemoryReady", 0, "Fun IsMemoryReady::Bool"},
+	{"ReadMemory", 0, "Fun ReadMemory::Int"},
+	{"WriteMemory", 1, "Fun WriteMemory::Int$ value Int"},
+}
+
+// checkJumps are taken after chk if the checked cell is empty, occupied by a sibling or by a friend
+var checkJumps = map[name]command{"IsEmpty": JUMP_IF_EMPTY, "IsSibling": JUMP_IF_SIBLING, "IsFriend": JUMP_IF_FRIEND}
+
+// returnType is taken from the signature, the same way as the type of user's function is written
+func (b Builtin) returnType() string {
+	_, after, ok := strings.Cut(b.Signature, "::")
+	if !ok {
+		return VOID.String()
 	}
+	t, _, _ := strings.Cut(after, "$")
+	return t
+}
+
+// DIRECTION_NAMES are values of the scope dir indexed by their codes, e.g. dir::front
+var DIRECTION_NAMES = [DIR_END]string{"_", "front", "frontRight", "right", "backRight", "back", "backLeft", "left", "frontLeft"}
+
+func (c *Compiler) initBuiltin(globalScope *scope) {
 	bot := newScope("bot")
 
-	for _, builtin := range builtins {
-		bot.functions[builtin.name] = function{
-			Name:      builtin.name,
-			Label:     "",
+	for _, builtin := range BUILTIN_FUNCTIONS {
+		bot.functions[builtin.Name] = function{
+			Name:      builtin.Name,
+			Label:     bot.name + "::" + builtin.Name,
 			Type:      VOID, //we shouldn't check this at all
-			Arguments: make([]variable, builtin.numberOfArguments),
+			Arguments: make([]variable, builtin.NumberOfArguments),
 			IsBuiltin: true}
+
+		c.define(bot.name+"::"+builtin.Name, FUNCTION_DEFINITION, bot.name+"::"+builtin.Name, builtin.returnType(), builtin.Signature, tokens.Token{})
 	}
 
 	ok := globalScope.AddScope(bot)
 	if !ok {
-		log.Fatalf("failed to initialize builtin variables")
+		internalError("failed to initialize builtin variables")
 	}
+	c.define(bot, SCOPE_DEFINITION, bot.name, "", "Scope "+bot.name, tokens.Token{})
 
 	dir := newScope(helper.FirstToLowerCase(Dir))
 
-	var directions = [DIR_END]string{"_", "front", "frontRight", "right", "backRight", "back", "backLeft", "left", "frontLeft"}
-
 	for direction := DIR_BEGIN + 1; direction < DIR_END; direction++ {
-		addr := c.purchaseMemoryAddress()
-		ok := dir.AddVariable(directions[direction], addr, builtIn(Dir))
+		addr := c.purchaseMemoryAddress(tokens.Token{})
+		ok := dir.AddVariable(DIRECTION_NAMES[direction], addr, builtIn(Dir))
 		if !ok {
-			log.Fatalf("failed to initialize builtin variables")
+			internalError("failed to initialize builtin variables")
 		}
+		c.addSymbol(dir, variable{Name: DIRECTION_NAMES[direction], Addr: addr, Type: builtIn(Dir)}, true)
+		c.constants[addr] = direction
+
+		name := dir.name + "::" + DIRECTION_NAMES[direction]
+		c.define(addr, VARIABLE_DEFINITION, name, Dir, Dir+" "+name, tokens.Token{})
 		c.emit(LOAD_TO_REG_FROM_VAL, AX, direction)
 		c.emit(LOAD_TO_MEM_FROM_REG, addr, AX)
 	}
 
 	ok = globalScope.AddScope(dir)
 	if !ok {
-		log.Fatalf("failed to initialize builtin variables")
+		internalError("failed to initialize builtin variables")
+	}
+	c.define(dir, SCOPE_DEFINITION, dir.name, "", "Scope "+dir.name, tokens.Token{})
+
+	if c.assertAddress != 0 {
+		c.initAssert(globalScope)
 	}
 }
 
@@ -83,33 +118,15 @@ func (c *Compiler) compileBuiltin(expression *ast.CallExpression, name name) (Ty
 		return builtIn(Bool), AX
 	}
 
-	direction := func() register {
-		numberOfArguments := 1
-		if len(expression.Arguments) != numberOfArguments {
-			err := helper.MakeError(expression.Token,
-				fmt.Sprintf("unexpected number of arguments expected=%d, got=%d", numberOfArguments, len(expression.Arguments)))
-			c.addError(err)
-			return ""
-		}
-		t, register := c.compileExpression(expression.Arguments[0])
-
-		if t != builtIn(Dir) {
-			err := helper.MakeError(expression.Token,
-				fmt.Sprintf("unexpected type of an argument expected %q, got %q", Dir, t.String()))
-			c.addError(err)
-		}
-		return register
-	}
-
 	switch name {
 	case "Fork":
-		c.compileFunctionWithDirectionArgument(FORK, direction())
+		c.compileFunctionWithDirectionArgument(FORK, expression)
 		return VOID, ""
 	case "Split":
-		c.compileFunctionWithDirectionArgument(SPLIT, direction())
+		c.compileFunctionWithDirectionArgument(SPLIT, expression)
 		return VOID, ""
 	case "Bite":
-		c.compileFunctionWithDirectionArgument(BITE, direction())
+		c.compileFunctionWithDirectionArgument(BITE, expression)
 		return VOID, ""
 	case "ConsumeSunlight":
 		c.emit(CONSUME_SUNLIGHT)
@@ -117,31 +134,25 @@ func (c *Compiler) compileBuiltin(expression *ast.CallExpression, name name) (Ty
 	case "AbsorbMinerals":
 		c.emit(ABSORB_MINERALS)
 		return VOID, ""
-	case "IsEmpty":
-		c.compileFunctionWithDirectionArgument(CHECK, direction())
-		return emitComparison(JUMP_IF_EMPTY)
-	case "IsSibling":
-		c.compileFunctionWithDirectionArgument(CHECK, direction())
-		return emitComparison(JUMP_IF_SIBLING)
-	case "IsFriend":
-		c.compileFunctionWithDirectionArgument(CHECK, direction())
-		return emitComparison(JUMP_IF_FRIEND)
+	case "IsEmpty", "IsSibling", "IsFriend":
+		c.compileFunctionWithDirectionArgument(CHECK, expression)
+		return emitComparison(checkJumps[name])
 	case "GetLuminosity":
-		c.compileFunctionWithDirectionArgument(CHECK, direction())
+		c.compileFunctionWithDirectionArgument(CHECK, expression)
 		c.emit(LOAD_TO_REG_FROM_REG, AX, SD)
 		return builtIn(Int), AX
 	case "GetMineralization":
-		c.compileFunctionWithDirectionArgument(CHECK, direction())
+		c.compileFunctionWithDirectionArgument(CHECK, expression)
 		c.emit(LOAD_TO_REG_FROM_REG, AX, MD)
 		return builtIn(Int), AX
 	case "Sleep":
 		c.emit(SKIP_CYCLE)
 		return VOID, ""
 	case "Move":
-		c.compileFunctionWithDirectionArgument(MOVE, direction())
+		c.compileFunctionWithDirectionArgument(MOVE, expression)
 		return VOID, ""
 	case "Face":
-		c.compileFunctionWithDirectionArgument(FACE, direction())
+		c.compileFunctionWithDirectionArgument(FACE, expression)
 		return VOID, ""
 	case "GetAge":
 		c.emit(LOAD_TO_REG_FROM_REG, AX, AG)
@@ -158,14 +169,14 @@ func (c *Compiler) compileBuiltin(expression *ast.CallExpression, name name) (Ty
 	case "WriteMemory":
 		numberOfArguments := 1
 		if len(expression.Arguments) != 1 {
-			err := helper.MakeError(expression.Token,
+			err := helper.MakeError(expression.Token, diagnostic.ARGUMENT_COUNT,
 				fmt.Sprintf("unexpected number of arguments expected=%d, got=%d", numberOfArguments, len(expression.Arguments)))
 			c.addError(err)
 		}
 		t, register := c.compileExpression(expression.Arguments[0])
 
 		if t != builtIn(Int) {
-			err := helper.MakeError(expression.Token,
+			err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH,
 				fmt.Sprintf("unexpected type of an argument expected %q, got %q", Int, t.String()))
 			c.addError(err)
 		}
@@ -175,53 +186,91 @@ func (c *Compiler) compileBuiltin(expression *ast.CallExpression, name name) (Ty
 
 		return builtIn(Int), register
 	default:
-		log.Fatalf("builtin function %q is not handled", name)
+		internalError("builtin function %q is not handled", name)
 		return VOID, ""
 	}
 }
 
-func (c *Compiler) compileFunctionWithDirectionArgument(command command, register register) {
+// compileDirectionArgument compiles the only argument of a builtin taking a direction
+func (c *Compiler) compileDirectionArgument(expression *ast.CallExpression) register {
+	numberOfArguments := 1
+	if len(expression.Arguments) != numberOfArguments {
+		err := helper.MakeError(expression.Token, diagnostic.ARGUMENT_COUNT,
+			fmt.Sprintf("unexpected number of arguments expected=%d, got=%d", numberOfArguments, len(expression.Arguments)))
+		c.addError(err)
+		return ""
+	}
+	t, register := c.compileExpression(expression.Arguments[0])
+
+	if t != builtIn(Dir) {
+		err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH,
+			fmt.Sprintf("unexpected type of an argument expected %q, got %q", Dir, t.String()))
+		c.addError(err)
+	}
+	return register
+}
+
+// compileFunctionWithDirectionArgument emits the command with the direction of the argument right away if the direction
+// is known at compile time, e.g. `mov front`, otherwise it calls the dispatch routine of the command
+func (c *Compiler) compileFunctionWithDirectionArgument(command command, expression *ast.CallExpression) {
+	if len(expression.Arguments) == 1 {
+		if value, ok := c.peek(expression.Arguments[0]); ok && value.Type == builtIn(Dir) {
+			c.fold(expression.Arguments[0])
+			c.emitDirectionCommand(command, value.Value)
+			return
+		}
+	}
+
+	register := c.compileDirectionArgument(expression)
+	if register != AX {
+		c.emit(LOAD_TO_REG_FROM_REG, AX, register)
+	}
+	c.emit(CALL, c.dispatch(command))
+}
+
+func (c *Compiler) emitDirectionCommand(command command, dir int) {
+	direction := botlang.Direction(dir - FRONT)
+
+	if command == SPLIT || command == FORK {
+		c.emit(command, direction, BEGIN_LABEL)
+	} else {
+		c.emit(command, direction)
+	}
+}
+
+// dispatch returns the label of the routine executing the command with the direction held by AX, the routine
+// is emitted by the first call and skipped by the code around it. Its commands have no source
+func (c *Compiler) dispatch(command command) string {
+	if label, ok := c.dispatches[command]; ok {
+		return label
+	}
+
+	start := c.getUniqueLabel()
+	end := c.getUniqueLabel()
+	c.dispatches[command] = start
+
+	previous := c.origin
+	c.origin = origin{}
+	defer func() { c.origin = previous }()
+
+	c.emit(JUMP, end)
+	c.emitLabel(start)
+
 	var labels [DIR_END]string
 	for dir := DIR_BEGIN + 1; dir < DIR_END; dir++ {
-		c.emit(COMPARE_WITH_VALUE, register, dir)
+		c.emit(COMPARE_WITH_VALUE, AX, dir)
 		label := c.getUniqueLabel()
 		c.emit(JUMP_IF_EQUAL, label)
 
 		labels[dir] = label
 	}
 
-	end := c.getUniqueLabel()
-
 	for dir := DIR_BEGIN + 1; dir < DIR_END; dir++ {
 		c.emitLabel(labels[dir])
-
-		var direction string
-		switch dir {
-		case FRONT:
-			direction = "front"
-		case FRONT_RIGHT:
-			direction = "frontright"
-		case RIGHT:
-			direction = "right"
-		case BACK_RIGHT:
-			direction = "backright"
-		case BACK:
-			direction = "back"
-		case BACK_LEFT:
-			direction = "backleft"
-		case LEFT:
-			direction = "left"
-		case FRONT_LEFT:
-			direction = "frontleft"
-		}
-
-		if command == SPLIT || command == FORK {
-			c.emit(command, direction, BEGIN_LABEL)
-		} else {
-			c.emit(command, direction)
-		}
-		c.emit(JUMP, end)
+		c.emitDirectionCommand(command, dir)
+		c.emit(RETURN)
 	}
 
 	c.emitLabel(end)
+	return start
 }
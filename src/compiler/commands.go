@@ -1,53 +1,51 @@
 package compiler
 
-type command = string
+import "NiLang/src/botlang"
+
+type command = botlang.Opcode
 
 const (
-	// COMPARE [a] [b] ?a>=b ?a==b ?a<b ?a!=b
-	COMPARE            = "cmp"
-	COMPARE_WITH_VALUE = "cmpv"
-
-	//JUMP [label]
-	JUMP                 = "jmp"
-	JUMP_IF_EQUAL        = "jme"
-	JUMP_IF_NOT_EQUAL    = "jne"
-	JUMP_IF_LESS_THAN    = "jml"
-	JUMP_IF_GREATER_THAN = "jmg"
-
-	JUMP_IF_LESS_EQUAL_THAN    = "jle"
-	JUMP_IF_GREATER_EQUAL_THAN = "jge"
-
-	JUMP_IF_EMPTY   = "jmf"
-	JUMP_IF_FRIEND  = "jmc"
-	JUMP_IF_SIBLING = "jmb"
-
-	// LOAD_TO_REG_FROM_REG [target] [source]
-	LOAD_TO_REG_FROM_REG = "ld"
-	LOAD_TO_REG_FROM_VAL = "ldv"
-	LOAD_TO_MEM_FROM_REG = "ldr"
-	LOAD_TO_REG_FROM_MEM = "ldm"
-
-	//CALL [label]
-	CALL = "call"
-
-	RETURN = "ret"
-
-	MOVE = "mov"
-	FACE = "rot"
-
-	FORK             = "fork"
-	SPLIT            = "split"
-	BITE             = "bite"
-	CONSUME_SUNLIGHT = "eatsun"
-	ABSORB_MINERALS  = "absorb"
-	CHECK            = "chk"
-	SKIP_CYCLE       = "nop"
-
-	NEGATE   = "neg"
-	ADD      = "add"
-	SUBTRACT = "sub"
-	DIVIDE   = "div"
-	MULTIPLY = "mul"
-	MOD      = "mod"
-	POWER    = "pow"
+	COMPARE            = botlang.COMPARE
+	COMPARE_WITH_VALUE = botlang.COMPARE_WITH_VALUE
+
+	JUMP                 = botlang.JUMP
+	JUMP_IF_EQUAL        = botlang.JUMP_IF_EQUAL
+	JUMP_IF_NOT_EQUAL    = botlang.JUMP_IF_NOT_EQUAL
+	JUMP_IF_LESS_THAN    = botlang.JUMP_IF_LESS_THAN
+	JUMP_IF_GREATER_THAN = botlang.JUMP_IF_GREATER_THAN
+
+	JUMP_IF_LESS_EQUAL_THAN    = botlang.JUMP_IF_LESS_EQUAL_THAN
+	JUMP_IF_GREATER_EQUAL_THAN = botlang.JUMP_IF_GREATER_EQUAL_THAN
+
+	JUMP_IF_EMPTY   = botlang.JUMP_IF_EMPTY
+	JUMP_IF_FRIEND  = botlang.JUMP_IF_FRIEND
+	JUMP_IF_SIBLING = botlang.JUMP_IF_SIBLING
+
+	LOAD_TO_REG_FROM_REG = botlang.LOAD_TO_REG_FROM_REG
+	LOAD_TO_REG_FROM_VAL = botlang.LOAD_TO_REG_FROM_VAL
+	LOAD_TO_MEM_FROM_REG = botlang.LOAD_TO_MEM_FROM_REG
+	LOAD_TO_REG_FROM_MEM = botlang.LOAD_TO_REG_FROM_MEM
+
+	CALL = botlang.CALL
+
+	RETURN = botlang.RETURN
+
+	MOVE = botlang.MOVE
+	FACE = botlang.FACE
+
+	FORK             = botlang.FORK
+	SPLIT            = botlang.SPLIT
+	BITE             = botlang.BITE
+	CONSUME_SUNLIGHT = botlang.CONSUME_SUNLIGHT
+	ABSORB_MINERALS  = botlang.ABSORB_MINERALS
+	CHECK            = botlang.CHECK
+	SKIP_CYCLE       = botlang.SKIP_CYCLE
+
+	NEGATE   = botlang.NEGATE
+	ADD      = botlang.ADD
+	SUBTRACT = botlang.SUBTRACT
+	DIVIDE   = botlang.DIVIDE
+	MULTIPLY = botlang.MULTIPLY
+	MOD      = botlang.MOD
+	POWER    = botlang.POWER
 )
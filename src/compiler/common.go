@@ -1,7 +1,6 @@
 package compiler
 
 import (
-	"log"
 	"slices"
 )
 
@@ -35,6 +34,7 @@ const (
 const RETURN_REGISTER = AX
 
 const BEGIN_LABEL = "BEGIN"
+const END_LABEL = "END"
 
 var BUILTIN_TYPES = []name{Int, Bool, Dir}
 
@@ -42,6 +42,6 @@ func builtIn(name name) Type {
 	if slices.Contains(BUILTIN_TYPES, name) {
 		return Type{Scope: nil, Name: name}
 	}
-	log.Fatalf("got unknown built in type %q", name)
+	internalError("got unknown built in type %q", name)
 	return Type{}
 }
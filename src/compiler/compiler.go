@@ -2,23 +2,49 @@ package compiler
 
 import (
 	"NiLang/src/ast"
+	"NiLang/src/botlang"
+	"NiLang/src/diagnostic"
 	"NiLang/src/helper"
 	"NiLang/src/lexer"
 	"NiLang/src/parser"
 	"NiLang/src/tokens"
-	"bytes"
 	"fmt"
-	"log"
+	"math"
 	"slices"
-	"strconv"
+	"sync"
 )
 
 type errors = []helper.Error
 
+// Compiler may be shared by goroutines, its exported methods hold the mutex. Every compilation starts anew,
+// so a compiler is reused for many programs, except Extend which continues the previous compilation
 type Compiler struct {
-	output           bytes.Buffer
+	mutex sync.Mutex
+
+	stackSize    int               // AUTO_STACK_SIZE to measure the stack of every program
+	memoryLimit  int               // set by LimitMemory
+	sources      map[string][]byte // added by AddSource
+	assert       bool              // assertions are enabled
+	optimization int               // level set by Optimize
+	frameDepth   int               // set by LimitFrames
+
+	state
+}
+
+// state of a compilation, its exported parts are read by methods of Compiler after the compilation
+type state struct {
+	code             []botlang.Instruction
+	origins          []origin // source of each instruction in code
+	origin           origin
+	located          tokens.Token // the last located node, it is kept when a panic unwinds locations
+	statements       int          // number of statements compiled so far
 	memoryIndex      address
 	stackMemoryIndex address
+	stackPeak        address       // the deepest address of the stack since it was flushed
+	stackDepth       int           // bytes of the stack used by the deepest statement
+	overflow         *tokens.Token // the expression which has exceeded the stack since it was flushed
+	memorySize       address       // limit of memory, zero if there is none
+	memoryOverflow   *tokens.Token // the first declaration which has exceeded memory
 
 	scope *scope
 
@@ -26,107 +52,276 @@ type Compiler struct {
 
 	maxStackAddress address
 	errors          errors
+	warnings        errors // the code is emitted in spite of them
+
+	symbols    []symbol
+	constants  map[address]int    // values of aliases and directions
+	dispatches map[command]string // labels of routines executing commands with a direction held by AX
+	scopeEnds  map[*scope]int     // index of code where the scope has been left
+
+	allocations  []Allocation             // memory which is not a variable, e.g. stacks and frames of functions
+	temporaries  map[address]bool         // addresses of stacks of functions
+	declarations map[address]tokens.Token // which have taken each address
+	free         []address                // of variables of blocks of the top level which have ended
+	frame        *frame                   // of the function being compiled, nil at the top level
+	frames       map[string]*frame        // keyed by label of function
+	calls        []call
+	linked       int      // number of calls checked by linkFrames
+	framed       bool     // frames are linked at the end of the pass
+	framesCall   int      // index of the call of FRAMES_LABEL in code
+	recursive    []*frame // functions copying their frames
+
+	definitions map[any]Definition // keyed by address of variable, label of function or scope
+	references  []Reference
+	imports     []imported
+
+	file     string // name of the file being compiled for errors
+	programs []*ast.Program
+
+	assertAddress address // zero unless assertions are enabled
+	assertions    []Assertion
+
+	overflowAddress address // of the number of the recursive function whose frames are full, zero without recursion
 }
 
+// AUTO_STACK_SIZE makes Compile and CompileFiles measure the stack needed by the program and reserve no more
+const AUTO_STACK_SIZE = 0
+
+// New creates a compiler reserving stackSize bytes at the beginning of memory for values of expressions,
+// variables follow the stack
 func New(stackSize int) *Compiler {
-	return &Compiler{
+	c := &Compiler{stackSize: stackSize, frameDepth: DEFAULT_FRAMES}
+	c.reset(stackSize)
+	return c
+}
+
+func (c *Compiler) reset(stackSize int) {
+	c.state = state{
 		memoryIndex:      address(stackSize),
 		stackMemoryIndex: -1,
+		stackPeak:        -1,
 		scope:            newScope(""),
 		lastLabel:        "",
-		maxStackAddress:  address(stackSize)}
+		scopeEnds:        make(map[*scope]int),
+		definitions:      make(map[any]Definition),
+		constants:        make(map[address]int),
+		dispatches:       make(map[command]string),
+		temporaries:      make(map[address]bool),
+		declarations:     make(map[address]tokens.Token),
+		frames:           make(map[string]*frame),
+		maxStackAddress:  address(stackSize),
+		memorySize:       address(c.memoryLimit)}
+
+	if c.assert {
+		c.assertAddress = c.purchaseMemoryAddress(tokens.Token{})
+	}
 }
 
-func (c *Compiler) Compile(input []byte, printAST bool) ([]byte, errors) {
+func (c *Compiler) Compile(input []byte) (code []byte, errs errors) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reset(c.stackSize)
 
 	lexer := lexer.New(input)
 	parser := parser.New(&lexer)
 	program := parser.Parse()
-	if program == nil {
-		log.Fatalf("parser.Parse() has returned nil")
-	}
 
-	errors := parser.Errors()
-	if len(errors) != 0 {
-		fmt.Printf("parser had %d error(s)\n", len(errors))
-		fmt.Print("parser error(s):\n")
+	if errors := parser.Errors(); len(errors) != 0 {
+		return nil, errors
+	}
 
-		return c.output.Bytes(), errors
+	f := &file{program: program}
+	if len(program.Statements) != 0 {
+		f.domain, _ = program.Statements[0].(*ast.DomainStatement)
 	}
+	return c.generate([]*file{f})
+}
 
-	if printAST {
-		fmt.Println("PROGRAM TREE")
+// generate compiles the files in their order. If the stack is measured, the first pass finds the deepest
+// statement and the second one compiles the files again with the stack of that size
+func (c *Compiler) generate(files []*file) (code []byte, errs errors) {
+	if c.stackSize == AUTO_STACK_SIZE {
+		c.reset(0)
+		c.maxStackAddress = math.MaxInt
+		c.memorySize = 0 // the stack is not counted yet
+		if code, errs := c.pass(files); len(errs) != 0 {
+			return code, errs
+		}
+		c.reset(c.stackDepth)
 	}
+	return c.pass(files)
+}
 
-	c.emitLabel(BEGIN_LABEL)
+// pass compiles the files once from the current state
+func (c *Compiler) pass(files []*file) (code []byte, errs errors) {
+	defer func() {
+		if c.failed(recover()) {
+			code, errs = nil, c.errors
+		}
+	}()
 
+	c.emitLabel(BEGIN_LABEL)
 	c.initBuiltin(c.scope)
 
-	for _, statement := range program.Statements {
-		if printAST {
-			fmt.Println(statement.String())
+	c.framed = c.frameDepth > 0
+	if c.framed {
+		c.emit(CALL, FRAMES_LABEL)
+		c.framesCall = len(c.code) - 1
+	}
+
+	for _, f := range files {
+		c.compileFile(f)
+	}
+	c.linkFrames()
+	c.emitEnd()
+	c.optimize()
+	c.shareMemory()
+	c.checkMemory()
+
+	return botlang.Format(c.code), c.errors
+}
+
+// Extend compiles the input in the global scope left by the previous call, so its names stay visible.
+// It returns type of the last statement and the register holding its value if the statement is an expression
+func (c *Compiler) Extend(input []byte) (t string, r register, errs errors) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	lexer := lexer.New(input)
+	parser := parser.New(&lexer)
+	program := parser.Parse()
+	if errors := parser.Errors(); len(errors) != 0 {
+		return "", "", errors
+	}
+
+	first := len(c.errors)
+	defer func() {
+		if c.failed(recover()) {
+			t, r, errs = "", "", c.errors[first:]
 		}
+	}()
 
-		c.compileStatement(statement)
+	if len(c.code) == 0 {
+		c.emitLabel(BEGIN_LABEL)
+		c.initBuiltin(c.scope)
 	}
 
-	if printAST {
-		fmt.Println("END")
+	_type, register := VOID, register("")
+
+	for i, statement := range program.Statements {
+		es, ok := statement.(*ast.ExpressionStatement)
+		if !ok || i != len(program.Statements)-1 {
+			c.compileStatement(statement)
+			continue
+		}
+
+		func() {
+			defer c.beginStatement(es)()
+			_type, register = c.compileExpression(es.Expression)
+			c.flushStackMemory()
+		}()
 	}
+	c.linkFrames()
+
+	return _type.String(), register, c.errors[first:]
+}
+
+// Instructions returns a copy of the code compiled so far including labels
+func (c *Compiler) Instructions() []botlang.Instruction {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.code)
+}
+
+// Warnings returns warnings of the last compilation, its code is emitted in spite of them
+func (c *Compiler) Warnings() []helper.Error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.warnings)
+}
+
+// Usage is memory taken by the program of the last compilation
+type Usage struct {
+	Stack     int // bytes reserved for the stack at the beginning of memory
+	StackUsed int // bytes of the stack used by the deepest statement
+	Memory    int // bytes up to the last variable including the stack
+	FrameCode int // commands of the routines copying frames of recursive functions, each -frames more takes the same
+}
+
+func (c *Compiler) Usage() Usage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	return c.output.Bytes(), c.errors
+	return Usage{Stack: int(c.maxStackAddress), StackUsed: c.stackDepth, Memory: int(c.memoryIndex) + 1, FrameCode: c.frameCode()}
 }
 
 func (c *Compiler) emit(op command, args ...interface{}) {
-	write := func(arg interface{}, id int) {
-		//// REMOVE THIS LATER
-		if id == 0 && op == LOAD_TO_MEM_FROM_REG {
-			c.output.WriteString("[")
-		}
-		if id == 1 && op == LOAD_TO_REG_FROM_MEM {
-			c.output.WriteString("[")
-		}
-		//// END OF REMOVE
-		switch v := arg.(type) {
-		case int:
-			c.output.WriteString(strconv.Itoa(v))
-		case int64:
-			c.output.WriteString(strconv.FormatInt(v, 10))
-		case string:
-			c.output.WriteString(v)
-		case bool:
-			if v {
-				c.output.WriteString("1")
-			} else {
-				c.output.WriteString("0")
-			}
-		default:
-			log.Fatalf("type of arg%d not handled. got=%T", id, arg)
+	signature, ok := botlang.Signature(op)
+	if !ok || len(signature) != len(args) {
+		internalError("unexpected arguments of command %q. got=%v", op, args)
+	}
+
+	operands := make([]botlang.Operand, len(args))
+	for i, arg := range args {
+		operands[i] = makeOperand(signature[i], arg, i)
+	}
+
+	c.code = append(c.code, botlang.New(op, operands...))
+	c.origins = append(c.origins, c.origin)
+}
+
+func makeOperand(kind botlang.OperandKind, arg interface{}, id int) botlang.Operand {
+	var value int
+	switch v := arg.(type) {
+	case int:
+		value = v
+	case int64:
+		value = int(v)
+	case bool:
+		if v {
+			value = BOOL_TRUE
+		} else {
+			value = BOOL_FALSE
 		}
-		//// REMOVE THIS LATER
-		if id == 0 && op == LOAD_TO_MEM_FROM_REG {
-			c.output.WriteString("]")
+	case botlang.Direction:
+		if kind == botlang.DIRECTION {
+			return botlang.Dir(v)
 		}
-		if id == 1 && op == LOAD_TO_REG_FROM_MEM {
-			c.output.WriteString("]")
+		internalError("unexpected direction as arg%d", id)
+	case string:
+		switch kind {
+		case botlang.REGISTER:
+			return botlang.Reg(v)
+		case botlang.LABEL:
+			return botlang.Lbl(v)
+		default:
+			internalError("unexpected string as arg%d. got=%q", id, v)
 		}
-		//// END OF REMOVE
+	default:
+		internalError("type of arg%d not handled. got=%T", id, arg)
 	}
 
-	c.output.WriteString(op)
-	for i, arg := range args {
-		c.output.WriteString(" ")
-		write(arg, i)
+	switch kind {
+	case botlang.VALUE:
+		return botlang.Val(value)
+	case botlang.MEMORY:
+		return botlang.Mem(value)
+	default:
+		internalError("unexpected number as arg%d. got=%d", id, value)
+		return botlang.Operand{}
 	}
-
-	c.output.WriteString("\n")
 }
 
 func (c *Compiler) emitLabel(label string) {
-	c.emit(label + ":")
+	c.code = append(c.code, botlang.NewLabel(label))
+	c.origins = append(c.origins, c.origin)
 }
 
 func (c *Compiler) compileStatement(statement ast.Statement) {
+	defer c.beginStatement(statement)()
+
 	switch stm := statement.(type) {
 	case *ast.DeclarationStatement:
 		c.compileDeclarationStatement(stm)
@@ -152,8 +347,10 @@ func (c *Compiler) compileStatement(statement ast.Statement) {
 		c.compileBreakStatement(stm)
 	case *ast.ContinueStatement:
 		c.compileContinueStatement(stm)
+	case *ast.DomainStatement:
+		c.addError(helper.MakeError(stm.Token, diagnostic.MISPLACED_STATEMENT, "Domain statement is allowed only at the beginning of a file"))
 	default:
-		log.Fatalf("type of statement is not handled. got=%T", statement)
+		internalError("type of statement is not handled. got=%T", statement)
 	}
 	c.flushStackMemory()
 }
@@ -163,35 +360,41 @@ func (c *Compiler) compileDeclarationStatement(ds *ast.DeclarationStatement) {
 
 	var_type, ok := c.findType(&ds.Var)
 	if !ok {
-		err := helper.MakeError(ds.Var.Token, fmt.Sprintf("undeclared type of variable %q", var_type.String()))
+		err := helper.MakeError(ds.Var.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared type of variable %q", var_type.String()))
 		c.addError(err)
 		return
 	}
 
 	if _type != var_type {
-		err := helper.MakeError(ds.Var.Token, fmt.Sprintf("declared variable and expression have different types. variable=%q, expression=%q",
+		err := helper.MakeError(ds.Var.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("declared variable and expression have different types. variable=%q, expression=%q",
 			var_type.String(), _type.String()))
 		c.addError(err)
 	}
 
-	if ok := c.addNewVariable(register, ds.Var.Name, var_type); !ok {
-		err := helper.MakeError(ds.Var.Token, fmt.Sprintf("redeclaration of variable %q", ds.Var.Name))
+	if ok := c.addNewVariable(register, &ds.Var, var_type, false); !ok {
+		err := helper.MakeError(ds.Var.Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of variable %q", ds.Var.Name))
+		err.Related = c.previousVariable(ds.Var.Name)
 		c.addError(err)
 	}
 }
 
-func (c *Compiler) addNewVariable(register register, name name, t Type) bool {
-	addr := c.purchaseMemoryAddress()
+func (c *Compiler) addNewVariable(register register, v *ast.Variable, t Type, constant bool) bool {
+	addr := c.purchaseVariableAddress(v.Token)
 	c.emit(LOAD_TO_MEM_FROM_REG, addr, register)
 
-	return c.scope.AddVariable(name, addr, t)
+	if !c.scope.AddVariable(v.Name, addr, t) {
+		return false
+	}
+	c.addSymbol(c.scope, variable{Name: v.Name, Addr: addr, Type: t}, constant)
+	c.defineVariable(v.Token, variable{Name: v.Name, Addr: addr, Type: t})
+	return true
 }
 
 func (c *Compiler) compileReturnStatement(rs *ast.ReturnStatement) {
 	returnType, ok := c.scope.GetReturnType()
 
 	if !ok {
-		err := helper.MakeError(rs.Token, "unexpected return statement")
+		err := helper.MakeError(rs.Token, diagnostic.MISPLACED_STATEMENT, "unexpected return statement")
 		c.addError(err)
 	}
 
@@ -202,7 +405,7 @@ func (c *Compiler) compileReturnStatement(rs *ast.ReturnStatement) {
 	}
 
 	if returnType != _type {
-		err := helper.MakeError(rs.Token, fmt.Sprintf("expected return of type=%q, got=%q",
+		err := helper.MakeError(rs.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected return of type=%q, got=%q",
 			returnType.String(), _type.String()))
 		c.addError(err)
 	}
@@ -220,23 +423,28 @@ func (c *Compiler) compileUsingStatement(us *ast.UsingStatement) {
 	switch name := us.Name.(type) {
 	case *ast.Identifier:
 		s, ok = c.scope.GetScope(name.Value)
+		if ok {
+			c.refer(name.Token, s)
+		}
 	case *ast.ScopeExpression:
 		s, ok = c.findScope(name, c.scope)
-		if !ok {
-			err := helper.MakeError(us.Token, "undeclared scope/alias expression")
-			c.addError(err)
+		if ok {
+			s, ok = s.GetScope(name.Value.Value)
+		}
+		if ok {
+			c.refer(name.Value.Token, s)
 		}
-		s, ok = s.GetScope(name.Value.Value)
 	default:
-		err := helper.MakeError(us.Token, fmt.Sprintf("expected identifier or scope expression of scope, got=%T", name))
+		err := helper.MakeError(us.Token, diagnostic.INVALID_USING, fmt.Sprintf("expected identifier or scope expression of scope, got=%T", name))
 		c.addError(err)
 	}
 
 	if !ok {
-		err := helper.MakeError(us.Token, "undeclared scope/alias expression")
+		err := helper.MakeError(us.Token, diagnostic.UNDECLARED, "undeclared scope/alias expression")
 		c.addError(err)
 	} else {
 		c.scope.UsingScope(s)
+		c.imports = append(c.imports, imported{token: us.Token, file: c.file, name: us.Name.String(), owner: c.scope, scope: s})
 	}
 }
 
@@ -245,12 +453,19 @@ func (c *Compiler) compileAssignmentStatement(as *ast.AssignmentStatement) {
 	variable, ok := c.scope.GetVariable(as.Name.Value)
 
 	if !ok {
-		err := helper.MakeError(as.Name.Token, fmt.Sprintf("assigning to undeclared variable %q", as.Name.Value))
+		err := helper.MakeError(as.Name.Token, diagnostic.UNDECLARED, fmt.Sprintf("assigning to undeclared variable %q", as.Name.Value))
 		c.addError(err)
+	} else {
+		c.refer(as.Name.Token, variable.Addr)
+		if _, constant := c.constants[variable.Addr]; constant {
+			err := helper.MakeError(as.Name.Token, diagnostic.CONSTANT_ASSIGNMENT, fmt.Sprintf("assigning to constant %q", as.Name.Value))
+			err.Related = c.previous(variable.Addr)
+			c.addError(err)
+		}
 	}
 
 	if variable.Type != _type {
-		err := helper.MakeError(as.Name.Token, fmt.Sprintf("expected expression of type=%q, got=%q",
+		err := helper.MakeError(as.Name.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected expression of type=%q, got=%q",
 			variable.Type.String(), _type.String()))
 		c.addError(err)
 	}
@@ -261,10 +476,14 @@ func (c *Compiler) compileAssignmentStatement(as *ast.AssignmentStatement) {
 func (c *Compiler) compileScopeStatement(ss *ast.ScopeStatement) {
 	c.enterNamedScope(ss.Name.Value)
 	defer c.leaveScope()
+	defer c.enterScopeOrigin(ss.Name.Value)()
 
 	if ok := c.scope.GetParent().AddScope(c.scope); !ok {
-		err := helper.MakeError(ss.Name.Token, fmt.Sprintf("redeclaration of scope/alias %q", c.scope.name))
+		err := helper.MakeError(ss.Name.Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of scope/alias %q", c.scope.name))
+		err.Related = c.previousScope(c.scope.GetParent(), c.scope.name)
 		c.addError(err)
+	} else {
+		c.define(c.scope, SCOPE_DEFINITION, scopePath(c.scope), "", "Scope "+ss.Name.Value, ss.Name.Token)
 	}
 
 	for _, statement := range ss.Body.Statements {
@@ -278,16 +497,14 @@ func (c *Compiler) compileWhileStatement(ws *ast.WhileStatement) {
 	end := c.getUniqueLabel()
 
 	c.emitLabel(loop)
-	_type, register := c.compileExpression(ws.Condition)
+	c.warnNeverRuns(ws.Condition, ws.Token, "While")
+	_type := c.compileCondition(ws.Condition, end, false)
 
 	if _type != builtIn(Bool) {
-		err := helper.MakeError(ws.Token, fmt.Sprintf("expected boolean condition in while loop, got %q", _type.String()))
+		err := helper.MakeError(ws.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected boolean condition in while loop, got %q", _type.String()))
 		c.addError(err)
 	}
 
-	c.emit(COMPARE_WITH_VALUE, register, BOOL_TRUE)
-	c.emit(JUMP_IF_NOT_EQUAL, end)
-
 	c.enterScope()
 	defer c.leaveScope()
 
@@ -307,32 +524,39 @@ func (c *Compiler) compileAliasStatement(as *ast.AliasStatement) {
 	defer c.leaveScope()
 
 	if ok := c.scope.GetParent().AddScope(c.scope); !ok {
-		err := helper.MakeError(as.Token, fmt.Sprintf("redeclaration of scope/alias %q", c.scope.name))
+		err := helper.MakeError(as.Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of scope/alias %q", c.scope.name))
+		err.Related = c.previousScope(c.scope.GetParent(), c.scope.name)
 		c.addError(err)
 	}
 
 	t, ok := as.Var.Type.(*ast.Identifier)
 	if !ok || (t.Value != Bool && t.Value != Int) {
-		err := helper.MakeError(as.Token, fmt.Sprintf("expected alias to be primitive type(Bool, Int), got %q", as.Var.Type))
+		err := helper.MakeError(as.Token, diagnostic.INVALID_ALIAS, fmt.Sprintf("expected alias to be primitive type(Bool, Int), got %q", as.Var.Type))
 		c.addError(err)
 	} else {
+		c.define(c.scope, ALIAS_DEFINITION, qualify(c.scope.GetParent(), as.Var.Name), t.Value, "Alias "+as.Var.Name+"::"+t.Value, as.Var.Token)
+
 		for _, val := range as.Values {
 			switch v := val.Value.(type) {
 			case *ast.IntegralLiteral, *ast.BooleanLiteral:
 				_type, register := c.compileExpression(val.Value)
 
 				if _type.Name != t.Value {
-					err := helper.MakeError(val.Var.Token, fmt.Sprintf("declared alias and expression have different types. alias=%q, expression=%q",
+					err := helper.MakeError(val.Var.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("declared alias and expression have different types. alias=%q, expression=%q",
 						as.Var.Type, _type.String()))
 					c.addError(err)
 				}
 
-				if ok := c.addNewVariable(register, val.Var.Name, Type{Scope: c.scope.GetParent(), Name: as.Var.Name}); !ok {
-					err := helper.MakeError(val.Var.Token, fmt.Sprintf("redeclaration of alias %q", val.Var.Name))
+				if ok := c.addNewVariable(register, &val.Var, Type{Scope: c.scope.GetParent(), Name: as.Var.Name}, true); !ok {
+					err := helper.MakeError(val.Var.Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of alias %q", val.Var.Name))
+					err.Related = c.previousVariable(val.Var.Name)
 					c.addError(err)
+				} else if value, ok := c.peek(val.Value); ok {
+					variable, _ := c.scope.getLocalVariable(val.Var.Name)
+					c.constants[variable.Addr] = value.Value
 				}
 			default:
-				err := helper.MakeError(val.Var.Token, fmt.Sprintf("expected literal expression, got %T", v))
+				err := helper.MakeError(val.Var.Token, diagnostic.INVALID_ALIAS, fmt.Sprintf("expected literal expression, got %T", v))
 				c.addError(err)
 			}
 		}
@@ -347,13 +571,14 @@ func (c *Compiler) compileFunctionStatement(fs *ast.FunctionStatement) {
 		_type, ok = c.findType(&fs.Var)
 
 		if !ok {
-			err := helper.MakeError(fs.Var.Token, "undeclared function type")
+			err := helper.MakeError(fs.Var.Token, diagnostic.UNDECLARED, "undeclared function type")
 			c.addError(err)
 		}
 	}
 
 	start := c.getUniqueLabel()
 	end := c.getUniqueLabel()
+	defer c.enterFrame(fs.Var.Name, start, fs.Var.Token)()
 
 	var arguments []variable
 	if fs.Parameters != nil {
@@ -365,16 +590,16 @@ func (c *Compiler) compileFunctionStatement(fs *ast.FunctionStatement) {
 				_var.Name = parameter.Name
 				_type, ok := c.findType(&parameter)
 				if !ok {
-					err := helper.MakeError(parameter.Token, "undeclared parameter type")
+					err := helper.MakeError(parameter.Token, diagnostic.UNDECLARED, "undeclared parameter type")
 					c.addError(err)
 					return
 				}
 				_var.Type = _type
-				_var.Addr = c.purchaseMemoryAddress()
+				_var.Addr = c.purchaseMemoryAddress(parameter.Token)
 
 				arguments[i] = _var
 			} else {
-				err := helper.MakeError(parameter.Token, "undeclared parameter type")
+				err := helper.MakeError(parameter.Token, diagnostic.UNDECLARED, "undeclared parameter type")
 				c.addError(err)
 				return
 			}
@@ -385,20 +610,30 @@ func (c *Compiler) compileFunctionStatement(fs *ast.FunctionStatement) {
 
 	ok := c.scope.AddFunction(fs.Var.Name, start, _type, arguments)
 	if !ok {
-		err := helper.MakeError(fs.Token, fmt.Sprintf("redeclaration of function %q", fs.Var.Name))
+		err := helper.MakeError(fs.Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of function %q", fs.Var.Name))
+		if previous, ok := c.scope.getLocalFunction(fs.Var.Name); ok {
+			err.Related = c.previous(previous.Label)
+		}
 		c.addError(err)
 		return
 	}
+	c.defineFunction(fs.Var.Token, function{Name: fs.Var.Name, Label: start, Type: _type, Arguments: arguments})
 
 	c.enterNamedScope(fs.Var.Name)
 	defer c.leaveScope()
+	defer c.enterFunctionOrigin(fs.Var.Name)()
 	c.scope.returnType = _type
+	c.frame.scope = c.scope
 
 	for i, arg := range arguments {
 		ok = c.scope.AddVariable(arg.Name, arg.Addr, arg.Type)
 		if !ok {
-			err := helper.MakeError(fs.Parameters[i].Token, fmt.Sprintf("redeclaration of an argument %q", arg.Name))
+			err := helper.MakeError(fs.Parameters[i].Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of an argument %q", arg.Name))
+			err.Related = c.previousVariable(arg.Name)
 			c.addError(err)
+		} else {
+			c.addSymbol(c.scope, arg, false)
+			c.defineVariable(fs.Parameters[i].Token, arg)
 		}
 	}
 
@@ -418,7 +653,7 @@ func (c *Compiler) compileFunctionStatement(fs *ast.FunctionStatement) {
 		if _type == VOID {
 			c.emit(RETURN)
 		} else {
-			err := helper.MakeError(fs.Token, fmt.Sprintf("expected return statement in function %q", fs.Var.Name))
+			err := helper.MakeError(fs.Token, diagnostic.MISSING_RETURN, fmt.Sprintf("expected return statement in function %q", fs.Var.Name))
 			c.addError(err)
 		}
 	}
@@ -430,16 +665,14 @@ func (c *Compiler) compileIfStatement(is *ast.IfStatement) {
 	elifOrElse := c.getUniqueLabel()
 	end := c.getUniqueLabel()
 
-	_type, register := c.compileExpression(is.Condition)
+	c.warnNeverRuns(is.Condition, is.Token, "If")
+	_type := c.compileCondition(is.Condition, elifOrElse, false)
 
 	if _type != builtIn(Bool) {
-		err := helper.MakeError(is.Token, fmt.Sprintf("expected boolean condition in if statement, got %q", _type.String()))
+		err := helper.MakeError(is.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected boolean condition in if statement, got %q", _type.String()))
 		c.addError(err)
 	}
 
-	c.emit(COMPARE_WITH_VALUE, register, BOOL_TRUE)
-	c.emit(JUMP_IF_NOT_EQUAL, elifOrElse)
-
 	c.enterScope()
 
 	for _, statement := range is.Consequence.Statements {
@@ -467,37 +700,37 @@ func (c *Compiler) compileIfStatement(is *ast.IfStatement) {
 }
 
 func (c *Compiler) compileBreakStatement(bs *ast.BreakStatement) {
-	_, begin, ok := c.scope.GetLoopEndAndBegin()
+	end, _, ok := c.scope.GetLoopEndAndBegin()
 	if !ok {
-		err := helper.MakeError(bs.Token, "unexpected Break statement")
+		err := helper.MakeError(bs.Token, diagnostic.MISPLACED_STATEMENT, "unexpected Break statement")
 		c.addError(err)
 	}
 
-	c.emit(JUMP, begin)
+	c.emit(JUMP, end)
 }
 
 func (c *Compiler) compileContinueStatement(bs *ast.ContinueStatement) {
-	end, _, ok := c.scope.GetLoopEndAndBegin()
+	_, begin, ok := c.scope.GetLoopEndAndBegin()
 	if !ok {
-		err := helper.MakeError(bs.Token, "unexpected Continue statement")
+		err := helper.MakeError(bs.Token, diagnostic.MISPLACED_STATEMENT, "unexpected Continue statement")
 		c.addError(err)
 	}
 
-	c.emit(JUMP, end)
+	c.emit(JUMP, begin)
 }
 
 func (c *Compiler) compileElifStatement(es *ast.ElifStatement, end string) {
+	defer c.beginStatement(es)()
+
 	nextElif := c.getUniqueLabel()
-	_type, register := c.compileExpression(es.Condition)
+	c.warnNeverRuns(es.Condition, es.Token, "Elif")
+	_type := c.compileCondition(es.Condition, nextElif, false)
 
 	if _type != builtIn(Bool) {
-		err := helper.MakeError(es.Token, fmt.Sprintf("expected boolean condition in elif statement, got %q", _type.String()))
+		err := helper.MakeError(es.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected boolean condition in elif statement, got %q", _type.String()))
 		c.addError(err)
 	}
 
-	c.emit(COMPARE_WITH_VALUE, register, BOOL_TRUE)
-	c.emit(JUMP_IF_NOT_EQUAL, nextElif)
-
 	c.enterScope()
 	defer c.leaveScope()
 
@@ -509,6 +742,8 @@ func (c *Compiler) compileElifStatement(es *ast.ElifStatement, end string) {
 }
 
 func (c *Compiler) compileExpression(statement ast.Expression) (Type, register) {
+	defer c.locate(statement)()
+
 	switch exp := statement.(type) {
 	case *ast.IntegralLiteral:
 		return c.compileIntegralLiteral(exp)
@@ -525,13 +760,13 @@ func (c *Compiler) compileExpression(statement ast.Expression) (Type, register)
 	case *ast.ScopeExpression:
 		scope, ok := c.findScope(exp, c.scope)
 		if !ok {
-			err := helper.MakeError(exp.Token, fmt.Sprintf("undeclared scope/alias %q", exp.Scope))
+			err := helper.MakeError(exp.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared scope/alias %q", exp.Scope))
 			c.addError(err)
 		} else {
 			return c.compileIdentifierFromScope(exp.Value, scope)
 		}
 	default:
-		log.Fatalf("type of expression is not handled. got=%T", exp)
+		internalError("type of expression is not handled. got=%T", exp)
 	}
 	return VOID, ""
 }
@@ -552,13 +787,16 @@ func (c *Compiler) compileBooleanLiteral(expression *ast.BooleanLiteral) (Type,
 }
 
 func (c *Compiler) compilePrefixExpression(expression *ast.PrefixExpression) (Type, register) {
+	if value, ok := c.fold(expression); ok {
+		return c.compileConstant(value)
+	}
 
 	_type, register := c.compileExpression(expression.Right)
 
 	switch expression.Operator {
 	case tokens.NOT:
 		if _type != builtIn(Bool) {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected boolean expression. got=%q", _type.String()))
+			err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected boolean expression. got=%q", _type.String()))
 			c.addError(err)
 		}
 
@@ -579,37 +817,32 @@ func (c *Compiler) compilePrefixExpression(expression *ast.PrefixExpression) (Ty
 		c.emitLabel(end)
 	case tokens.NEGATION:
 		if _type != builtIn(Int) {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected integer expression. got=%q", _type.String()))
+			err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected integer expression. got=%q", _type.String()))
 			c.addError(err)
 		}
 		c.emit(NEGATE, register)
 
 		return builtIn(Int), register
 	default:
-		log.Fatalf("type of prefix is not handled. got=%q", expression.Operator)
+		internalError("type of prefix is not handled. got=%q", expression.Operator)
 	}
 
 	return builtIn(Bool), register
 }
 
 func (c *Compiler) compileInfixExpression(expression *ast.InfixExpression) (Type, register) {
-
-	leftType, leftRegister := c.compileExpression(expression.Left)
-	buffer := c.purchaseStackMemoryAddress()
-	c.emit(LOAD_TO_MEM_FROM_REG, buffer, leftRegister)
-
-	rightType, rightRegister := c.compileExpression(expression.Right)
-
-	if rightRegister != BX {
-		c.emit(LOAD_TO_REG_FROM_REG, BX, rightRegister)
-		rightRegister = BX
+	if value, ok := c.fold(expression); ok {
+		return c.compileConstant(value)
+	}
+	if expression.Operator == tokens.AND || expression.Operator == tokens.OR {
+		return c.compileLogicalExpression(expression)
 	}
 
-	c.emit(LOAD_TO_REG_FROM_MEM, AX, buffer)
-	leftRegister = AX
+	leftType, rightType := c.compileOperands(expression)
+	leftRegister, rightRegister := AX, BX
 
-	emitComparison := func(jump command, typeErrorHandler func()) (Type, register) {
-		typeErrorHandler()
+	if jump, ok := comparisonJumps[expression.Operator]; ok {
+		c.checkComparison(expression, leftType, rightType)
 
 		end := c.getUniqueLabel()
 		True := c.getUniqueLabel()
@@ -626,25 +859,9 @@ func (c *Compiler) compileInfixExpression(expression *ast.InfixExpression) (Type
 		return builtIn(Bool), AX
 	}
 
-	handleIntegers := func() {
-		if leftType != builtIn(Int) || rightType != builtIn(Int) {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected integer expression(s). got left=%q and right=%q",
-				leftType.String(), rightType.String()))
-			c.addError(err)
-		}
-	}
-
-	handleSameTypes := func() {
-		if leftType != rightType {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected expression(s) of the same type. got left=%q and right=%q",
-				leftType.String(), rightType.String()))
-			c.addError(err)
-		}
-	}
-
 	emitArithmetics := func(op command) (Type, register) {
 		if leftType != builtIn(Int) || rightType != builtIn(Int) {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected integer expression(s). got left=%q and right=%q",
+			err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected integer expression(s). got left=%q and right=%q",
 				leftType.String(), rightType.String()))
 			c.addError(err)
 		}
@@ -653,65 +870,14 @@ func (c *Compiler) compileInfixExpression(expression *ast.InfixExpression) (Type
 		return builtIn(Int), leftRegister
 	}
 
-	switch expression.Operator {
-	case tokens.LT:
-		return emitComparison(JUMP_IF_LESS_THAN, handleIntegers)
-	case tokens.LE:
-		return emitComparison(JUMP_IF_LESS_EQUAL_THAN, handleIntegers)
-	case tokens.GT:
-		return emitComparison(JUMP_IF_GREATER_THAN, handleIntegers)
-	case tokens.GE:
-		return emitComparison(JUMP_IF_GREATER_EQUAL_THAN, handleIntegers)
-	case tokens.NEQUAL:
-		return emitComparison(JUMP_IF_NOT_EQUAL, handleSameTypes)
-	case tokens.EQUAL:
-		return emitComparison(JUMP_IF_EQUAL, handleSameTypes)
-	case tokens.AND:
-		if leftType != builtIn(Bool) || rightType != builtIn(Bool) {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected bool expression(s). got left=%q and right=%q",
-				leftType.String(), rightType.String()))
-			c.addError(err)
-		}
-
-		end := c.getUniqueLabel()
-		False := c.getUniqueLabel()
-
-		c.emit(COMPARE_WITH_VALUE, leftRegister, BOOL_FALSE)
-		c.emit(JUMP_IF_EQUAL, False)
-		c.emit(COMPARE_WITH_VALUE, rightRegister, BOOL_FALSE)
-		c.emit(JUMP_IF_EQUAL, False)
-
-		c.emit(LOAD_TO_REG_FROM_VAL, AX, BOOL_TRUE)
-		c.emit(JUMP, end)
-
-		c.emitLabel(False)
-		c.emit(LOAD_TO_REG_FROM_VAL, AX, BOOL_FALSE)
-
-		c.emitLabel(end)
-		return builtIn(Bool), AX
-	case tokens.OR:
-		if leftType != builtIn(Bool) || rightType != builtIn(Bool) {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("expected bool expression(s). got left=%q and right=%q",
-				leftType.String(), rightType.String()))
+	if expression.Operator == tokens.DIVISION || expression.Operator == tokens.MODULO {
+		if divisor, ok := c.peek(expression.Right); ok && divisor.Type == builtIn(Int) && divisor.Value == 0 {
+			err := helper.MakeError(expression.Token, diagnostic.DIVISION_BY_ZERO, fmt.Sprintf("%s by constant zero", expression.Operator))
 			c.addError(err)
 		}
+	}
 
-		end := c.getUniqueLabel()
-		True := c.getUniqueLabel()
-
-		c.emit(COMPARE_WITH_VALUE, leftRegister, BOOL_TRUE)
-		c.emit(JUMP_IF_EQUAL, True)
-		c.emit(COMPARE_WITH_VALUE, rightRegister, BOOL_TRUE)
-		c.emit(JUMP_IF_EQUAL, True)
-
-		c.emit(LOAD_TO_REG_FROM_VAL, AX, BOOL_FALSE)
-		c.emit(JUMP, end)
-
-		c.emitLabel(True)
-		c.emit(LOAD_TO_REG_FROM_VAL, AX, BOOL_TRUE)
-
-		c.emitLabel(end)
-		return builtIn(Bool), AX
+	switch expression.Operator {
 	case tokens.ADDITION:
 		return emitArithmetics(ADD)
 	case tokens.NEGATION:
@@ -725,11 +891,78 @@ func (c *Compiler) compileInfixExpression(expression *ast.InfixExpression) (Type
 	case tokens.POWER:
 		return emitArithmetics(POWER)
 	default:
-		log.Fatalf("type of infix expression is not handled. got=%q", expression.Operator)
+		internalError("type of infix expression is not handled. got=%q", expression.Operator)
 		return VOID, ""
 	}
 }
 
+// compileOperands leaves the value of the left operand in AX and the value of the right one in BX
+func (c *Compiler) compileOperands(expression *ast.InfixExpression) (leftType Type, rightType Type) {
+	leftType, leftRegister := c.compileExpression(expression.Left)
+	buffer := c.purchaseStackMemoryAddress(expression.Token)
+	c.emit(LOAD_TO_MEM_FROM_REG, buffer, leftRegister)
+
+	rightType, rightRegister := c.compileExpression(expression.Right)
+
+	if rightRegister != BX {
+		c.emit(LOAD_TO_REG_FROM_REG, BX, rightRegister)
+	}
+
+	c.emit(LOAD_TO_REG_FROM_MEM, AX, buffer)
+	return leftType, rightType
+}
+
+// checkComparison reports operands of an ordering other than integers and operands of == and != of different types
+func (c *Compiler) checkComparison(expression *ast.InfixExpression, leftType Type, rightType Type) {
+	switch {
+	case expression.Operator == tokens.EQUAL || expression.Operator == tokens.NEQUAL:
+		if leftType != rightType {
+			err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected expression(s) of the same type. got left=%q and right=%q",
+				leftType.String(), rightType.String()))
+			c.addError(err)
+		}
+	case leftType != builtIn(Int) || rightType != builtIn(Int):
+		err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected integer expression(s). got left=%q and right=%q",
+			leftType.String(), rightType.String()))
+		c.addError(err)
+	}
+}
+
+// compileLogicalExpression evaluates the right operand of And and Or only if the left one doesn't decide the result,
+// e.g. `bot::IsEmpty$ dir::front And Ready` doesn't call Ready if the cell in the front is occupied
+func (c *Compiler) compileLogicalExpression(expression *ast.InfixExpression) (Type, register) {
+	// the left operand decides the result if it equals to the result
+	decided := BOOL_FALSE
+	if expression.Operator == tokens.OR {
+		decided = BOOL_TRUE
+	}
+
+	end := c.getUniqueLabel()
+	short := c.getUniqueLabel()
+
+	leftType, leftRegister := c.compileExpression(expression.Left)
+	c.emit(COMPARE_WITH_VALUE, leftRegister, decided)
+	c.emit(JUMP_IF_EQUAL, short)
+
+	rightType, rightRegister := c.compileExpression(expression.Right)
+	if rightRegister != AX {
+		c.emit(LOAD_TO_REG_FROM_REG, AX, rightRegister)
+	}
+	c.emit(JUMP, end)
+
+	c.emitLabel(short)
+	c.emit(LOAD_TO_REG_FROM_VAL, AX, decided)
+
+	c.emitLabel(end)
+
+	if leftType != builtIn(Bool) || rightType != builtIn(Bool) {
+		err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected bool expression(s). got left=%q and right=%q",
+			leftType.String(), rightType.String()))
+		c.addError(err)
+	}
+	return builtIn(Bool), AX
+}
+
 func (c *Compiler) compileIdentifier(expression *ast.Identifier) (Type, register) {
 	return c.compileIdentifierFromScope(expression, c.scope)
 }
@@ -737,11 +970,12 @@ func (c *Compiler) compileIdentifier(expression *ast.Identifier) (Type, register
 func (c *Compiler) compileIdentifierFromScope(expression *ast.Identifier, scope *scope) (Type, register) {
 	if scope != nil {
 		if variable, ok := scope.GetVariable(expression.Value); ok {
+			c.refer(expression.Token, variable.Addr)
 			c.emit(LOAD_TO_REG_FROM_MEM, AX, variable.Addr)
 			return variable.Type, AX
 		}
 	}
-	err := helper.MakeError(expression.Token, fmt.Sprintf("undeclared identifier. got=%q", expression))
+	err := helper.MakeError(expression.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared identifier. got=%q", expression))
 	c.addError(err)
 	return VOID, ""
 }
@@ -749,71 +983,135 @@ func (c *Compiler) compileIdentifierFromScope(expression *ast.Identifier, scope
 func (c *Compiler) compileCallExpression(expression *ast.CallExpression) (Type, register) {
 	var function name
 	var scope *scope
+	var token tokens.Token
 
 	switch exp := expression.Function.(type) {
 	case *ast.ScopeExpression:
 		s, ok := c.findScope(exp, c.scope)
 		if !ok {
-			err := helper.MakeError(exp.Token, fmt.Sprintf("undeclared scope %q", exp.Value.Value))
+			err := helper.MakeError(exp.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared scope %q", exp.Value.Value))
 			c.addError(err)
 			return VOID, ""
 		}
 		function = exp.Value.Value
 		scope = s
+		token = exp.Value.Token
 	case *ast.Identifier:
 		function = exp.Value
 		scope = c.scope
+		token = exp.Token
 	default:
-		log.Fatalf("type of call expression is not handled. got=%q", expression.Function)
+		internalError("type of call expression is not handled. got=%q", expression.Function)
 		return VOID, ""
 	}
 
 	fun, ok := scope.GetFunction(function)
 	if !ok {
-		err := helper.MakeError(expression.Token, fmt.Sprintf("undeclared function %q", function))
+		err := helper.MakeError(expression.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared function %q", function))
 		c.addError(err)
 		return VOID, ""
 	}
 
+	c.refer(token, fun.Label)
+
 	if len(fun.Arguments) != len(expression.Arguments) {
-		err := helper.MakeError(expression.Token, fmt.Sprintf("unexpected number of arguments expected=%d, got=%d", len(fun.Arguments), len(expression.Arguments)))
+		err := helper.MakeError(expression.Token, diagnostic.ARGUMENT_COUNT, fmt.Sprintf("unexpected number of arguments expected=%d, got=%d", len(fun.Arguments), len(expression.Arguments)))
 		c.addError(err)
 		return VOID, ""
 	}
 
+	if fun.IsBuiltin && fun.Label == ASSERT {
+		return c.compileAssert(expression)
+	}
 	if fun.IsBuiltin {
 		return c.compileBuiltin(expression, function)
 	}
 
+	// calls are listed in the order of the source, arguments may call functions as well
+	c.calls = append(c.calls, call{caller: c.frame, callee: c.frames[fun.Label], token: expression.Token, push: c.emitFrameCall(PUSH_SUFFIX)})
+	listed := len(c.calls) - 1
+
+	// an argument waits on the stack if a following one calls a function which may overwrite the parameters
+	var buffers, parameters []address
 	for i := range len(fun.Arguments) {
 		arg := fun.Arguments[i]
 		passedArg := expression.Arguments[i]
 		t, register := c.compileExpression(passedArg)
 
 		if t != arg.Type {
-			err := helper.MakeError(expression.Token, fmt.Sprintf("unexpected type of an argument expected %q, got %q", t.String(), arg.Type.String()))
+			err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("unexpected type of an argument expected %q, got %q", t.String(), arg.Type.String()))
 			c.addError(err)
 		}
 
-		c.emit(LOAD_TO_MEM_FROM_REG, arg.Addr, register)
+		if slices.ContainsFunc(expression.Arguments[i+1:], c.callsFunction) {
+			buffer := c.purchaseStackMemoryAddress(expression.Token)
+			c.emit(LOAD_TO_MEM_FROM_REG, buffer, register)
+			buffers, parameters = append(buffers, buffer), append(parameters, arg.Addr)
+		} else {
+			c.emit(LOAD_TO_MEM_FROM_REG, arg.Addr, register)
+		}
+	}
+	for i, buffer := range buffers {
+		c.emit(LOAD_TO_REG_FROM_MEM, AX, buffer)
+		c.emit(LOAD_TO_MEM_FROM_REG, parameters[i], AX)
 	}
 
 	c.emit(CALL, fun.Label)
+	c.calls[listed].pop = c.emitFrameCall(POP_SUFFIX)
 
 	return fun.Type, RETURN_REGISTER
 }
 
+// findFunction finds the called function recording nothing
+func (c *Compiler) findFunction(expression *ast.CallExpression) (function, bool) {
+	errors, references := len(c.errors), len(c.references)
+	defer func() { c.errors, c.references = c.errors[:errors], c.references[:references] }()
+
+	switch exp := expression.Function.(type) {
+	case *ast.ScopeExpression:
+		if s, ok := c.findScope(exp, c.scope); ok {
+			return s.GetFunction(exp.Value.Value)
+		}
+	case *ast.Identifier:
+		return c.scope.GetFunction(exp.Value)
+	}
+	return function{}, false
+}
+
+// callsFunction tells if the expression may call a function of the program, builtins are never called
+func (c *Compiler) callsFunction(expression ast.Expression) bool {
+	switch exp := expression.(type) {
+	case *ast.PrefixExpression:
+		return c.callsFunction(exp.Right)
+	case *ast.InfixExpression:
+		return c.callsFunction(exp.Left) || c.callsFunction(exp.Right)
+	case *ast.CallExpression:
+		fun, ok := c.findFunction(exp)
+		return !ok || !fun.IsBuiltin || slices.ContainsFunc(exp.Arguments, c.callsFunction)
+	default:
+		return false
+	}
+}
+
 func (c *Compiler) findScope(expression *ast.ScopeExpression, scope *scope) (*scope, bool) {
 	switch exp := expression.Scope.(type) {
 	case *ast.ScopeExpression:
 		s, ok := c.findScope(exp, scope)
 		if !ok {
-			err := helper.MakeError(exp.Token, fmt.Sprintf("undeclared scope/alias %q", exp.Value.Value))
+			err := helper.MakeError(exp.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared scope/alias %q", exp.Value.Value))
 			c.addError(err)
 		}
-		return s.GetScope(exp.Value.Value)
+		s, ok = s.GetScope(exp.Value.Value)
+		if ok {
+			c.refer(exp.Value.Token, s)
+		}
+		return s, ok
 	case *ast.Identifier:
-		return scope.GetScope(exp.Value)
+		s, ok := scope.GetScope(exp.Value)
+		if ok {
+			c.refer(exp.Token, s)
+		}
+		return s, ok
 	default:
 		return scope, false
 	}
@@ -824,11 +1122,14 @@ func (c *Compiler) findType(expression *ast.Variable) (Type, bool) {
 	case *ast.ScopeExpression:
 		s, ok := c.findScope(exp, c.scope)
 		if !ok {
-			err := helper.MakeError(exp.Token, fmt.Sprintf("undeclared scope/alias %q", exp.Value.Value))
+			err := helper.MakeError(exp.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared scope/alias %q", exp.Value.Value))
 			c.addError(err)
 			return VOID, false
 		}
 
+		if alias, ok := s.GetScope(helper.FirstToLowerCase(exp.Value.Value)); ok {
+			c.refer(exp.Value.Token, alias)
+		}
 		return Type{Scope: s, Name: exp.Value.Value}, true
 	case *ast.Identifier:
 		if slices.Contains(BUILTIN_TYPES, exp.Value) {
@@ -836,10 +1137,11 @@ func (c *Compiler) findType(expression *ast.Variable) (Type, bool) {
 		}
 		s, ok := c.scope.GetScope(helper.FirstToLowerCase(exp.Value))
 		if !ok {
-			err := helper.MakeError(exp.Token, fmt.Sprintf("undeclared type %q", exp.Value))
+			err := helper.MakeError(exp.Token, diagnostic.UNDECLARED, fmt.Sprintf("undeclared type %q", exp.Value))
 			c.addError(err)
 			return VOID, false
 		}
+		c.refer(exp.Token, s)
 
 		return Type{Scope: s.GetParent(), Name: exp.Value}, true
 	default:
@@ -847,21 +1149,37 @@ func (c *Compiler) findType(expression *ast.Variable) (Type, bool) {
 	}
 }
 
-func (c *Compiler) purchaseMemoryAddress() address {
-	c.memoryIndex++
-	return c.memoryIndex
-}
-
-func (c *Compiler) purchaseStackMemoryAddress() address {
+// purchaseStackMemoryAddress takes the next byte of the stack for the value of the expression, a function takes it
+// from its own stack in memory. The first expression exceeding the stack is reported when the stack is flushed
+func (c *Compiler) purchaseStackMemoryAddress(expression tokens.Token) address {
 	c.stackMemoryIndex++
-	if c.stackMemoryIndex >= c.maxStackAddress {
-		log.Fatalf("Stack overflow, StackSize=%d", c.maxStackAddress)
+	if f := c.frame; f != nil {
+		for len(f.stack) <= c.stackMemoryIndex {
+			addr := c.purchaseMemoryAddress(expression)
+			c.allocate(addr, 1, f.scope, STACK_ALLOCATION)
+			f.stack = append(f.stack, addr)
+		}
+		return f.stack[c.stackMemoryIndex]
+	}
+
+	c.stackPeak = max(c.stackPeak, c.stackMemoryIndex)
+	c.stackDepth = max(c.stackDepth, int(c.stackMemoryIndex)+1)
+
+	if c.stackMemoryIndex >= c.maxStackAddress && c.overflow == nil {
+		c.overflow = &expression
 	}
 	return c.stackMemoryIndex
 }
 
 func (c *Compiler) flushStackMemory() {
+	if c.overflow != nil {
+		err := helper.MakeError(*c.overflow, diagnostic.STACK_OVERFLOW, fmt.Sprintf("stack overflow, the statement needs %d bytes of stack, StackSize=%d",
+			c.stackPeak+1, c.maxStackAddress))
+		c.addError(err)
+		c.overflow = nil
+	}
 	c.stackMemoryIndex = -1
+	c.stackPeak = -1
 }
 
 func (c *Compiler) getUniqueLabel() string {
@@ -869,10 +1187,37 @@ func (c *Compiler) getUniqueLabel() string {
 	return "lbl_" + c.lastLabel
 }
 
+// internalError stops the compilation because of a bug of the compiler or an exceeded limit,
+// the error is reported at the statement being compiled instead of crashing the process
+func internalError(format string, args ...any) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+// failed reports the value returned by recover as an error of compilation if there has been a panic
+func (c *Compiler) failed(r any) bool {
+	if r == nil {
+		return false
+	}
+
+	c.addError(helper.MakeError(c.located, diagnostic.INTERNAL_ERROR, fmt.Sprintf("internal compiler error: %v", r)))
+	return true
+}
+
 func (c *Compiler) addError(error helper.Error) {
+	if error.File == "" {
+		error.File = c.file
+	}
 	c.errors = append(c.errors, error)
 }
 
+func (c *Compiler) addWarning(warning helper.Error) {
+	if warning.File == "" {
+		warning.File = c.file
+	}
+	warning.Severity = helper.WARNING
+	c.warnings = append(c.warnings, warning)
+}
+
 func (c *Compiler) enterNamedScope(name name) {
 	scope := newScope(name)
 	scope.SetParent(c.scope)
@@ -883,12 +1228,18 @@ func (c *Compiler) enterScope() {
 	c.enterNamedScope("")
 }
 
+// leaveScope frees variables of a block, variables of named scopes, aliases and functions keep their memory
 func (c *Compiler) leaveScope() {
+	c.scopeEnds[c.scope] = len(c.code)
+	if c.scope.name == "" {
+		c.freeVariables(c.scope)
+	}
+
 	parent := c.scope.GetParent()
 	if parent != nil {
 		c.scope = parent
 	} else {
-		log.Fatal("leaving global scope")
+		internalError("leaving global scope")
 	}
 }
 
@@ -1,11 +1,18 @@
 package compiler_test
 
 import (
+	"NiLang/src/botlang"
 	"NiLang/src/compiler"
+	"NiLang/src/diagnostic"
 	"NiLang/src/helper"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -30,7 +37,7 @@ func TestSmoke1(t *testing.T) {
 	}
 
 	c := compiler.New(stackSize)
-	_, errors := c.Compile(input, true)
+	_, errors := c.Compile(input)
 	if len(errors) != 0 {
 		for _, err := range errors {
 			helper.PrintError(err, input)
@@ -39,6 +46,40 @@ func TestSmoke1(t *testing.T) {
 	}
 }
 
+func TestRoundTrip(t *testing.T) {
+
+	file, err := os.Open("bot.nil")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	input, err := io.ReadAll(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c := compiler.New(stackSize)
+	code, errors := c.Compile(input)
+	if len(errors) != 0 {
+		t.Fatalf("Failed to compile code")
+	}
+
+	instructions, err := botlang.Parse(code)
+	if err != nil {
+		t.Fatalf("Failed to parse compiled code: %s", err)
+	}
+
+	if output := botlang.Format(instructions); string(output) != string(code) {
+		t.Fatalf("Compiled code doesn't survive round trip through botlang.Parse and botlang.Format")
+	}
+}
+
 func TestFailToCompileFunctionWithoutReturn(t *testing.T) {
 
 	input := []byte(`
@@ -47,7 +88,7 @@ Fun X::Int:
 Int x = X`)
 
 	c := compiler.New(stackSize)
-	_, errors := c.Compile(input, true)
+	_, errors := c.Compile(input)
 	if len(errors) == 0 {
 		t.Fatalf("Successfully compiled ill-formed code")
 	}
@@ -66,7 +107,7 @@ Fun X::Int:
 Int x = X`)
 
 	c := compiler.New(stackSize)
-	_, errors := c.Compile(input, true)
+	_, errors := c.Compile(input)
 	if len(errors) == 0 {
 		t.Fatalf("Successfully compiled ill-formed code")
 	}
@@ -83,8 +124,823 @@ Fun X::Int:
 Int x = X`)
 
 	c := compiler.New(stackSize)
-	_, errors := c.Compile(input, true)
+	_, errors := c.Compile(input)
 	if len(errors) == 0 {
 		t.Fatalf("Successfully compiled ill-formed code")
 	}
 }
+
+func TestSourceMap(t *testing.T) {
+
+	input := []byte(`Int a = 1
+Scope outer:
+    Fun Inc::Int$ x Int:
+        Return x + 1
+Int b = outer::Inc$ a
+`)
+
+	c := compiler.New(stackSize)
+	code, errors := c.Compile(input)
+	if len(errors) != 0 {
+		t.Fatalf("Failed to compile code")
+	}
+
+	instructions, err := botlang.Parse(code)
+	if err != nil {
+		t.Fatalf("Failed to parse compiled code: %s", err)
+	}
+
+	sourceMap := c.SourceMap("bot.nil")
+	if len(sourceMap.Mappings) == 0 {
+		t.Fatalf("Source map is empty")
+	}
+
+	lines := make(map[int]bool)
+	for _, mapping := range sourceMap.Mappings {
+		instruction := instructions[mapping.OutputLine-1]
+		if instruction.IsLabel() {
+			t.Fatalf("Label %q is mapped as instruction %d", instruction.Label, mapping.Instruction)
+		}
+
+		if mapping.File != "bot.nil" {
+			t.Fatalf("Unexpected file %q", mapping.File)
+		}
+
+		inFunction := mapping.Line == 3 || mapping.Line == 4
+		if inFunction && (mapping.Function != "Inc" || mapping.Scope != "outer") {
+			t.Fatalf("Expected line %d to be in function %q of scope %q, got function=%q, scope=%q", mapping.Line, "Inc", "outer", mapping.Function, mapping.Scope)
+		}
+
+		if !inFunction && mapping.Function != "" {
+			t.Fatalf("Line %d unexpectedly belongs to function %q", mapping.Line, mapping.Function)
+		}
+		lines[mapping.Line] = true
+	}
+
+	for _, line := range []int{1, 4, 5} {
+		if !lines[line] {
+			t.Errorf("No instruction is mapped to line %d", line)
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+
+	input := []byte(`Int a = 1
+Alias Berry::Int:
+    black = 1
+    blue = 2
+Scope outer:
+    Fun Inc::Int$ x Int:
+        Bool b = True
+        Return x + 1
+Berry c = berry::blue
+`)
+
+	c := compiler.New(stackSize)
+	_, errors := c.Compile(input)
+	if len(errors) != 0 {
+		t.Fatalf("Failed to compile code")
+	}
+
+	tests := []struct {
+		name     string
+		scope    string
+		_type    string
+		constant bool
+	}{
+		{"front", "dir", "Dir", true},
+		{"a", "", "Int", false},
+		{"blue", "berry", "Berry", true},
+		{"x", "outer::Inc", "Int", false},
+		{"b", "outer::Inc", "Bool", false},
+		{"c", "", "Berry", false},
+	}
+
+	symbols := c.Symbols()
+	for _, tt := range tests {
+		found := false
+		for _, symbol := range symbols {
+			if symbol.Name != tt.name || symbol.Scope != tt.scope {
+				continue
+			}
+			found = true
+
+			if symbol.Type != tt._type || symbol.Constant != tt.constant {
+				t.Errorf("Symbol %q expected type=%q, constant=%t, got type=%q, constant=%t", tt.name, tt._type, tt.constant, symbol.Type, symbol.Constant)
+			}
+
+			if symbol.From > symbol.To {
+				t.Errorf("Symbol %q is visible in empty range [%d, %d)", tt.name, symbol.From, symbol.To)
+			}
+		}
+
+		if !found {
+			t.Errorf("Symbol %q of scope %q not found", tt.name, tt.scope)
+		}
+	}
+
+	var a, x compiler.Symbol
+	for _, symbol := range symbols {
+		switch symbol.Name {
+		case "a":
+			a = symbol
+		case "x":
+			x = symbol
+		}
+	}
+
+	if x.From < a.From || x.To > a.To {
+		t.Errorf("Parameter is visible outside of the global variable, parameter=[%d, %d), global=[%d, %d)", x.From, x.To, a.From, a.To)
+	}
+}
+
+func TestReferences(t *testing.T) {
+
+	input := []byte(`Scope food:
+    Int calories = 10
+Using food
+Int x = calories + food::calories
+bot::Move$ dir::front
+`)
+
+	c := compiler.New(stackSize)
+	_, errors := c.Compile(input)
+	if len(errors) != 0 {
+		t.Fatalf("Failed to compile code")
+	}
+
+	tests := []struct {
+		line      int
+		offset    int
+		kind      string
+		name      string
+		signature string
+		declared  int
+	}{
+		{2, 8, compiler.VARIABLE_DEFINITION, "food::calories", "Int calories", 2},
+		{3, 6, compiler.SCOPE_DEFINITION, "food", "Scope food", 1},
+		{4, 8, compiler.VARIABLE_DEFINITION, "food::calories", "Int calories", 2},
+		{4, 19, compiler.SCOPE_DEFINITION, "food", "Scope food", 1},
+		{4, 25, compiler.VARIABLE_DEFINITION, "food::calories", "Int calories", 2},
+		{5, 5, compiler.FUNCTION_DEFINITION, "bot::Move", "Fun Move$ direction Dir", 0},
+		{5, 16, compiler.VARIABLE_DEFINITION, "dir::front", "Dir dir::front", 0},
+	}
+
+	references := c.References()
+	for _, tt := range tests {
+		found := false
+		for _, reference := range references {
+			if reference.Line != tt.line || reference.Offset != tt.offset {
+				continue
+			}
+			found = true
+
+			definition := reference.Definition
+			if definition.Kind != tt.kind || definition.Name != tt.name || definition.Signature != tt.signature || definition.Line != tt.declared {
+				t.Errorf("Reference at %d:%d expected %s %q (%q) declared at line %d, got=%+v", tt.line, tt.offset, tt.kind, tt.name, tt.signature, tt.declared, definition)
+			}
+		}
+
+		if !found {
+			t.Errorf("No reference at %d:%d", tt.line, tt.offset)
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+
+	input := []byte(`Scope food:
+    Int calories = 10
+Scope water:
+    Int volume = 1
+Using food
+Using water
+Int x = calories + water::volume
+`)
+
+	c := compiler.New(stackSize)
+	_, errors := c.Compile(input)
+	if len(errors) != 0 {
+		t.Fatalf("Failed to compile code")
+	}
+
+	expected := []compiler.Import{
+		{Name: "food", Used: true, Line: 5, Offset: 0},
+		{Name: "water", Used: false, Line: 6, Offset: 0},
+	}
+
+	imports := c.Imports()
+	if len(imports) != len(expected) {
+		t.Fatalf("expected %d imports, got %d", len(expected), len(imports))
+	}
+	for i := range expected {
+		if imports[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], imports[i])
+		}
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCompileFiles(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"helpers.nil":        "Domain helpers\n\nInt x = 10\n",
+		"lib/utils/math.nil": "Domain utils::math\n\nUsing helpers\n\nFun Double::Int$ a Int:\n    Return a * 2 + x\n",
+	})
+	library := filepath.Join(root, "lib")
+
+	input := []byte("Domain main\n\nUsing helpers\nUsing utils::math\n\nInt y = math::Double$ x\n")
+
+	c := compiler.New(stackSize)
+	_, errors := c.CompileFiles("main.nil", input, []string{root, library})
+	if len(errors) != 0 {
+		for _, err := range errors {
+			t.Errorf("%s:%d:%d: %s", err.File, err.Line, err.Offset, err.Description)
+		}
+		t.Fatalf("Failed to compile code")
+	}
+
+	scopes := make(map[string]bool)
+	for _, symbol := range c.Symbols() {
+		scopes[symbol.Scope+"::"+symbol.Name] = true
+	}
+	for _, expected := range []string{"helpers::x", "utils::math::Double::a", "main::y"} {
+		if !scopes[expected] {
+			t.Errorf("symbol %q is missing", expected)
+		}
+	}
+
+	files := make(map[string]bool)
+	for _, mapping := range c.SourceMap("main.nil").Mappings {
+		files[mapping.File] = true
+	}
+	for _, expected := range []string{"main.nil", filepath.Join(root, "helpers.nil"), filepath.Join(library, "utils", "math.nil")} {
+		if !files[expected] {
+			t.Errorf("source map doesn't mention file %q", expected)
+		}
+	}
+
+	for source, expected := range map[string]bool{"Domain helpers\n\nInt x = 10\n": true, "Using helpers\n\nInt y = x\n": false, "": false} {
+		if compiler.IsDomain([]byte(source)) != expected {
+			t.Errorf("%q: expected IsDomain %t", source, expected)
+		}
+	}
+}
+
+func TestCompileFilesErrors(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"broken.nil": "Domain broken\n\nInt x = y\n",
+		"wrong.nil":  "Domain right\n",
+		"a.nil":      "Domain a\nUsing b\n",
+		"b.nil":      "Domain b\nUsing a\n",
+	})
+
+	tests := []struct {
+		input string
+		file  string
+		line  int
+	}{
+		{"Using broken\n", "broken.nil", 3},
+		{"Using wrong\n", "wrong.nil", 1},
+		{"Using a\n", "b.nil", 2},
+		{"Int x = 1\nDomain late\n", "main.nil", 2},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New(stackSize)
+		_, errors := c.CompileFiles("main.nil", []byte(tt.input), []string{root})
+		if len(errors) == 0 {
+			t.Errorf("%q: expected errors", tt.input)
+			continue
+		}
+
+		file := tt.file
+		if file != "main.nil" {
+			file = filepath.Join(root, tt.file)
+		}
+		if errors[0].File != file || errors[0].Line != tt.line {
+			t.Errorf("%q: expected error at %s:%d, got %s:%d: %s", tt.input, file, tt.line, errors[0].File, errors[0].Line, errors[0].Description)
+		}
+	}
+}
+
+func TestCompileFilesLocalScopes(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"bot.nil":  "Int x = 1\n",
+		"food.nil": "Int y = 2\n",
+	})
+
+	input := []byte("Scope food:\n    Int calories = 10\nUsing food\nUsing bot\nSleep\n")
+
+	c := compiler.New(stackSize)
+	_, errors := c.CompileFiles(filepath.Join(root, "main.nil"), input, []string{root})
+	for _, err := range errors {
+		t.Errorf("%s:%d:%d: %s", err.File, err.Line, err.Offset, err.Description)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		input   string
+		code    string
+		related int // line of the previous declaration, zero if there is none
+	}{
+		{"Int x = True\n", diagnostic.TYPE_MISMATCH, 0},
+		{"Int x = y\n", diagnostic.UNDECLARED, 0},
+		{"Int x = 1\nInt x = 2\n", diagnostic.REDECLARATION, 1},
+		{"Fun F:\n    bot::Sleep\nFun F:\n    bot::Sleep\n", diagnostic.REDECLARATION, 1},
+		{"Scope s:\n    Int x = 1\nAlias S::Int:\n    a = 1\n", diagnostic.REDECLARATION, 1},
+		{"Fun F$ a Int, a Int:\n    bot::Sleep\n", diagnostic.REDECLARATION, 1},
+		{"Fun F::Int:\n    bot::Sleep\n", diagnostic.MISSING_RETURN, 0},
+		{"bot::Move\n", diagnostic.ARGUMENT_COUNT, 0},
+		{"Break\n", diagnostic.MISPLACED_STATEMENT, 0},
+		{"Alias A::Dir:\n    a = dir::front\n", diagnostic.INVALID_ALIAS, 0},
+		{"Alias A::Int:\n    a = 1\nUsing a\na = 2\n", diagnostic.CONSTANT_ASSIGNMENT, 2},
+		{"Using dir\nfront = back\n", diagnostic.CONSTANT_ASSIGNMENT, 0},
+		{"Int x = 1\nx = x % 0 ** 5\n", diagnostic.DIVISION_BY_ZERO, 0},
+		{"1$ 2\n", diagnostic.INTERNAL_ERROR, 0},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New(stackSize)
+		_, errors := c.Compile([]byte(tt.input))
+		if len(errors) == 0 {
+			t.Errorf("%q: expected error %s", tt.input, tt.code)
+			continue
+		}
+
+		err := errors[0]
+		if err.Code != tt.code || err.Level() != helper.ERROR {
+			t.Errorf("%q: expected error %s, got %s %s: %s", tt.input, tt.code, err.Level(), err.Code, err.Description)
+		}
+		if tt.related == 0 && len(err.Related) != 0 || tt.related != 0 && (len(err.Related) != 1 || err.Related[0].Line != tt.related) {
+			t.Errorf("%q: expected related location at line %d, got %+v", tt.input, tt.related, err.Related)
+		}
+	}
+}
+
+func TestReuse(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("Int x = 1\nFun F::Int:\n    Return x\nbot::WriteMemory$ F\n"),
+		[]byte("Scope s:\n    Bool b = True\nIf s::b:\n    bot::Sleep\n"),
+		[]byte("Int x = y\n"),
+	}
+
+	expected := make([]string, len(inputs))
+	for i, input := range inputs {
+		code, errors := compiler.New(stackSize).Compile(input)
+		expected[i] = fmt.Sprint(string(code), errors)
+	}
+
+	shared := compiler.New(stackSize)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			own := compiler.New(stackSize)
+			for i := range 30 {
+				input := i % len(inputs)
+				for _, c := range []*compiler.Compiler{own, shared} {
+					code, errors := c.Compile(inputs[input])
+					if got := fmt.Sprint(string(code), errors); got != expected[input] {
+						t.Errorf("reused compiler compiled %q differently:\n%s\nexpected:\n%s", inputs[input], got, expected[input])
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCopies(t *testing.T) {
+	c := compiler.New(stackSize)
+	c.EnableAssertions()
+	if _, errors := c.Compile([]byte("Int x = 1\nAssert$ x == 1\n")); len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	// results of a compilation belong to the caller, changing them changes nothing in the compiler
+	instructions, assertions, programs, references := c.Instructions(), c.Assertions(), c.Programs(), c.References()
+	expected := fmt.Sprint(instructions, assertions, programs, references)
+	instructions[0], assertions[0], programs[0], references[0] = botlang.Instruction{}, compiler.Assertion{}, nil, compiler.Reference{}
+
+	if got := fmt.Sprint(c.Instructions(), c.Assertions(), c.Programs(), c.References()); got != expected {
+		t.Errorf("expected results\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestStackDepth(t *testing.T) {
+	input := []byte(`Int x = 1 + 2
+Fun F::Int$ a Int:
+    Return a * 2 + a * 3
+Int y = F$ x
+Int z = y + y * y - y
+If z == 15:
+    bot::WriteMemory$ y
+`)
+
+	tests := []struct {
+		stackSize int
+		expected  compiler.Usage
+	}{
+		// directions of the builtin scope take 8 bytes before x, a, 3 bytes of the stack of F, y and z
+		{compiler.AUTO_STACK_SIZE, compiler.Usage{Stack: 3, StackUsed: 3, Memory: 19}},
+		{stackSize, compiler.Usage{Stack: stackSize, StackUsed: 3, Memory: stackSize + 16}},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New(tt.stackSize)
+		if _, errors := c.Compile(input); len(errors) != 0 {
+			t.Fatalf("unexpected errors %v", errors)
+		}
+		if usage := c.Usage(); usage != tt.expected {
+			t.Errorf("stack size %d: expected %+v, got %+v", tt.stackSize, tt.expected, usage)
+		}
+	}
+
+	_, errors := compiler.New(2).Compile(input)
+	if len(errors) != 1 || errors[0].Code != diagnostic.STACK_OVERFLOW || errors[0].Line != 5 || errors[0].Offset != 18 {
+		t.Errorf("expected stack overflow at 5:18, got %v", errors)
+	}
+}
+
+func TestMemoryMap(t *testing.T) {
+	input := []byte(`Int x = bot::GetEnergy + 2
+Scope food:
+    Bool ripe = True
+Fun F$ a Int:
+    bot::WriteMemory$ a
+`)
+
+	c := compiler.New(compiler.AUTO_STACK_SIZE)
+	c.EnableAssertions()
+	if _, errors := c.Compile(input); len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	allocations := c.MemoryMap()
+	expected := []compiler.Allocation{
+		{Addr: 0, Size: 1, Name: compiler.STACK_ALLOCATION},
+		{Addr: 1, Size: 1, Name: compiler.UNUSED_ALLOCATION},
+		{Addr: 2, Size: 1, Name: compiler.ASSERTION_ALLOCATION, Type: "Int"},
+		{Addr: 3, Size: 1, Scope: "dir", Name: "front", Type: "Dir"},
+	}
+	if len(allocations) != 14 || !reflect.DeepEqual(allocations[:len(expected)], expected) {
+		t.Fatalf("expected 14 allocations beginning with %+v, got %+v", expected, allocations)
+	}
+
+	expected = []compiler.Allocation{
+		{Addr: 11, Size: 1, Name: "x", Type: "Int"},
+		{Addr: 12, Size: 1, Scope: "food", Name: "ripe", Type: "Bool"},
+		{Addr: 13, Size: 1, Scope: "F", Name: "a", Type: "Int"},
+	}
+	if !reflect.DeepEqual(allocations[11:], expected) {
+		t.Errorf("expected %+v, got %+v", expected, allocations[11:])
+	}
+
+	c.LimitMemory(13)
+	_, errors := c.Compile(input)
+	if len(errors) != 1 || errors[0].Code != diagnostic.MEMORY_OVERFLOW || errors[0].Line != 4 || errors[0].Offset != 7 {
+		t.Errorf("expected memory overflow at the parameter 4:7, got %v", errors)
+	}
+}
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // code of the expression
+	}{
+		{"Int v = 5 ** 3", "ldv AX 125"},
+		{"Bool v = 10 > 8", "ldv AX 1"},
+		{"Bool v = Not True", "ldv AX 0"},
+		{"Int v = 60 * 60 - -1", "ldv AX 3601"},
+		{"Int v = 7 / 2 + 7 % 2", "ldv AX 4"},
+		{"Bool v = level::high == level::low Or Not False", "ldv AX 1"},
+		{"Bool v = dir::front != dir::back And level::high != level::low", "ldv AX 1"},
+		{"Int v = x + 1 * 2", "ldm AX [139]\nldr [0] AX\nldv AX 2\nld BX AX\nldm AX [0]\nadd AX BX"},
+		{"Int v = 2 ** -1", "ldv AX 2\nldr [0] AX\nldv AX -1\nld BX AX\nldm AX [0]\npow AX BX"},
+	}
+
+	for _, tt := range tests {
+		input := "Alias Level::Int:\n    low = 1\n    high = 2\nInt x = 1\n" + tt.input + "\n"
+		c := compiler.New(stackSize)
+		code, errors := c.Compile([]byte(input))
+		if len(errors) != 0 {
+			t.Fatalf("%q: unexpected errors %v", tt.input, errors)
+		}
+
+		expected := "ldr [139] AX\n" + tt.expected + "\nldr [140] AX\n"
+		if !strings.HasSuffix(string(code), expected) {
+			t.Errorf("%q: expected code ending with\n%s\ngot\n%s", tt.input, expected, code)
+		}
+	}
+}
+
+func TestOptimization(t *testing.T) {
+	input := "Int x = 1\nInt v = x + 2\nIf v > x:\n    v = x\n"
+	tests := []struct {
+		level    int
+		sum      string // code of the second line
+		branch   string // end of the code
+		unwanted string
+	}{
+		{compiler.NO_OPTIMIZATION, "ldr [137] AX\nldm AX [137]\nldr [0] AX\nldv AX 2\nld BX AX\nldm AX [0]\nadd AX BX\nldr [138] AX\n",
+			"jle lbl_a\nldm AX [137]\nldr [138] AX\njmp lbl_b\nlbl_a:\nlbl_b:\n", ""},
+		{compiler.PEEPHOLE_OPTIMIZATION, "ldr [137] AX\nldr [0] AX\nldv AX 2\nld BX AX\nldm AX [0]\nadd AX BX\nldr [138] AX\n",
+			"jle lbl_a\nldm AX [137]\nldr [138] AX\nlbl_a:\n", "lbl_b"},
+		{compiler.REGISTER_OPTIMIZATION, "ldr [137] AX\nldv BX 2\nadd AX BX\nldr [138] AX\n",
+			"jle lbl_a\nldm AX [137]\nldr [138] AX\nlbl_a:\n", "[0]"},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New(stackSize)
+		c.Optimize(tt.level)
+		code, errors := c.Compile([]byte(input))
+		if len(errors) != 0 {
+			t.Fatalf("level %d: unexpected errors %v", tt.level, errors)
+		}
+
+		if !strings.Contains(string(code), tt.sum) || !strings.HasSuffix(string(code), tt.branch) {
+			t.Errorf("level %d: expected code containing\n%s\nand ending with\n%s\ngot\n%s", tt.level, tt.sum, tt.branch, code)
+		}
+		if tt.unwanted != "" && strings.Contains(string(code), tt.unwanted) {
+			t.Errorf("level %d: unexpected %q in\n%s", tt.level, tt.unwanted, code)
+		}
+
+		instructions := c.Instructions()
+		for _, m := range c.SourceMap("").Mappings {
+			if m.OutputLine > len(instructions) || instructions[m.OutputLine-1].IsLabel() {
+				t.Errorf("level %d: mapping of line %d points at %d, not a command", tt.level, m.Line, m.OutputLine)
+			}
+		}
+		for _, s := range c.Symbols() {
+			if s.Name == "v" && s.From > s.To {
+				t.Errorf("level %d: symbol %q is visible from %d to %d", tt.level, s.Name, s.From, s.To)
+			}
+		}
+	}
+}
+
+func TestConditionCode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // parts of the code
+	}{
+		{"Int x = 1\nWhile x < 10:\n    x = x + 1\n",
+			[]string{"lbl_a:\nldm AX [137]\ncmpv AX 10\njge lbl_b\n", "jmp lbl_a\nlbl_b:\n"}},
+		{"Bool e = True\nInt x = 1\nIf Not e And x != 0:\n    x = 0\n",
+			[]string{"ldm AX [137]\ncmpv AX 1\njme lbl_a\nldm AX [138]\ncmpv AX 0\njme lbl_a\nldv AX 0\n"}},
+		{"Int x = 1\nIf x == 3 Or bot::IsEmpty$ dir::front:\n    x = 0\nElif Not bot::IsSibling$ dir::front:\n    x = 2\n",
+			[]string{"ldm AX [137]\ncmpv AX 3\njme lbl_c\n", "chk front\njmf lbl_d\njmp lbl_a\nlbl_d:\nlbl_c:\nldv AX 0\n", "chk front\njmb lbl_e\nldv AX 2\n"}},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New(stackSize)
+		code, errors := c.Compile([]byte(tt.input))
+		if len(errors) != 0 {
+			t.Fatalf("%q: unexpected errors %v", tt.input, errors)
+		}
+
+		for _, part := range tt.expected {
+			if !strings.Contains(string(code), part) {
+				t.Errorf("%q: expected code containing\n%s\ngot\n%s", tt.input, part, code)
+			}
+		}
+		if strings.Contains(string(code), "cmpv AX 1\njne") {
+			t.Errorf("%q: expected no value of Bool made for the condition, got\n%s", tt.input, code)
+		}
+	}
+}
+
+func TestConstantConditionWarnings(t *testing.T) {
+	input := []byte(`Alias Mode::Int:
+    quiet = 1
+    loud = 2
+If mode::quiet == mode::loud:
+    bot::Sleep
+Elif 1 > 2:
+    bot::Sleep
+Int x = 1
+While x > 2 And False:
+    x = 0
+While True:
+    bot::Sleep
+`)
+
+	c := compiler.New(stackSize)
+	code, errors := c.Compile(input)
+	if len(errors) != 0 || len(code) == 0 {
+		t.Fatalf("expected code in spite of warnings, got errors %v", errors)
+	}
+
+	// a condition which is true or not known at compile time is fine
+	lines := make([]int, 0)
+	for _, warning := range c.Warnings() {
+		if warning.Code != diagnostic.CONSTANT_CONDITION || warning.Level() != helper.WARNING {
+			t.Errorf("expected warning %s, got %s %s", diagnostic.CONSTANT_CONDITION, warning.Level(), warning.Code)
+		}
+		lines = append(lines, warning.Line)
+	}
+	if expected := []int{4, 6}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected warnings at lines %v, got %v", expected, lines)
+	}
+}
+
+func TestDirectionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the end of the code
+		calls    int
+		routines int // of dispatch
+	}{
+		{"bot::Move$ dir::front\nbot::Split$ dir::back\n", "ldr [136] AX\nmov front\nsplit back BEGIN\n", 0, 0},
+		{"Using dir\nIf bot::IsEmpty$ frontLeft:\n    bot::Face$ right\n", "ldr [136] AX\nchk frontleft\njmf lbl_c\njmp lbl_a\nlbl_c:\nrot right\n", 0, 0},
+		{"Dir d = dir::left\nbot::Move$ d\nbot::Move$ d\nbot::Bite$ d\nbot::Move$ dir::back\n", "call lbl_k\nmov back\n", 3, 2},
+	}
+
+	for _, tt := range tests {
+		c := compiler.New(stackSize)
+		code, errors := c.Compile([]byte(tt.input))
+		if len(errors) != 0 {
+			t.Fatalf("%q: unexpected errors %v", tt.input, errors)
+		}
+
+		if !strings.Contains(string(code), tt.expected) {
+			t.Errorf("%q: expected code containing\n%s\ngot\n%s", tt.input, tt.expected, code)
+		}
+		calls, routines := strings.Count(string(code), "call "), strings.Count(string(code), "cmpv AX 1\n")
+		if calls != tt.calls || routines != tt.routines {
+			t.Errorf("%q: expected %d calls of %d routines, got %d calls of %d routines in\n%s", tt.input, tt.calls, tt.routines, calls, routines, code)
+		}
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	input := []byte(`Fun Bits::Int$ n Int:
+    Fun Half::Int$ m Int:
+        Return Bits$ m / 2
+    If n == 0:
+        Return 0
+    Int bits = Half$ n
+    Return bits + n % 2
+Fun Double::Int$ x Int:
+    Return Bits$ x * 2
+bot::WriteMemory$ Double$ 3
+`)
+
+	c := compiler.New(compiler.AUTO_STACK_SIZE)
+	c.LimitFrames(0)
+	_, errors := c.Compile(input)
+
+	// the call of Bits by Double and the call of Double never run their caller again
+	positions := make([]string, 0, len(errors))
+	for _, err := range errors {
+		if err.Code != diagnostic.RECURSION {
+			t.Errorf("expected only recursion errors, got %v", err)
+		}
+		positions = append(positions, fmt.Sprintf("%d:%d", err.Line, err.Offset))
+	}
+	if expected := []string{"3:19", "6:19"}; !reflect.DeepEqual(positions, expected) {
+		t.Errorf("expected recursion errors at %v, got %v", expected, positions)
+	}
+
+	c.LimitFrames(3)
+	code, errors := c.Compile(input)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+	for _, routine := range []string{"FRAMES:", "lbl_a_push:", "lbl_a_pop:", "lbl_c_push:", "lbl_c_pop:"} {
+		if !strings.Contains(string(code), "\n"+routine+"\n") {
+			t.Errorf("expected routine %s in code:\n%s", routine, code)
+		}
+	}
+	if calls := strings.Count(string(code), "_push\n"); calls != 2 {
+		t.Errorf("expected 2 calls of push routines, got %d in code:\n%s", calls, code)
+	}
+
+	// Bits keeps n, bits and 2 bytes of its stack, Half keeps m and a byte of its stack
+	frames := make(map[string]int)
+	overflow := 0
+	for _, allocation := range c.MemoryMap() {
+		switch allocation.Name {
+		case compiler.FRAMES_ALLOCATION:
+			frames[allocation.Scope] = allocation.Size
+		case compiler.FRAME_OVERFLOW_ALLOCATION:
+			overflow = allocation.Addr
+		}
+	}
+	if expected := map[string]int{"Bits": 3 * 4, "Bits::Half": 3 * 2}; !reflect.DeepEqual(frames, expected) {
+		t.Errorf("expected sizes of frames %v, got %v", expected, frames)
+	}
+	if functions := c.RecursiveFunctions(); overflow == 0 || overflow != c.FrameOverflowAddress() || len(functions) != 2 {
+		t.Errorf("expected the frame overflow address %d in the memory map and 2 recursive functions, got %d and %v",
+			c.FrameOverflowAddress(), overflow, functions)
+	}
+
+	// the routines end the program, from the FRAMES routine on
+	_, routines, _ := strings.Cut(string(code), "\nFRAMES:\n")
+	commands := 0
+	for _, line := range strings.Split(routines, "\n") {
+		if line != "" && !strings.HasSuffix(line, ":") {
+			commands++
+		}
+	}
+	if usage := c.Usage(); usage.FrameCode != commands {
+		t.Errorf("expected %d commands of frame routines, got %d", commands, usage.FrameCode)
+	}
+	c.LimitFrames(4)
+	if _, errors := c.Compile(input); len(errors) != 0 || c.Usage().FrameCode <= commands {
+		t.Errorf("expected more commands of frame routines with more frames, got %d and %v", c.Usage().FrameCode, errors)
+	}
+
+	// frames are linked only for recursive functions
+	code, errors = c.Compile([]byte("Fun Double::Int$ x Int:\n    Return x * 2\nFun Quadruple::Int$ x Int:\n    Return Double$ Double$ x\nbot::WriteMemory$ Quadruple$ 3\n"))
+	if len(errors) != 0 || strings.Contains(string(code), compiler.FRAMES_LABEL) || c.FrameOverflowAddress() != 0 || c.Usage().FrameCode != 0 {
+		t.Errorf("expected no frames, got %v in code:\n%s", errors, code)
+	}
+}
+
+func TestMemoryReuse(t *testing.T) {
+	input := []byte(`Int x = 1
+If x == 1:
+    Int a = 2
+    Int b = 3
+    x = a + b
+While x < 10:
+    Int c = x
+    x = c + 1
+Int y = x
+Fun F::Int$ p Int:
+    Int q = p * 2
+    Return q
+Fun G::Int$ r Int:
+    Return F$ r
+Fun H::Int$ s Int:
+    Return s + 1
+bot::WriteMemory$ G$ H$ y
+`)
+
+	c := compiler.New(compiler.AUTO_STACK_SIZE)
+	if _, errors := c.Compile(input); len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	addresses := make(map[string]int)
+	for _, symbol := range c.Symbols() {
+		addresses[symbol.Name] = symbol.Addr
+	}
+
+	// variables of blocks which have ended and functions which never run at the same time share addresses
+	for _, shared := range [][]string{{"a", "c", "y"}, {"p", "s"}} {
+		for _, name := range shared[1:] {
+			if addresses[name] != addresses[shared[0]] {
+				t.Errorf("expected %s at the address of %s, got %v", name, shared[0], addresses)
+			}
+		}
+	}
+	for _, separate := range [][]string{{"a", "b"}, {"x", "y"}, {"p", "q"}, {"p", "r"}, {"q", "r"}} {
+		if addresses[separate[0]] == addresses[separate[1]] {
+			t.Errorf("expected %s and %s at different addresses, got %v", separate[0], separate[1], addresses)
+		}
+	}
+
+	// x, a and b follow the directions, then p, the stack and q of F shared by H and r of G
+	if usage := c.Usage(); usage.Memory != 17 {
+		t.Errorf("expected 17 bytes of memory, got %+v", usage)
+	}
+
+	names := make([]string, 0)
+	for _, allocation := range c.MemoryMap() {
+		if allocation.Addr == addresses["a"] {
+			names = append(names, allocation.Name)
+		}
+	}
+	if expected := []string{"a", "c", "y"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v in the memory map at the address of a, got %v", expected, names)
+	}
+
+	c.LimitMemory(17)
+	if _, errors := c.Compile(input); len(errors) != 0 {
+		t.Errorf("expected the program to fit into 17 bytes, got %v", errors)
+	}
+	c.LimitMemory(16)
+	_, errors := c.Compile(input)
+	if len(errors) != 1 || errors[0].Code != diagnostic.MEMORY_OVERFLOW || errors[0].Line != 13 || errors[0].Offset != 12 {
+		t.Errorf("expected memory overflow at r 13:12, got %v", errors)
+	}
+}
@@ -0,0 +1,162 @@
+package compiler
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"fmt"
+)
+
+// comparisonJumps are taken after cmp if the comparison is true
+var comparisonJumps = map[string]command{
+	tokens.LT:     JUMP_IF_LESS_THAN,
+	tokens.LE:     JUMP_IF_LESS_EQUAL_THAN,
+	tokens.GT:     JUMP_IF_GREATER_THAN,
+	tokens.GE:     JUMP_IF_GREATER_EQUAL_THAN,
+	tokens.EQUAL:  JUMP_IF_EQUAL,
+	tokens.NEQUAL: JUMP_IF_NOT_EQUAL,
+}
+
+// negatedJumps are taken if the comparison of the jump is false
+var negatedJumps = map[command]command{
+	JUMP_IF_LESS_THAN:          JUMP_IF_GREATER_EQUAL_THAN,
+	JUMP_IF_LESS_EQUAL_THAN:    JUMP_IF_GREATER_THAN,
+	JUMP_IF_GREATER_THAN:       JUMP_IF_LESS_EQUAL_THAN,
+	JUMP_IF_GREATER_EQUAL_THAN: JUMP_IF_LESS_THAN,
+	JUMP_IF_EQUAL:              JUMP_IF_NOT_EQUAL,
+	JUMP_IF_NOT_EQUAL:          JUMP_IF_EQUAL,
+}
+
+// warnNeverRuns reports the block of the statement if its condition is false whatever the program does
+func (c *Compiler) warnNeverRuns(condition ast.Expression, token tokens.Token, statement string) {
+	if value, ok := c.peek(condition); ok && value.Type == builtIn(Bool) && value.Value != BOOL_TRUE {
+		c.addWarning(helper.MakeError(token, diagnostic.CONSTANT_CONDITION, fmt.Sprintf("condition of %s is always false, its block never runs", statement)))
+	}
+}
+
+// compileCondition jumps to the target if the condition equals to jumpIf and goes on to the next command otherwise.
+// Comparisons, Not, And, Or and checks of cells jump right away instead of making a value of Bool to compare
+func (c *Compiler) compileCondition(condition ast.Expression, target string, jumpIf bool) Type {
+	defer c.locate(condition)()
+
+	if value, ok := c.fold(condition); ok {
+		if value.Type == builtIn(Bool) && (value.Value == BOOL_TRUE) == jumpIf {
+			c.emit(JUMP, target)
+		}
+		return value.Type
+	}
+
+	switch exp := condition.(type) {
+	case *ast.PrefixExpression:
+		if exp.Operator == tokens.NOT {
+			return c.compileNotCondition(exp, target, jumpIf)
+		}
+	case *ast.InfixExpression:
+		if exp.Operator == tokens.AND || exp.Operator == tokens.OR {
+			return c.compileLogicalCondition(exp, target, jumpIf)
+		}
+		if jump, ok := comparisonJumps[exp.Operator]; ok {
+			return c.compileComparisonCondition(exp, jump, target, jumpIf)
+		}
+	case *ast.CallExpression:
+		if fun, ok := c.findCheck(exp); ok {
+			return c.compileCheckCondition(exp, fun, target, jumpIf)
+		}
+	}
+
+	_type, register := c.compileExpression(condition)
+	c.emit(COMPARE_WITH_VALUE, register, BOOL_TRUE)
+	if jumpIf {
+		c.emit(JUMP_IF_EQUAL, target)
+	} else {
+		c.emit(JUMP_IF_NOT_EQUAL, target)
+	}
+	return _type
+}
+
+func (c *Compiler) compileNotCondition(expression *ast.PrefixExpression, target string, jumpIf bool) Type {
+	if _type := c.compileCondition(expression.Right, target, !jumpIf); _type != builtIn(Bool) {
+		err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected boolean expression. got=%q", _type.String()))
+		c.addError(err)
+	}
+	return builtIn(Bool)
+}
+
+// compileLogicalCondition jumps as soon as an operand decides the result, like compileLogicalExpression
+func (c *Compiler) compileLogicalCondition(expression *ast.InfixExpression, target string, jumpIf bool) Type {
+	var leftType, rightType Type
+
+	// And is true and Or is false only if both operands are, otherwise the left operand may decide the result alone
+	if (expression.Operator == tokens.AND) == jumpIf {
+		next := c.getUniqueLabel()
+		leftType = c.compileCondition(expression.Left, next, !jumpIf)
+		rightType = c.compileCondition(expression.Right, target, jumpIf)
+		c.emitLabel(next)
+	} else {
+		leftType = c.compileCondition(expression.Left, target, jumpIf)
+		rightType = c.compileCondition(expression.Right, target, jumpIf)
+	}
+
+	if leftType != builtIn(Bool) || rightType != builtIn(Bool) {
+		err := helper.MakeError(expression.Token, diagnostic.TYPE_MISMATCH, fmt.Sprintf("expected bool expression(s). got left=%q and right=%q",
+			leftType.String(), rightType.String()))
+		c.addError(err)
+	}
+	return builtIn(Bool)
+}
+
+// compileComparisonCondition compares the left operand with a constant right one by cmpv, other operands by cmp
+func (c *Compiler) compileComparisonCondition(expression *ast.InfixExpression, jump command, target string, jumpIf bool) Type {
+	if !jumpIf {
+		jump = negatedJumps[jump]
+	}
+
+	if _, ok := c.peek(expression.Right); ok {
+		leftType, register := c.compileExpression(expression.Left)
+		right, _ := c.fold(expression.Right)
+		c.checkComparison(expression, leftType, right.Type)
+
+		c.emit(COMPARE_WITH_VALUE, register, right.Value)
+		c.emit(jump, target)
+		return builtIn(Bool)
+	}
+
+	leftType, rightType := c.compileOperands(expression)
+	c.checkComparison(expression, leftType, rightType)
+
+	c.emit(COMPARE, AX, BX)
+	c.emit(jump, target)
+	return builtIn(Bool)
+}
+
+// findCheck returns the builtin checking a cell if the expression calls one, it records nothing
+func (c *Compiler) findCheck(expression *ast.CallExpression) (function, bool) {
+	fun, ok := c.findFunction(expression)
+	_, check := checkJumps[fun.Name]
+	return fun, ok && check && fun.IsBuiltin && len(expression.Arguments) == 1
+}
+
+// compileCheckCondition records references of the call as compileCallExpression does and jumps by the result of chk
+func (c *Compiler) compileCheckCondition(expression *ast.CallExpression, fun function, target string, jumpIf bool) Type {
+	switch exp := expression.Function.(type) {
+	case *ast.ScopeExpression:
+		c.findScope(exp, c.scope)
+		c.refer(exp.Value.Token, fun.Label)
+	case *ast.Identifier:
+		c.refer(exp.Token, fun.Label)
+	}
+	c.compileFunctionWithDirectionArgument(CHECK, expression)
+
+	jump := checkJumps[fun.Name]
+	if jumpIf {
+		c.emit(jump, target)
+		return builtIn(Bool)
+	}
+
+	next := c.getUniqueLabel()
+	c.emit(jump, next)
+	c.emit(JUMP, target)
+	c.emitLabel(next)
+	return builtIn(Bool)
+}
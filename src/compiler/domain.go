@@ -0,0 +1,300 @@
+package compiler
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/lexer"
+	"NiLang/src/parser"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const EXTENSION = ".nil"
+
+// file is a source of the program, every file except the main one begins with its Domain statement
+type file struct {
+	name    string // used in errors
+	program *ast.Program
+	domain  *ast.DomainStatement // nil if the file has no domain
+}
+
+// CompileFiles compiles the main file and files of domains it uses. Domain a::b is looked for as a/b.nil in the directories
+// in the given order, the first one is usually the root of the project. Files are compiled after domains they use
+func (c *Compiler) CompileFiles(name string, input []byte, directories []string) (code []byte, errs errors) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reset(c.stackSize)
+
+	l := &loader{directories: directories, sources: c.sources, domains: make(map[string]*file), loading: make(map[*file]bool)}
+
+	main, ok := l.parse(name, input)
+	if !ok {
+		return nil, l.errors
+	}
+	if main.domain != nil {
+		l.domains[main.domain.Name.String()] = main
+	}
+
+	l.load(main)
+	if len(l.errors) != 0 {
+		return nil, l.errors
+	}
+
+	return c.generate(append(l.order, main))
+}
+
+type loader struct {
+	directories []string
+	sources     map[string][]byte // files added to the compiler, they are found before files of the directories
+
+	domains map[string]*file // files by their domains
+	loading map[*file]bool   // files whose domains are being loaded, a cycle leads back to one of them
+	order   []*file          // each file follows the files of domains it uses
+
+	errors errors
+}
+
+func (l *loader) parse(name string, input []byte) (*file, bool) {
+	lexer := lexer.New(input)
+	parser := parser.New(&lexer)
+	program := parser.Parse()
+
+	if errs := parser.Errors(); len(errs) != 0 {
+		for _, err := range errs {
+			err.File = name
+			l.errors = append(l.errors, err)
+		}
+		return nil, false
+	}
+
+	f := &file{name: name, program: program}
+	if len(program.Statements) != 0 {
+		f.domain, _ = program.Statements[0].(*ast.DomainStatement)
+	}
+	return f, true
+}
+
+// load finds files of domains used by the file, the longest path of a Using statement matching a file wins
+func (l *loader) load(f *file) {
+	l.loading[f] = true
+	defer func() { l.loading[f] = false }()
+
+	local := localScopes(f.program.Statements)
+	for _, using := range usings(f.program.Statements) {
+		path, ok := namePath(using.Name)
+		if !ok || local[path[0]] {
+			continue
+		}
+
+		for length := len(path); length > 0; length-- {
+			domain := strings.Join(path[:length], "::")
+
+			if dependency, ok := l.domains[domain]; ok {
+				if l.loading[dependency] {
+					err := helper.MakeError(using.Token, diagnostic.IMPORT_CYCLE, fmt.Sprintf("import cycle, domain %q uses this file", domain))
+					err.File = f.name
+					l.errors = append(l.errors, err)
+				}
+				break
+			}
+
+			name, input, ok := l.find(path[:length])
+			if !ok || name == f.name {
+				continue
+			}
+
+			dependency, ok := l.parse(name, input)
+			if !ok {
+				break
+			}
+			l.domains[domain] = dependency
+
+			if dependency.domain == nil || dependency.domain.Name.String() != domain {
+				l.errors = append(l.errors, helper.Error{Line: 1, Offset: 0, File: name, Code: diagnostic.INVALID_DOMAIN,
+					Description: fmt.Sprintf("expected file to begin with `Domain %s`", domain)})
+				break
+			}
+
+			l.load(dependency)
+			l.order = append(l.order, dependency)
+			break
+		}
+	}
+}
+
+func (l *loader) find(path []string) (string, []byte, bool) {
+	if input, ok := l.sources[filepath.Join(path...)+EXTENSION]; ok {
+		return filepath.Join(path...) + EXTENSION, input, true
+	}
+
+	for _, directory := range l.directories {
+		name := filepath.Join(append([]string{directory}, path...)...) + EXTENSION
+		if input, err := os.ReadFile(name); err == nil {
+			return name, input, true
+		}
+	}
+	return "", nil, false
+}
+
+// usings lists Using statements of the block and of nested blocks
+func usings(statements []ast.Statement) []*ast.UsingStatement {
+	result := make([]*ast.UsingStatement, 0)
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.UsingStatement:
+			result = append(result, s)
+		case *ast.ScopeStatement:
+			result = append(result, usings(s.Body.Statements)...)
+		case *ast.FunctionStatement:
+			result = append(result, usings(s.Body.Statements)...)
+		case *ast.WhileStatement:
+			result = append(result, usings(s.Body.Statements)...)
+		case *ast.IfStatement:
+			result = append(result, usings(s.Consequence.Statements)...)
+			for _, elif := range s.Elifs {
+				result = append(result, usings(elif.Consequence.Statements)...)
+			}
+			if s.Alternative != nil {
+				result = append(result, usings(s.Alternative.Statements)...)
+			}
+		}
+	}
+	return result
+}
+
+// localScopes are names of builtin scopes and of scopes declared in the file, Using them doesn't need other files
+func localScopes(statements []ast.Statement) map[string]bool {
+	result := map[string]bool{"bot": true, helper.FirstToLowerCase(Dir): true}
+
+	var collect func(statements []ast.Statement)
+	collect = func(statements []ast.Statement) {
+		for _, statement := range statements {
+			switch s := statement.(type) {
+			case *ast.ScopeStatement:
+				result[s.Name.Value] = true
+				collect(s.Body.Statements)
+			case *ast.AliasStatement:
+				result[helper.FirstToLowerCase(s.Var.Name)] = true
+			}
+		}
+	}
+	collect(statements)
+	return result
+}
+
+// namePath splits a::b::c into names
+func namePath(expression ast.Expression) ([]string, bool) {
+	identifiers, ok := nameIdentifiers(expression)
+
+	path := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		path[i] = identifier.Value
+	}
+	return path, ok
+}
+
+func nameIdentifiers(expression ast.Expression) ([]*ast.Identifier, bool) {
+	switch e := expression.(type) {
+	case *ast.Identifier:
+		return []*ast.Identifier{e}, true
+	case *ast.ScopeExpression:
+		identifiers, ok := nameIdentifiers(e.Scope)
+		return append(identifiers, e.Value), ok
+	default:
+		return nil, false
+	}
+}
+
+// IsDomain reports whether the source begins with a Domain statement, such a file is a part of the programs using
+// the domain rather than a program of its own
+func IsDomain(input []byte) bool {
+	lexer := lexer.New(input)
+	parser := parser.New(&lexer)
+	program := parser.Parse()
+
+	if len(program.Statements) == 0 {
+		return false
+	}
+	_, ok := program.Statements[0].(*ast.DomainStatement)
+	return ok
+}
+
+// AddSource makes the file available to Using statements of CompileFiles as if it were in the first directory,
+// e.g. food/berry.nil for domain food::berry, so the program may be compiled without the file system
+func (c *Compiler) AddSource(name string, input []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.sources == nil {
+		c.sources = make(map[string][]byte)
+	}
+	c.sources[filepath.Clean(name)] = input
+}
+
+// Programs returns syntax trees of the files of the last compilation in the order they have been compiled in
+func (c *Compiler) Programs() []*ast.Program {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.programs)
+}
+
+// compileFile compiles statements of the file in the scope of its domain
+func (c *Compiler) compileFile(f *file) {
+	c.file = f.name
+	c.origin.file = f.name
+	c.programs = append(c.programs, f.program)
+
+	statements := f.program.Statements
+	if f.domain != nil {
+		global := c.scope
+		defer func() { c.scope = global }()
+		defer c.enterDomain(f.domain)()
+
+		statements = statements[1:]
+	}
+
+	for _, statement := range statements {
+		c.compileStatement(statement)
+	}
+}
+
+// enterDomain makes the scope of the domain current, scopes of the path are shared by domains, e.g. a::b and a::c
+func (c *Compiler) enterDomain(ds *ast.DomainStatement) func() {
+	path, ok := nameIdentifiers(ds.Name)
+	if !ok {
+		c.addError(helper.MakeError(ds.Token, diagnostic.INVALID_DOMAIN, fmt.Sprintf("expected name of domain, got %q", ds.Name)))
+		return func() {}
+	}
+
+	restores := make([]func(), 0, len(path))
+	for i, identifier := range path {
+		n := identifier.Value
+		s, ok := c.scope.getLocalScope(n)
+		if ok && i == len(path)-1 {
+			err := helper.MakeError(ds.Token, diagnostic.REDECLARATION, fmt.Sprintf("redeclaration of domain %q", ds.Name))
+			err.Related = c.previous(s)
+			c.addError(err)
+		}
+
+		if !ok {
+			s = newScope(n)
+			s.SetParent(c.scope)
+			c.scope.AddScope(s)
+			c.define(s, SCOPE_DEFINITION, scopePath(s), "", "Domain "+scopePath(s), identifier.Token)
+		}
+
+		c.scope = s
+		restores = append(restores, c.enterScopeOrigin(n))
+	}
+
+	return func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}
+}
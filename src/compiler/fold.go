@@ -0,0 +1,157 @@
+package compiler
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/botlang"
+	"NiLang/src/tokens"
+)
+
+// constant is a value of an expression known at compile time
+type constant struct {
+	Type  Type
+	Value int
+}
+
+// fold evaluates the expression made of literals, values of aliases and directions at compile time.
+// Nothing is recorded if the expression is not constant, it is compiled and checked as usual then
+func (c *Compiler) fold(expression ast.Expression) (constant, bool) {
+	errors, references := len(c.errors), len(c.references)
+
+	value, ok := c.evaluate(expression)
+	if !ok {
+		c.errors, c.references = c.errors[:errors], c.references[:references]
+	}
+	return value, ok
+}
+
+// peek evaluates the expression like fold but records nothing, e.g. for an operand compiled on its own
+func (c *Compiler) peek(expression ast.Expression) (constant, bool) {
+	errors, references := len(c.errors), len(c.references)
+	defer func() { c.errors, c.references = c.errors[:errors], c.references[:references] }()
+
+	return c.evaluate(expression)
+}
+
+func (c *Compiler) compileConstant(value constant) (Type, register) {
+	c.emit(LOAD_TO_REG_FROM_VAL, AX, value.Value)
+	return value.Type, AX
+}
+
+func (c *Compiler) evaluate(expression ast.Expression) (constant, bool) {
+	switch exp := expression.(type) {
+	case *ast.IntegralLiteral:
+		return constant{builtIn(Int), int(exp.Value)}, true
+	case *ast.BooleanLiteral:
+		return makeBool(exp.Value), true
+	case *ast.Identifier:
+		return c.evaluateIdentifier(exp, c.scope)
+	case *ast.ScopeExpression:
+		if scope, ok := c.findScope(exp, c.scope); ok {
+			return c.evaluateIdentifier(exp.Value, scope)
+		}
+	case *ast.PrefixExpression:
+		return c.evaluatePrefix(exp)
+	case *ast.InfixExpression:
+		return c.evaluateInfix(exp)
+	}
+	return constant{}, false
+}
+
+func (c *Compiler) evaluateIdentifier(expression *ast.Identifier, scope *scope) (constant, bool) {
+	variable, ok := scope.GetVariable(expression.Value)
+	if !ok {
+		return constant{}, false
+	}
+
+	value, ok := c.constants[variable.Addr]
+	if ok {
+		c.refer(expression.Token, variable.Addr)
+	}
+	return constant{variable.Type, value}, ok
+}
+
+func (c *Compiler) evaluatePrefix(expression *ast.PrefixExpression) (constant, bool) {
+	right, ok := c.evaluate(expression.Right)
+	if !ok {
+		return constant{}, false
+	}
+
+	switch {
+	case expression.Operator == tokens.NOT && right.Type == builtIn(Bool):
+		return makeBool(right.Value != BOOL_TRUE), true
+	case expression.Operator == tokens.NEGATION && right.Type == builtIn(Int):
+		return constant{builtIn(Int), -right.Value}, true
+	default:
+		return constant{}, false
+	}
+}
+
+func (c *Compiler) evaluateInfix(expression *ast.InfixExpression) (constant, bool) {
+	left, ok := c.evaluate(expression.Left)
+	if !ok {
+		return constant{}, false
+	}
+	right, ok := c.evaluate(expression.Right)
+	if !ok {
+		return constant{}, false
+	}
+
+	a, b := left.Value, right.Value
+	integers := left.Type == builtIn(Int) && right.Type == builtIn(Int)
+	booleans := left.Type == builtIn(Bool) && right.Type == builtIn(Bool)
+
+	switch {
+	case expression.Operator == tokens.EQUAL && left.Type == right.Type:
+		return makeBool(a == b), true
+	case expression.Operator == tokens.NEQUAL && left.Type == right.Type:
+		return makeBool(a != b), true
+	case expression.Operator == tokens.AND && booleans:
+		return makeBool(a == BOOL_TRUE && b == BOOL_TRUE), true
+	case expression.Operator == tokens.OR && booleans:
+		return makeBool(a == BOOL_TRUE || b == BOOL_TRUE), true
+	case !integers:
+		return constant{}, false
+	}
+
+	switch expression.Operator {
+	case tokens.LT:
+		return makeBool(a < b), true
+	case tokens.LE:
+		return makeBool(a <= b), true
+	case tokens.GT:
+		return makeBool(a > b), true
+	case tokens.GE:
+		return makeBool(a >= b), true
+	case tokens.ADDITION:
+		return constant{builtIn(Int), a + b}, true
+	case tokens.NEGATION:
+		return constant{builtIn(Int), a - b}, true
+	case tokens.MULTIPLICATION:
+		return constant{builtIn(Int), a * b}, true
+	case tokens.DIVISION:
+		if b == 0 {
+			return constant{}, false // reported by compileInfixExpression
+		}
+		return constant{builtIn(Int), a / b}, true
+	case tokens.MODULO:
+		if b == 0 {
+			return constant{}, false
+		}
+		return constant{builtIn(Int), a % b}, true
+	case tokens.POWER:
+		power, err := botlang.Power(a, b)
+		if err != nil {
+			return constant{}, false // a runtime error as on the virtual machine
+		}
+		return constant{builtIn(Int), power}, true
+	default:
+		return constant{}, false
+	}
+}
+
+func makeBool(value bool) constant {
+	if value {
+		return constant{builtIn(Bool), BOOL_TRUE}
+	}
+	return constant{builtIn(Bool), BOOL_FALSE}
+}
@@ -0,0 +1,265 @@
+package compiler
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"fmt"
+	"slices"
+)
+
+// DEFAULT_FRAMES is the number of calls of a recursive function which may run at once
+const DEFAULT_FRAMES = 16
+
+// FRAMES_LABEL is the routine setting frame pointers of recursive functions at the beginning of the program
+const FRAMES_LABEL = "FRAMES"
+
+// suffixes of labels of the function's routines copying its memory to a frame and back
+const (
+	PUSH_SUFFIX = "_push"
+	POP_SUFFIX  = "_pop"
+)
+
+// LimitFrames sets the number of calls of a recursive function which may run at once, zero disables frames
+// and makes recursion an error. Extend never uses frames
+func (c *Compiler) LimitFrames(depth int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.frameDepth = depth
+}
+
+// FrameOverflowAddress returns the address where a call needing one more frame than LimitFrames allows stores
+// the number of its function counted from 1 in the order of RecursiveFunctions before the bot stops.
+// It is zero if the program of the last compilation has no recursive functions
+func (c *Compiler) FrameOverflowAddress() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return int(c.overflowAddress)
+}
+
+// RecursiveFunctions lists names of functions of the last compilation whose frames are copied around recursive calls,
+// e.g. "Bits::Half"
+func (c *Compiler) RecursiveFunctions() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	names := make([]string, len(c.recursive))
+	for i, f := range c.recursive {
+		names[i] = scopePath(f.scope)
+	}
+	return names
+}
+
+// frameCode counts commands of the FRAMES routine and the routines copying frames, they end the program
+func (c *Compiler) frameCode() int {
+	start := slices.IndexFunc(c.code, func(i botlang.Instruction) bool { return i.Label == FRAMES_LABEL })
+	if start < 0 {
+		return 0 // no recursive functions
+	}
+
+	count := 0
+	for _, instruction := range c.code[start:] {
+		if !instruction.IsLabel() {
+			count++
+		}
+	}
+	return count
+}
+
+// frame is the memory of a function: its parameters, variables and values of expressions. Botlang can't address
+// memory by a register, so a function keeps its memory at fixed addresses and a call which may run the function
+// again before it returns copies the memory to the frame its frame pointer points at and back after the return
+type frame struct {
+	name      name
+	label     string       // of the function
+	token     tokens.Token // name of the function, frames exceeding memory are reported at it
+	scope     *scope
+	addresses []address
+	stack     []address // values of expressions, calls nested in expressions never overwrite the stack of the caller
+	free      []address // of variables of blocks which have ended, in ascending order
+	pointer   address   // number of frames in use
+}
+
+// call of a function of the program
+type call struct {
+	caller    *frame // nil at the top level
+	callee    *frame
+	token     tokens.Token
+	push, pop int // indexes of the commands copying the frame of the caller in code, -1 if frames are not linked
+}
+
+// enterFrame makes the following declarations and values of expressions a part of the frame of the function
+func (c *Compiler) enterFrame(name name, label string, token tokens.Token) func() {
+	previous := c.frame
+	c.frame = &frame{name: name, label: label, token: token}
+	c.frames[label] = c.frame
+
+	return func() { c.frame = previous }
+}
+
+// emitFrameCall calls the routine of the caller copying its frame, linkFrames drops the call unless the callee may run
+// the caller again. It returns the index of the call in code or -1 if it is not emitted
+func (c *Compiler) emitFrameCall(suffix string) int {
+	if !c.framed || c.frame == nil {
+		return -1
+	}
+	c.emit(CALL, c.frame.label+suffix)
+	return len(c.code) - 1
+}
+
+// reaching returns a function telling if a call of the first function may run the second one before it returns
+func (c *Compiler) reaching() func(from *frame, to *frame) bool {
+	callees := make(map[*frame][]*frame)
+	for _, call := range c.calls {
+		if call.caller != nil {
+			callees[call.caller] = append(callees[call.caller], call.callee)
+		}
+	}
+
+	var reaches func(from *frame, to *frame, visited map[*frame]bool) bool
+	reaches = func(from *frame, to *frame, visited map[*frame]bool) bool {
+		if from == to {
+			return true
+		}
+		visited[from] = true
+		for _, callee := range callees[from] {
+			if !visited[callee] && reaches(callee, to, visited) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(from *frame, to *frame) bool { return reaches(from, to, make(map[*frame]bool)) }
+}
+
+// recursiveCalls lists calls compiled since the first one which may run their caller again before they return,
+// a caller without memory has nothing to lose
+func (c *Compiler) recursiveCalls(first int) []call {
+	reaches := c.reaching()
+
+	recursive := make([]call, 0)
+	for _, call := range c.calls[first:] {
+		if call.caller != nil && len(call.caller.addresses) != 0 && reaches(call.callee, call.caller) {
+			recursive = append(recursive, call)
+		}
+	}
+	return recursive
+}
+
+// linkFrames keeps copying of frames around recursive calls and emits the routines copying them after the program,
+// the other calls drop the copying. Recursive calls are errors if frames are not linked
+func (c *Compiler) linkFrames() {
+	calls := c.recursiveCalls(c.linked)
+	c.linked = len(c.calls)
+
+	if !c.framed {
+		for _, call := range calls {
+			err := helper.MakeError(call.token, diagnostic.RECURSION, fmt.Sprintf("call of %q may run %q again before it returns, recursion needs frames",
+				call.callee.name, call.caller.name))
+			c.addError(err)
+		}
+		return
+	}
+
+	kept := make(map[int]bool)
+	for _, call := range calls {
+		kept[call.push], kept[call.pop] = true, true
+		if !slices.Contains(c.recursive, call.caller) {
+			c.recursive = append(c.recursive, call.caller)
+		}
+	}
+
+	dropped := map[int]bool{c.framesCall: len(c.recursive) == 0}
+	for _, call := range c.calls {
+		dropped[call.push], dropped[call.pop] = !kept[call.push], !kept[call.pop]
+	}
+
+	entries := make([]entry, 0, len(c.code))
+	for i, instruction := range c.code {
+		if !dropped[i] {
+			entries = append(entries, entry{instruction: instruction, origin: c.origins[i], index: i})
+		}
+	}
+	c.replaceCode(entries)
+
+	if len(c.recursive) == 0 {
+		return
+	}
+
+	previous := c.origin
+	c.origin = origin{}
+	defer func() { c.origin = previous }()
+
+	c.emit(JUMP, END_LABEL)
+	c.emitLabel(FRAMES_LABEL)
+	c.emit(LOAD_TO_REG_FROM_VAL, BX, 0)
+	for _, f := range c.recursive {
+		f.pointer = c.purchaseMemoryAddress(f.token)
+		c.allocate(f.pointer, 1, f.scope, FRAME_POINTER_ALLOCATION)
+		c.emit(LOAD_TO_MEM_FROM_REG, f.pointer, BX)
+	}
+	c.emit(RETURN)
+
+	c.overflowAddress = c.purchaseMemoryAddress(c.recursive[0].token)
+	c.allocate(c.overflowAddress, 1, nil, FRAME_OVERFLOW_ALLOCATION)
+	for i, f := range c.recursive {
+		c.emitFrameRoutines(f, i+1)
+	}
+}
+
+// emitFrameRoutines copies the memory of the function to its frames through BX, so AX keeps the returned value.
+// The push routine stores the number of the function at the overflow address and stops the bot if every frame is in use
+func (c *Compiler) emitFrameRoutines(f *frame, number int) {
+	frames := make([][]address, c.frameDepth)
+	for i := range frames {
+		frames[i] = make([]address, len(f.addresses))
+		for j := range frames[i] {
+			frames[i][j] = c.purchaseMemoryAddress(f.token)
+		}
+	}
+	c.allocate(frames[0][0], len(frames)*len(f.addresses), f.scope, FRAMES_ALLOCATION)
+
+	copyFrame := func(from []address, to []address, pointer int) {
+		for i := range from {
+			c.emit(LOAD_TO_REG_FROM_MEM, BX, from[i])
+			c.emit(LOAD_TO_MEM_FROM_REG, to[i], BX)
+		}
+		c.emit(LOAD_TO_REG_FROM_VAL, BX, pointer)
+		c.emit(LOAD_TO_MEM_FROM_REG, f.pointer, BX)
+		c.emit(RETURN)
+	}
+
+	pushes := make([]string, len(frames))
+	c.emitLabel(f.label + PUSH_SUFFIX)
+	c.emit(LOAD_TO_REG_FROM_MEM, BX, f.pointer)
+	for i := range frames {
+		pushes[i] = c.getUniqueLabel()
+		c.emit(COMPARE_WITH_VALUE, BX, i)
+		c.emit(JUMP_IF_EQUAL, pushes[i])
+	}
+	c.emit(LOAD_TO_REG_FROM_VAL, BX, number)
+	c.emit(LOAD_TO_MEM_FROM_REG, c.overflowAddress, BX)
+	c.emit(JUMP, END_LABEL)
+	for i, frame := range frames {
+		c.emitLabel(pushes[i])
+		copyFrame(f.addresses, frame, i+1)
+	}
+
+	// the last frame is popped if the pointer points at none of the others
+	pops := make([]string, len(frames)-1)
+	c.emitLabel(f.label + POP_SUFFIX)
+	c.emit(LOAD_TO_REG_FROM_MEM, BX, f.pointer)
+	for i := range pops {
+		pops[i] = c.getUniqueLabel()
+		c.emit(COMPARE_WITH_VALUE, BX, i+1)
+		c.emit(JUMP_IF_EQUAL, pops[i])
+	}
+	copyFrame(frames[len(frames)-1], f.addresses, len(frames)-1)
+	for i, frame := range frames[:len(pops)] {
+		c.emitLabel(pops[i])
+		copyFrame(frame, f.addresses, i)
+	}
+}
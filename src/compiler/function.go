@@ -2,7 +2,7 @@ package compiler
 
 type function struct {
 	Name      name
-	Label     string
+	Label     string // builtins are never called, their label is the key of the definition
 	Type      Type
 	Arguments []variable
 
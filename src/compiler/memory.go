@@ -0,0 +1,212 @@
+package compiler
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"fmt"
+	"slices"
+)
+
+// names of allocations which are not variables
+const (
+	STACK_ALLOCATION          = "(stack)"
+	ASSERTION_ALLOCATION      = "(assertion)"
+	FRAMES_ALLOCATION         = "(frames)"
+	FRAME_POINTER_ALLOCATION  = "(frame pointer)"
+	FRAME_OVERFLOW_ALLOCATION = "(frame overflow)"
+	UNUSED_ALLOCATION         = "(unused)"
+)
+
+// Allocation is a part of memory taken by the program, the stack, a variable or frames of a recursive function
+type Allocation struct {
+	Addr  int
+	Size  int    // bytes, a variable takes one byte
+	Scope string // names of enclosing scopes joined with "::", empty for the global scope
+	Name  string
+	Type  string
+}
+
+// TARGET_MEMORY_SIZE is the memory of a TorLand bot (https://github.com/Slava2001/TorLand) in bytes, the default
+// limit of the command line compiler and of nilang.DefaultOptions. It has been taken over from the local virtual
+// machine and is not checked against the bots of TorLand yet, -m overrides it for bots of another size
+const TARGET_MEMORY_SIZE = 1024
+
+// LimitMemory makes a program taking more than size bytes of memory an error, zero removes the limit
+func (c *Compiler) LimitMemory(size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.memoryLimit = size
+	c.memorySize = address(size)
+}
+
+// MemoryMap lists every address taken by the program of the last compilation in their order,
+// an address shared by variables of blocks or functions which never run at the same time is listed for each of them
+func (c *Compiler) MemoryMap() []Allocation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	taken := make(map[address][]Allocation)
+	for _, s := range c.symbols {
+		taken[s.Addr] = append(taken[s.Addr], Allocation{Addr: s.Addr, Size: 1, Scope: scopePath(s.scope), Name: s.Name, Type: s.Type.String()})
+	}
+	for _, allocation := range c.allocations {
+		taken[allocation.Addr] = append(taken[allocation.Addr], allocation)
+	}
+	if c.assert {
+		taken[c.assertAddress] = append(taken[c.assertAddress], Allocation{Addr: c.assertAddress, Size: 1, Name: ASSERTION_ALLOCATION, Type: Int})
+	}
+
+	allocations := make([]Allocation, 0, len(taken)+1)
+	if c.maxStackAddress != 0 {
+		allocations = append(allocations, Allocation{Addr: 0, Size: int(c.maxStackAddress), Name: STACK_ALLOCATION})
+	}
+	for addr := c.maxStackAddress; addr <= c.memoryIndex; addr++ {
+		if len(taken[addr]) == 0 {
+			allocations = append(allocations, Allocation{Addr: addr, Size: 1, Name: UNUSED_ALLOCATION})
+			continue
+		}
+
+		allocations = append(allocations, taken[addr]...)
+		addr += address(taken[addr][0].Size) - 1 // frames are never shared
+	}
+	return allocations
+}
+
+// purchaseMemoryAddress takes the next byte of memory for the declaration, the byte belongs to the frame of the function
+// being compiled. The first declaration exceeding memory is reported at the end of compilation
+func (c *Compiler) purchaseMemoryAddress(declaration tokens.Token) address {
+	c.memoryIndex++
+	c.declarations[c.memoryIndex] = declaration
+	if c.frame != nil {
+		c.frame.addresses = append(c.frame.addresses, c.memoryIndex)
+	}
+	if c.memorySize != 0 && c.memoryIndex >= c.memorySize && c.memoryOverflow == nil {
+		c.memoryOverflow = &declaration
+	}
+	return c.memoryIndex
+}
+
+// purchaseVariableAddress takes the byte of a variable of a block which has ended in the same function
+// or at the top level, a new byte is taken if there is none
+func (c *Compiler) purchaseVariableAddress(declaration tokens.Token) address {
+	free := &c.free
+	if c.frame != nil {
+		free = &c.frame.free
+	}
+
+	if len(*free) == 0 {
+		return c.purchaseMemoryAddress(declaration)
+	}
+	addr := (*free)[0]
+	*free = (*free)[1:]
+	return addr
+}
+
+// freeVariables returns bytes of variables of the block which has ended to the function or the top level
+func (c *Compiler) freeVariables(block *scope) {
+	free := &c.free
+	if c.frame != nil {
+		free = &c.frame.free
+	}
+
+	for _, variable := range block.variables {
+		*free = append(*free, variable.Addr)
+	}
+	slices.Sort(*free)
+}
+
+// allocate names memory which is not a variable in the memory map
+func (c *Compiler) allocate(addr address, size int, scope *scope, name string) {
+	c.allocations = append(c.allocations, Allocation{Addr: addr, Size: size, Scope: scopePath(scope), Name: name})
+	if name == STACK_ALLOCATION {
+		c.temporaries[addr] = true
+	}
+}
+
+// temporary tells if the address holds values of expressions, it is a part of the stack or of a stack of a function
+func (c *Compiler) temporary(addr address) bool {
+	return addr < c.maxStackAddress || c.temporaries[addr]
+}
+
+// shareMemory moves memory of functions which never run at the same time to the same addresses, each byte of a
+// function takes the first address none of the functions running with it has taken. Variables of the top level,
+// frames and their pointers keep their own addresses in their order
+func (c *Compiler) shareMemory() {
+	if c.memoryIndex <= c.maxStackAddress {
+		return // nothing follows the stack, e.g. the first pass measuring the stack
+	}
+
+	owners := make(map[address]*frame)
+	for _, f := range c.frames {
+		for _, addr := range f.addresses {
+			owners[addr] = f
+		}
+	}
+	reaches := c.reaching()
+	together := func(f *frame, g *frame) bool { return reaches(f, g) || reaches(g, f) }
+
+	moved := make(map[address]address)
+	users := make(map[address][]*frame) // functions sharing each new address, none for the top level
+	last := c.maxStackAddress
+	for addr := c.maxStackAddress + 1; addr <= c.memoryIndex; addr++ {
+		f, ok := owners[addr]
+		if ok {
+			for shared := c.maxStackAddress + 1; shared <= last && moved[addr] == 0; shared++ {
+				if len(users[shared]) != 0 && !slices.ContainsFunc(users[shared], func(g *frame) bool { return together(f, g) }) {
+					moved[addr] = shared
+				}
+			}
+		}
+		if moved[addr] == 0 {
+			last++
+			moved[addr] = last
+		}
+		if ok {
+			users[moved[addr]] = append(users[moved[addr]], f)
+		}
+	}
+
+	for i, instruction := range c.code {
+		operands := slices.Clone(instruction.Operands)
+		for j, operand := range operands {
+			if operand.Kind == botlang.MEMORY && moved[operand.Value] != 0 {
+				operands[j].Value = moved[operand.Value]
+			}
+		}
+		c.code[i].Operands = operands
+	}
+	for i := range c.symbols {
+		c.symbols[i].Addr = moved[c.symbols[i].Addr]
+	}
+	for i := range c.allocations {
+		c.allocations[i].Addr = moved[c.allocations[i].Addr]
+	}
+	if c.assert {
+		c.assertAddress = moved[c.assertAddress]
+	}
+	if c.overflowAddress != 0 {
+		c.overflowAddress = moved[c.overflowAddress]
+	}
+
+	end := c.memoryIndex
+	c.memoryIndex, c.memoryOverflow = last, nil
+	for addr := c.maxStackAddress + 1; addr <= end && c.memorySize != 0; addr++ {
+		if moved[addr] >= c.memorySize {
+			declaration := c.declarations[addr]
+			c.memoryOverflow = &declaration
+			break
+		}
+	}
+}
+
+// checkMemory reports the first declaration which doesn't fit into memory with the size of the whole program
+func (c *Compiler) checkMemory() {
+	if c.memoryOverflow != nil {
+		err := helper.MakeError(*c.memoryOverflow, diagnostic.MEMORY_OVERFLOW, fmt.Sprintf("memory overflow, the program needs %d bytes of memory, MemorySize=%d",
+			c.memoryIndex+1, c.memorySize))
+		c.addError(err)
+	}
+}
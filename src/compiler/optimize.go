@@ -0,0 +1,250 @@
+package compiler
+
+import (
+	"NiLang/src/botlang"
+	"slices"
+)
+
+// levels of Optimize, each level includes the previous ones
+const (
+	// NO_OPTIMIZATION keeps code as it is emitted
+	NO_OPTIMIZATION = iota
+	// PEEPHOLE_OPTIMIZATION drops jumps to the next command, unused labels, moves of a register to itself,
+	// loads overwritten by the next command and loads of a value just stored from the same register
+	PEEPHOLE_OPTIMIZATION
+	// REGISTER_OPTIMIZATION keeps the left operand of an operator in its register instead of the stack
+	// if the right operand is a literal or a variable
+	REGISTER_OPTIMIZATION
+)
+
+const DEFAULT_OPTIMIZATION = REGISTER_OPTIMIZATION
+
+// Optimize sets the level of optimization of the following compilations, code of a program with errors is never optimized
+func (c *Compiler) Optimize(level int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.optimization = level
+}
+
+// entry is a command or a label being optimized
+type entry struct {
+	instruction botlang.Instruction
+	origin      origin
+	index       int // in the emitted code
+}
+
+// rule replaces commands at the beginning of the entries, it returns the number of replaced entries or zero
+type rule func(c *Compiler, entries []entry) ([]entry, int)
+
+var peepholeRules = []rule{dropSelfMove, dropOverwrittenLoad, dropReload, dropJumpToNext}
+var registerRules = []rule{keepOperandInRegister}
+
+// optimize rewrites the code until no rule applies
+func (c *Compiler) optimize() {
+	if c.optimization == NO_OPTIMIZATION || len(c.errors) != 0 {
+		return
+	}
+
+	rules := peepholeRules
+	if c.optimization >= REGISTER_OPTIMIZATION {
+		rules = append(rules, registerRules...)
+	}
+
+	entries := make([]entry, len(c.code))
+	for i, instruction := range c.code {
+		entries[i] = entry{instruction: instruction, origin: c.origins[i], index: i}
+	}
+
+	for changed := true; changed; {
+		entries, changed = c.rewrite(entries, rules)
+		if labels := c.dropUnusedLabels(entries); len(labels) != len(entries) {
+			entries, changed = labels, true
+		}
+	}
+
+	c.replaceCode(entries)
+}
+
+// replaceCode makes the entries the code, indexes of code kept by symbols and scopes are moved with it
+func (c *Compiler) replaceCode(entries []entry) {
+	moved := make([]int, len(c.code)+1) // new index of the first entry kept at or after each index of the emitted code
+	for i, j := 0, 0; i <= len(c.code); i++ {
+		for j < len(entries) && entries[j].index < i {
+			j++
+		}
+		moved[i] = j
+	}
+	for i := range c.symbols {
+		c.symbols[i].from = moved[c.symbols[i].from]
+	}
+	for s, end := range c.scopeEnds {
+		c.scopeEnds[s] = moved[end]
+	}
+
+	c.code, c.origins = make([]botlang.Instruction, len(entries)), make([]origin, len(entries))
+	for i, e := range entries {
+		c.code[i], c.origins[i] = e.instruction, e.origin
+	}
+}
+
+// rewrite applies the first matching rule at every position of the entries
+func (c *Compiler) rewrite(entries []entry, rules []rule) ([]entry, bool) {
+	rewritten := make([]entry, 0, len(entries))
+	changed := false
+
+	for i := 0; i < len(entries); {
+		replaced := 0
+		for _, r := range rules {
+			var replacement []entry
+			if replacement, replaced = r(c, entries[i:]); replaced != 0 {
+				rewritten = append(rewritten, replacement...)
+				break
+			}
+		}
+
+		if replaced == 0 {
+			rewritten = append(rewritten, entries[i])
+			replaced = 1
+		} else {
+			changed = true
+		}
+		i += replaced
+	}
+	return rewritten, changed
+}
+
+// dropUnusedLabels keeps labels of jumps, calls, splits, forks, functions and the ends of the program
+func (c *Compiler) dropUnusedLabels(entries []entry) []entry {
+	used := map[string]bool{BEGIN_LABEL: true, END_LABEL: true}
+	for key, definition := range c.definitions {
+		if label, ok := key.(string); ok && definition.Kind == FUNCTION_DEFINITION {
+			used[label] = true
+		}
+	}
+	for _, e := range entries {
+		if label, ok := e.instruction.Target(); ok {
+			used[label] = true
+		}
+	}
+
+	kept := make([]entry, 0, len(entries))
+	for _, e := range entries {
+		if !e.instruction.IsLabel() || used[e.instruction.Label] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// general registers hold values of expressions only, writing them has no effect on the bot
+func general(operand botlang.Operand) bool {
+	return operand.Kind == botlang.REGISTER && (operand.Register == AX || operand.Register == BX)
+}
+
+// loads returns the register written by a load which doesn't read it
+func loads(instruction botlang.Instruction) (botlang.Operand, bool) {
+	switch instruction.Opcode {
+	case LOAD_TO_REG_FROM_VAL, LOAD_TO_REG_FROM_MEM:
+		return instruction.Operands[0], true
+	case LOAD_TO_REG_FROM_REG:
+		return instruction.Operands[0], instruction.Operands[0] != instruction.Operands[1]
+	default:
+		return botlang.Operand{}, false
+	}
+}
+
+// dropSelfMove drops `ld AX AX`
+func dropSelfMove(c *Compiler, entries []entry) ([]entry, int) {
+	instruction := entries[0].instruction
+	if instruction.Opcode == LOAD_TO_REG_FROM_REG && instruction.Operands[0] == instruction.Operands[1] {
+		return nil, 1
+	}
+	return nil, 0
+}
+
+// dropOverwrittenLoad drops `ldv AX 1` followed by `ldm AX [9]`
+func dropOverwrittenLoad(c *Compiler, entries []entry) ([]entry, int) {
+	if len(entries) < 2 {
+		return nil, 0
+	}
+
+	first, ok := loads(entries[0].instruction)
+	if !ok || !general(first) {
+		return nil, 0
+	}
+	if second, ok := loads(entries[1].instruction); !ok || second != first {
+		return nil, 0
+	}
+	return nil, 1
+}
+
+// dropReload drops `ldm AX [9]` following `ldr [9] AX` and `ldr [9] AX` following `ldm AX [9]`
+func dropReload(c *Compiler, entries []entry) ([]entry, int) {
+	if len(entries) < 2 {
+		return nil, 0
+	}
+
+	first, second := entries[0].instruction, entries[1].instruction
+	var register, memory botlang.Operand
+	switch {
+	case first.Opcode == LOAD_TO_MEM_FROM_REG && second.Opcode == LOAD_TO_REG_FROM_MEM:
+		memory, register = first.Operands[0], first.Operands[1]
+	case first.Opcode == LOAD_TO_REG_FROM_MEM && second.Opcode == LOAD_TO_MEM_FROM_REG:
+		register, memory = first.Operands[0], first.Operands[1]
+	default:
+		return nil, 0
+	}
+
+	if !general(register) || !slices.Contains(second.Operands, register) || !slices.Contains(second.Operands, memory) {
+		return nil, 0
+	}
+	return entries[:1], 2
+}
+
+// dropJumpToNext drops a jump to one of the labels right after it, the comparison before it is kept
+func dropJumpToNext(c *Compiler, entries []entry) ([]entry, int) {
+	instruction := entries[0].instruction
+	if !botlang.IsJump(instruction.Opcode) {
+		return nil, 0
+	}
+
+	target, _ := instruction.Target()
+	for _, e := range entries[1:] {
+		if !e.instruction.IsLabel() {
+			break
+		}
+		if e.instruction.Label == target {
+			return nil, 1
+		}
+	}
+	return nil, 0
+}
+
+// keepOperandInRegister rewrites the left operand put on the stack while a simple right operand is loaded,
+// `ldr [0] AX`, `ldv AX 2`, `ld BX AX`, `ldm AX [0]` becomes `ldv BX 2`
+func keepOperandInRegister(c *Compiler, entries []entry) ([]entry, int) {
+	if len(entries) < 4 {
+		return nil, 0
+	}
+
+	spill, right, move, reload := entries[0].instruction, entries[1].instruction, entries[2].instruction, entries[3].instruction
+	if spill.Opcode != LOAD_TO_MEM_FROM_REG || !c.temporary(spill.Operands[0].Value) || !general(spill.Operands[1]) {
+		return nil, 0
+	}
+	left, buffer := spill.Operands[1], spill.Operands[0]
+
+	if right.Opcode != LOAD_TO_REG_FROM_VAL && right.Opcode != LOAD_TO_REG_FROM_MEM || right.Operands[0] != left || right.Operands[1] == buffer {
+		return nil, 0
+	}
+	if move.Opcode != LOAD_TO_REG_FROM_REG || move.Operands[1] != left || !general(move.Operands[0]) || move.Operands[0] == left {
+		return nil, 0
+	}
+	if reload.Opcode != LOAD_TO_REG_FROM_MEM || reload.Operands[0] != left || reload.Operands[1] != buffer {
+		return nil, 0
+	}
+
+	operand := entries[1]
+	operand.instruction = botlang.New(right.Opcode, move.Operands[0], right.Operands[1])
+	return []entry{operand}, 4
+}
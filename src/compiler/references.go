@@ -0,0 +1,164 @@
+package compiler
+
+import (
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"bytes"
+	"slices"
+)
+
+const (
+	VARIABLE_DEFINITION = "variable"
+	FUNCTION_DEFINITION = "function"
+	SCOPE_DEFINITION    = "scope"
+	ALIAS_DEFINITION    = "alias"
+)
+
+// Definition is a declared entity of the program the way the compiler has resolved it
+type Definition struct {
+	Kind      string
+	Name      string // path through scopes, e.g. "food::berry::isTasty"
+	Type      string // type of variable or alias, return type of function
+	Signature string // declaration in NiLang, e.g. "Int x" or "Fun Inc::Int$ x Int"
+
+	File   string // empty for the file compiled by Compile
+	Line   int    // position of the declared name, zero line for builtins
+	Offset int
+}
+
+// Reference links a name in the source to its definition
+type Reference struct {
+	File   string // empty for the file compiled by Compile
+	Line   int
+	Offset int
+	Length int
+
+	Definition Definition
+}
+
+// Import is a Using statement, it is used when a name has been found in the imported scope through it
+type Import struct {
+	Name string
+	Used bool
+
+	File   string // empty for the file compiled by Compile
+	Line   int    // position of the Using keyword
+	Offset int
+}
+
+type imported struct {
+	token tokens.Token
+	file  string
+	name  string
+	owner *scope // scope containing the statement
+	scope *scope
+}
+
+// References lists every resolved name of the last compilation including names of declarations
+func (c *Compiler) References() []Reference {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.references)
+}
+
+// Imports lists Using statements of the last compilation
+func (c *Compiler) Imports() []Import {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	imports := make([]Import, 0, len(c.imports))
+	for _, i := range c.imports {
+		imports = append(imports, Import{Name: i.name, Used: i.owner.usedScopes[i.scope], File: i.file, Line: i.token.Line, Offset: i.token.Offset})
+	}
+	return imports
+}
+
+// Label returns the label of the function declared by the program with the path, e.g. "food::Eat"
+func (c *Compiler) Label(path string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, definition := range c.definitions {
+		label, ok := key.(string)
+		if ok && definition.Kind == FUNCTION_DEFINITION && definition.Name == path && definition.Line != 0 {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// define remembers the declaration of variable (by address), function (by label) or scope
+func (c *Compiler) define(key any, kind string, path string, t string, signature string, token tokens.Token) Definition {
+	definition := Definition{Kind: kind, Name: path, Type: t, Signature: signature, File: c.file, Line: token.Line, Offset: token.Offset}
+	c.definitions[key] = definition
+
+	if token.Line != 0 {
+		c.refer(token, key)
+	}
+	return definition
+}
+
+// previous points at the declaration of the key for errors of redeclaration
+func (c *Compiler) previous(key any) []helper.Related {
+	definition, ok := c.definitions[key]
+	if !ok || definition.Line == 0 {
+		return nil
+	}
+	return []helper.Related{{File: definition.File, Line: definition.Line, Offset: definition.Offset, Description: "previously declared here"}}
+}
+
+func (c *Compiler) previousVariable(name name) []helper.Related {
+	if v, ok := c.scope.getLocalVariable(name); ok {
+		return c.previous(v.Addr)
+	}
+	return nil
+}
+
+func (c *Compiler) previousScope(parent *scope, name name) []helper.Related {
+	if s, ok := parent.getLocalScope(name); ok {
+		return c.previous(s)
+	}
+	return nil
+}
+
+func (c *Compiler) refer(token tokens.Token, key any) {
+	if definition, ok := c.definitions[key]; ok {
+		c.references = append(c.references, Reference{
+			File:       c.file,
+			Line:       token.Line,
+			Offset:     token.Offset,
+			Length:     len(token.Literal),
+			Definition: definition})
+	}
+}
+
+func (c *Compiler) defineVariable(token tokens.Token, v variable) {
+	c.define(v.Addr, VARIABLE_DEFINITION, qualify(c.scope, v.Name), v.Type.String(), v.Type.String()+" "+v.Name, token)
+}
+
+func (c *Compiler) defineFunction(token tokens.Token, f function) {
+	var signature bytes.Buffer
+	signature.WriteString("Fun " + f.Name)
+	if f.Type != VOID {
+		signature.WriteString("::" + f.Type.String())
+	}
+
+	for i, arg := range f.Arguments {
+		if i == 0 {
+			signature.WriteString("$ ")
+		} else {
+			signature.WriteString(", ")
+		}
+		signature.WriteString(arg.Name + " " + arg.Type.String())
+	}
+
+	c.define(f.Label, FUNCTION_DEFINITION, qualify(c.scope, f.Name), f.Type.String(), signature.String(), token)
+}
+
+func qualify(s *scope, n name) string {
+	if path := scopePath(s); path != "" {
+		return path + "::" + n
+	}
+	return n
+}
@@ -1,15 +1,17 @@
 package compiler
 
-type register = string
+import "NiLang/src/botlang"
+
+type register = botlang.Register
 
 const (
-	AX = "AX"
-	BX = "BX"
-	CX = "CX" // flag for bot's memory being ready for reading
-	DX = "DX" // bot's memory
-
-	SD = "SD"
-	MD = "MD"
-	EN = "EN"
-	AG = "AG"
+	AX = botlang.AX
+	BX = botlang.BX
+	CX = botlang.CX // flag for bot's memory being ready for reading
+	DX = botlang.DX // bot's memory
+
+	SD = botlang.SD
+	MD = botlang.MD
+	EN = botlang.EN
+	AG = botlang.AG
 )
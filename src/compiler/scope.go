@@ -12,6 +12,7 @@ type scope struct {
 	functions map[name]function
 
 	usingScopes []*scope
+	usedScopes  map[*scope]bool // imported scopes a name has been found in
 
 	escapeLabel string //used by Break and Continue in While loop
 	repeatLabel string
@@ -27,6 +28,7 @@ func newScope(n name) *scope {
 		variables:   make(map[name]variable),
 		functions:   make(map[name]function),
 		usingScopes: make([]*scope, 0),
+		usedScopes:  make(map[*scope]bool),
 		escapeLabel: "",
 		repeatLabel: "",
 		parent:      nil,
@@ -40,6 +42,7 @@ func (s *scope) GetVariable(name name) (variable, bool) {
 
 	for _, scope := range s.usingScopes {
 		if variable, ok := scope.getLocalVariable(name); ok {
+			s.usedScopes[scope] = true
 			return variable, true
 		}
 	}
@@ -57,6 +60,7 @@ func (s *scope) GetFunction(name name) (function, bool) {
 
 	for _, scope := range s.usingScopes {
 		if function, ok := scope.getLocalFunction(name); ok {
+			s.usedScopes[scope] = true
 			return function, true
 		}
 	}
@@ -122,10 +126,12 @@ func (s *scope) GetScope(name name) (*scope, bool) {
 
 	for _, scope := range s.usingScopes {
 		if scope.name == name {
+			s.usedScopes[scope] = true
 			return scope, true
 		}
 
 		if _scope, ok := scope.getLocalScope(name); ok {
+			s.usedScopes[scope] = true
 			return _scope, true
 		}
 	}
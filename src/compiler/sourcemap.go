@@ -0,0 +1,158 @@
+package compiler
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/tokens"
+	"strings"
+)
+
+const SOURCE_MAP_VERSION = 1
+
+// SourceMap links commands of the compiled program to the NiLang source they came from
+type SourceMap struct {
+	Version  int       `json:"version"`
+	Mappings []Mapping `json:"mappings"`
+}
+
+type Mapping struct {
+	Instruction int    `json:"instruction"` // index of the command, labels are not counted
+	Statement   int    `json:"statement"`   // commands of the same statement share its number
+	OutputLine  int    `json:"outputLine"`  // line of the command in the .tor file
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"` // counted from zero as in error messages
+	Function    string `json:"function,omitempty"`
+	Scope       string `json:"scope,omitempty"` // names of enclosing Scope blocks joined with "::"
+}
+
+// origin is the part of the source being compiled while a command is emitted
+type origin struct {
+	file      string // empty for the only file of the program
+	token     tokens.Token
+	statement int
+	function  name
+	scopes    []name
+}
+
+// SourceMap describes the code of the last compilation, commands emitted
+// for builtins before the first statement have no source and are skipped.
+// The file names the source compiled by Compile, CompileFiles knows names of its files
+func (c *Compiler) SourceMap(file string) SourceMap {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sourceMap := SourceMap{Version: SOURCE_MAP_VERSION, Mappings: make([]Mapping, 0, len(c.code))}
+
+	instruction := 0
+	for i, command := range c.code {
+		if command.IsLabel() {
+			continue
+		}
+
+		if origin := c.origins[i]; origin.token.Line != 0 {
+			source := file
+			if origin.file != "" {
+				source = origin.file
+			}
+
+			sourceMap.Mappings = append(sourceMap.Mappings, Mapping{
+				Instruction: instruction,
+				Statement:   origin.statement,
+				OutputLine:  i + 1,
+				File:        source,
+				Line:        origin.token.Line,
+				Column:      origin.token.Offset,
+				Function:    origin.function,
+				Scope:       strings.Join(origin.scopes, "::"),
+			})
+		}
+		instruction++
+	}
+
+	return sourceMap
+}
+
+// locate makes following commands point at the node and returns function restoring the previous location
+func (c *Compiler) locate(node ast.Node) func() {
+	previous := c.origin.token
+	if token, ok := tokenOf(node); ok {
+		c.origin.token = token
+		c.located = token
+	}
+
+	return func() { c.origin.token = previous }
+}
+
+// beginStatement numbers commands of the next statement and points them at it
+func (c *Compiler) beginStatement(node ast.Node) func() {
+	previous := c.origin.statement
+	c.statements++
+	c.origin.statement = c.statements
+	restore := c.locate(node)
+
+	return func() {
+		restore()
+		c.origin.statement = previous
+	}
+}
+
+func (c *Compiler) enterFunctionOrigin(function name) func() {
+	previous := c.origin.function
+	c.origin.function = function
+
+	return func() { c.origin.function = previous }
+}
+
+func (c *Compiler) enterScopeOrigin(scope name) func() {
+	previous := c.origin.scopes
+	c.origin.scopes = append(previous[:len(previous):len(previous)], scope)
+
+	return func() { c.origin.scopes = previous }
+}
+
+func tokenOf(node ast.Node) (tokens.Token, bool) {
+	switch n := node.(type) {
+	case *ast.DeclarationStatement:
+		return n.Var.Token, true
+	case *ast.AssignmentStatement:
+		return n.Name.Token, true
+	case *ast.ExpressionStatement:
+		return n.Token, true
+	case *ast.ReturnStatement:
+		return n.Token, true
+	case *ast.UsingStatement:
+		return n.Token, true
+	case *ast.ScopeStatement:
+		return n.Token, true
+	case *ast.WhileStatement:
+		return n.Token, true
+	case *ast.AliasStatement:
+		return n.Token, true
+	case *ast.FunctionStatement:
+		return n.Token, true
+	case *ast.IfStatement:
+		return n.Token, true
+	case *ast.ElifStatement:
+		return n.Token, true
+	case *ast.BreakStatement:
+		return n.Token, true
+	case *ast.ContinueStatement:
+		return n.Token, true
+	case *ast.Identifier:
+		return n.Token, true
+	case *ast.IntegralLiteral:
+		return n.Token, true
+	case *ast.BooleanLiteral:
+		return n.Token, true
+	case *ast.PrefixExpression:
+		return n.Token, true
+	case *ast.InfixExpression:
+		return n.Token, true
+	case *ast.CallExpression:
+		return n.Token, true
+	case *ast.ScopeExpression:
+		return n.Token, true
+	default:
+		return tokens.Token{}, false
+	}
+}
@@ -0,0 +1,67 @@
+package compiler
+
+import "strings"
+
+// Symbol is a variable of the compiled program and the part of the code it is visible in
+type Symbol struct {
+	Name     string
+	Scope    string // names of enclosing scopes joined with "::", e.g. "food::berry"
+	Type     string
+	Addr     int
+	Constant bool // values of aliases and directions
+	From, To int  // visible for instructions in [From, To), labels are not counted
+}
+
+type symbol struct {
+	variable
+	scope    *scope
+	from     int // index in code
+	constant bool
+}
+
+// Symbols lists variables declared during the last compilation
+func (c *Compiler) Symbols() []Symbol {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	instructions := make([]int, len(c.code)+1) // number of commands before each index of code
+	for i, command := range c.code {
+		instructions[i+1] = instructions[i]
+		if !command.IsLabel() {
+			instructions[i+1]++
+		}
+	}
+
+	symbols := make([]Symbol, 0, len(c.symbols))
+	for _, s := range c.symbols {
+		to, ok := c.scopeEnds[s.scope]
+		if !ok {
+			to = len(c.code)
+		}
+
+		symbols = append(symbols, Symbol{
+			Name:     s.Name,
+			Scope:    scopePath(s.scope),
+			Type:     s.Type.String(),
+			Addr:     s.Addr,
+			Constant: s.constant,
+			From:     instructions[s.from],
+			To:       instructions[to],
+		})
+	}
+	return symbols
+}
+
+func (c *Compiler) addSymbol(s *scope, v variable, constant bool) {
+	c.symbols = append(c.symbols, symbol{variable: v, scope: s, from: len(c.code), constant: constant})
+}
+
+func scopePath(s *scope) string {
+	names := make([]string, 0)
+	for ; s != nil; s = s.GetParent() {
+		if s.name != "" {
+			names = append([]string{s.name}, names...)
+		}
+	}
+	return strings.Join(names, "::")
+}
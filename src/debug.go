@@ -0,0 +1,508 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/compiler"
+	"NiLang/src/vm"
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func debug(args []string) {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	stackSize := flags.Int("s", compiler.AUTO_STACK_SIZE, "stack size in bytes, measured from the program if 0")
+	memorySize := flags.Int("m", vm.DefaultMemorySize, "memory size of the virtual machine")
+	optimization := flags.Int("O", compiler.DEFAULT_OPTIMIZATION, "optimization level, 0 keeps code as emitted, 1 removes redundant commands, 2 also keeps operands in registers")
+	frames := flags.Int("frames", compiler.DEFAULT_FRAMES, "calls of a recursive function which may run at once, each copies the memory of the function, 0 makes recursion an error")
+	steps := flags.Int("steps", 100000, "maximum number of instructions executed by one command")
+	energy := flags.Int("energy", 1000, "energy level reported to the bot")
+	sensors := flags.String("sensors", "", "file with answers of sensors, one checked cell per line")
+	directories := domainFlags(flags)
+	report := diagnosticsFlag(flags)
+	files := parseFlags(flags, args)
+
+	if len(files) != 1 {
+		log.Fatal("Expected argument with path to .nil file to debug")
+	}
+
+	c, code, ok := compileFile(files[0], directories(files[0]), *stackSize, *memorySize, *optimization, *frames, false, report())
+	if !ok {
+		os.Exit(1)
+	}
+
+	program, err := vm.Parse(code)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	world := &scriptedWorld{consoleWorld: consoleWorld{energy: *energy}}
+	if *sensors != "" {
+		world.answers = readSensors(*sensors)
+	}
+
+	source, err := os.ReadFile(files[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d := newDebugger(vm.New(program, world, *memorySize), c, files[0], source)
+	d.steps = *steps
+	d.serve(os.Stdin)
+}
+
+type debugger struct {
+	machine  *vm.VM
+	fileName string
+	source   []string
+
+	locations []*compiler.Mapping // source of each instruction, nil for builtins
+	starts    []bool              // instruction is the first one of a statement
+	symbols   []compiler.Symbol
+
+	breakpoints map[int]bool // lines of the source
+	steps       int
+}
+
+func newDebugger(machine *vm.VM, c *compiler.Compiler, fileName string, source []byte) *debugger {
+	size := len(machine.Program().Instructions)
+	d := &debugger{
+		machine:     machine,
+		fileName:    fileName,
+		source:      strings.Split(string(source), "\n"),
+		locations:   make([]*compiler.Mapping, size),
+		starts:      make([]bool, size),
+		symbols:     c.Symbols(),
+		breakpoints: make(map[int]bool),
+	}
+
+	main, err := filepath.Abs(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	statements := make(map[int]bool)
+	mappings := c.SourceMap(main).Mappings
+	for i := range mappings {
+		mapping := &mappings[i]
+		if mapping.File != main {
+			continue // code of domains is stepped over like builtins
+		}
+		d.locations[mapping.Instruction] = mapping
+
+		if !statements[mapping.Statement] {
+			statements[mapping.Statement] = true
+			d.starts[mapping.Instruction] = true
+		}
+	}
+	return d
+}
+
+const debuggerHelp = `Commands:
+  break LINE     stop before the statement at the line of the source (b)
+  delete [LINE]  remove the breakpoint or all of them (d)
+  continue       run until a breakpoint (c)
+  step           run until the next statement, entering functions (s)
+  next           run until the next statement of the current function (n)
+  stepi          execute one botlang instruction (si)
+  print NAME...  print variables, e.g. x or food::berry::isTasty (p)
+  locals         print all variables visible at the current statement
+  registers      print registers of the virtual machine (r)
+  list [LINE]    print the source around the line (l)
+  where          print the current position (w)
+  quit           leave the debugger (q)`
+
+func (d *debugger) serve(input io.Reader) {
+	if d.isStop(d.machine.PC(), func() bool { return true }) {
+		d.where()
+	} else {
+		d.resume(func() bool { return true })
+	}
+
+	scanner := bufio.NewScanner(input)
+	for {
+		fmt.Print("(debug) ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if !d.execute(fields[0], fields[1:]) {
+			return
+		}
+	}
+}
+
+// execute runs the command of the debugger and reports whether debugging goes on
+func (d *debugger) execute(command string, args []string) bool {
+	switch command {
+	case "break", "b":
+		line, ok := d.parseLine(args)
+		if !ok {
+			return true
+		}
+		if !d.hasStatementAt(line) {
+			fmt.Printf("no statement at line %d\n", line)
+			return true
+		}
+		d.breakpoints[line] = true
+		fmt.Printf("breakpoint at %s:%d\n", d.fileName, line)
+	case "delete", "d":
+		if len(args) == 0 {
+			d.breakpoints = make(map[int]bool)
+			return true
+		}
+		if line, ok := d.parseLine(args); ok {
+			delete(d.breakpoints, line)
+		}
+	case "continue", "c":
+		d.resume(func() bool { return d.breakpoints[d.location().Line] })
+	case "step", "s":
+		d.resume(func() bool { return true })
+	case "next", "n":
+		depth := d.machine.Depth()
+		d.resume(func() bool { return d.machine.Depth() <= depth })
+	case "stepi", "si":
+		d.resume(nil)
+	case "print", "p":
+		if len(args) == 0 {
+			fmt.Println("expected name of the variable")
+		}
+		for _, name := range args {
+			d.print(name)
+		}
+	case "locals":
+		d.printLocals()
+	case "registers", "r":
+		for _, register := range botlang.Registers {
+			fmt.Printf("%s=%d ", register, d.machine.Register(register))
+		}
+		fmt.Println()
+	case "list", "l":
+		line := d.location().Line
+		if len(args) != 0 {
+			var ok bool
+			if line, ok = d.parseLine(args); !ok {
+				return true
+			}
+		}
+		d.list(line)
+	case "where", "w":
+		d.where()
+	case "help", "h":
+		fmt.Println(debuggerHelp)
+	case "quit", "q":
+		return false
+	default:
+		fmt.Printf("unknown command %q, type help to list commands\n", command)
+	}
+	return true
+}
+
+// resume executes instructions until the first instruction of a statement satisfying stop,
+// nil stop makes it execute a single instruction
+func (d *debugger) resume(stop func() bool) {
+	for i := 0; ; i++ {
+		if d.machine.Halted() {
+			fmt.Printf("program has finished after %d steps\n", d.machine.Steps())
+			return
+		}
+
+		if i == d.steps {
+			fmt.Printf("paused after %d instructions\n", d.steps)
+			break
+		}
+
+		if _, err := d.machine.Step(); err != nil {
+			fmt.Printf("runtime error: %s\n", err)
+			break
+		}
+
+		if stop == nil || d.machine.Halted() || d.isStop(d.machine.PC(), stop) {
+			break
+		}
+	}
+
+	if !d.machine.Halted() {
+		d.where()
+	} else {
+		fmt.Printf("program has finished after %d steps\n", d.machine.Steps())
+	}
+}
+
+func (d *debugger) isStop(pc int, stop func() bool) bool {
+	return pc < len(d.starts) && d.starts[pc] && stop()
+}
+
+// location returns source of the current instruction, empty for builtins
+func (d *debugger) location() compiler.Mapping {
+	pc := d.machine.PC()
+	if pc < len(d.locations) && d.locations[pc] != nil {
+		return *d.locations[pc]
+	}
+	return compiler.Mapping{}
+}
+
+func (d *debugger) where() {
+	pc := d.machine.PC()
+	location := d.location()
+
+	if location.Line == 0 {
+		fmt.Printf("instruction %d has no source\n", pc)
+	} else {
+		fmt.Printf("%s:%d:%d", d.fileName, location.Line, location.Column)
+		if location.Function != "" {
+			function := location.Function
+			if location.Scope != "" {
+				function = location.Scope + "::" + function
+			}
+			fmt.Printf(" in %s", function)
+		}
+		fmt.Println()
+		fmt.Printf("%5d\t%s\n", location.Line, d.line(location.Line))
+	}
+
+	if !d.machine.Halted() {
+		fmt.Printf("%5d:\t%s\n", pc, d.machine.Program().Instructions[pc])
+	}
+}
+
+func (d *debugger) list(line int) {
+	current := d.location().Line
+	for i := max(line-5, 1); i <= min(line+5, len(d.source)); i++ {
+		marker := "  "
+		if i == current {
+			marker = "=>"
+		} else if d.breakpoints[i] {
+			marker = " *"
+		}
+		fmt.Printf("%s%5d\t%s\n", marker, i, d.line(i))
+	}
+}
+
+func (d *debugger) line(line int) string {
+	if line < 1 || line > len(d.source) {
+		return ""
+	}
+	return strings.TrimRight(d.source[line-1], "\r")
+}
+
+func (d *debugger) parseLine(args []string) (int, bool) {
+	if len(args) != 1 {
+		fmt.Println("expected line of the source")
+		return 0, false
+	}
+
+	line, err := strconv.Atoi(args[0])
+	if err != nil || line < 1 {
+		fmt.Printf("expected line of the source, got %q\n", args[0])
+		return 0, false
+	}
+	return line, true
+}
+
+func (d *debugger) hasStatementAt(line int) bool {
+	for pc, location := range d.locations {
+		if location != nil && location.Line == line && d.starts[pc] {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup finds variable by its name or by its path through scopes, names
+// without scopes are searched in the innermost scope of the current instruction
+func (d *debugger) lookup(path string) (compiler.Symbol, bool) {
+	names := strings.Split(path, "::")
+	name := names[len(names)-1]
+	scope := strings.Join(names[:len(names)-1], "::")
+
+	var found compiler.Symbol
+	ok := false
+	for _, symbol := range d.symbols {
+		if symbol.Name != name {
+			continue
+		}
+
+		if scope != "" {
+			if symbol.Scope == scope || strings.HasSuffix(symbol.Scope, "::"+scope) {
+				return symbol, true
+			}
+			continue
+		}
+
+		if !symbol.Constant && d.isVisible(symbol) && (!ok || symbol.To-symbol.From < found.To-found.From) {
+			found, ok = symbol, true
+		}
+	}
+	return found, ok
+}
+
+func (d *debugger) isVisible(symbol compiler.Symbol) bool {
+	pc := min(d.machine.PC(), len(d.starts)-1) // global variables stay visible once the program is over
+	return symbol.From <= pc && pc < symbol.To
+}
+
+func (d *debugger) print(name string) {
+	symbol, ok := d.lookup(name)
+	if !ok {
+		fmt.Printf("no variable %q in the current scope\n", name)
+		return
+	}
+	fmt.Printf("%s %s = %s\n", symbol.Type, name, d.format(symbol))
+}
+
+func (d *debugger) printLocals() {
+	visible := make([]compiler.Symbol, 0)
+	for _, symbol := range d.symbols {
+		if shadow, ok := d.lookup(symbol.Name); ok && shadow == symbol {
+			visible = append(visible, symbol)
+		}
+	}
+
+	if len(visible) == 0 {
+		fmt.Println("no variables")
+		return
+	}
+
+	sort.SliceStable(visible, func(i, j int) bool { return visible[i].From < visible[j].From })
+	for _, symbol := range visible {
+		fmt.Printf("%s %s = %s\n", symbol.Type, symbol.Name, d.format(symbol))
+	}
+}
+
+// format shows the value of the variable according to its type,
+// values of aliases and directions are shown by their names
+func (d *debugger) format(symbol compiler.Symbol) string {
+	value, ok := d.machine.Memory(symbol.Addr)
+	if !ok {
+		return fmt.Sprintf("<address %d is out of memory>", symbol.Addr)
+	}
+	return formatValue(d.machine, d.symbols, symbol.Type, value)
+}
+
+// formatValue prints Bool as True/False and values of aliases by their names, e.g. dir::front
+func formatValue(machine *vm.VM, symbols []compiler.Symbol, t string, value int) string {
+	switch t {
+	case compiler.Int:
+		return strconv.Itoa(value)
+	case compiler.Bool:
+		switch value {
+		case compiler.BOOL_TRUE:
+			return "True"
+		case compiler.BOOL_FALSE:
+			return "False"
+		}
+	}
+
+	for _, constant := range symbols {
+		if !constant.Constant || constant.Type != t {
+			continue
+		}
+
+		if v, _ := machine.Memory(constant.Addr); v == value {
+			return constant.Scope + "::" + constant.Name
+		}
+	}
+	return strconv.Itoa(value)
+}
+
+type sensorAnswer struct {
+	cell vm.Cell
+	text string
+}
+
+// scriptedWorld answers checks of cells from the script, cells are empty once the script is over
+type scriptedWorld struct {
+	consoleWorld
+	answers []sensorAnswer
+	checks  int
+}
+
+func (w *scriptedWorld) Check(direction botlang.Direction) vm.Cell {
+	answer := sensorAnswer{cell: vm.Cell{Empty: true}, text: "empty"}
+	if w.checks < len(w.answers) {
+		answer = w.answers[w.checks]
+	}
+	w.checks++
+
+	fmt.Printf("[check %d] %s: %s\n", w.checks, direction, answer.text)
+	return answer.cell
+}
+
+// readSensors parses the script of sensor answers, every line describes a checked cell with
+// words empty, wall, bot, friend, sibling and optional luminosity=N and minerals=N,
+// lines starting with # are comments
+func readSensors(fileName string) []sensorAnswer {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	answers := make([]sensorAnswer, 0)
+	for i, line := range strings.Split(string(data), "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cell, err := parseCell(strings.Fields(text))
+		if err != nil {
+			log.Fatalf("%s:%d: %s", fileName, i+1, err)
+		}
+		answers = append(answers, sensorAnswer{cell: cell, text: text})
+	}
+	return answers
+}
+
+func parseCell(fields []string) (vm.Cell, error) {
+	cell := vm.Cell{Empty: true}
+
+	for _, field := range fields {
+		key, value, hasValue := strings.Cut(field, "=")
+		if hasValue {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return cell, fmt.Errorf("expected number in %q", field)
+			}
+
+			switch key {
+			case "luminosity":
+				cell.Luminosity = number
+			case "minerals":
+				cell.Mineralization = number
+			default:
+				return cell, fmt.Errorf("unknown property of the cell %q", key)
+			}
+			continue
+		}
+
+		switch field {
+		case "empty":
+			cell.Empty = true
+		case "wall", "bot":
+			cell.Empty = false
+		case "friend":
+			cell.Empty, cell.Friend = false, true
+		case "sibling":
+			cell.Empty, cell.Sibling = false, true
+		default:
+			return cell, fmt.Errorf("unknown kind of the cell %q", field)
+		}
+	}
+	return cell, nil
+}
@@ -0,0 +1,552 @@
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// codes of errors and warnings, a code is never reused for another kind of diagnostics once released
+const (
+	// syntax
+	TABULATION        = "NL0001"
+	INDENTATION       = "NL0002"
+	UNEXPECTED_INDENT = "NL0003"
+	UNEXPECTED_TOKEN  = "NL0004"
+	INVALID_LITERAL   = "NL0005"
+
+	// declarations and types
+	UNDECLARED          = "NL0010"
+	REDECLARATION       = "NL0011"
+	TYPE_MISMATCH       = "NL0012"
+	ARGUMENT_COUNT      = "NL0013"
+	MISSING_RETURN      = "NL0014"
+	MISPLACED_STATEMENT = "NL0015"
+	INVALID_ALIAS       = "NL0016"
+	INVALID_USING       = "NL0017"
+	CONSTANT_ASSIGNMENT = "NL0018"
+
+	// domains
+	IMPORT_CYCLE   = "NL0020"
+	INVALID_DOMAIN = "NL0021"
+
+	// limits of the machine
+	STACK_OVERFLOW  = "NL0030"
+	MEMORY_OVERFLOW = "NL0031"
+	RECURSION       = "NL0032"
+
+	// values known at compile time
+	DIVISION_BY_ZERO = "NL0040"
+
+	// bugs of the compiler
+	INTERNAL_ERROR = "NL0090"
+
+	// warnings of vet
+	UNUSED_VARIABLE = "NL0101"
+	UNUSED_FUNCTION = "NL0102"
+	UNREACHABLE     = "NL0103"
+	SHADOW          = "NL0104"
+	UNUSED_RESULT   = "NL0105"
+	UNUSED_USING    = "NL0106"
+
+	// warnings of the compiler
+	CONSTANT_CONDITION = "NL0110"
+)
+
+// Code describes a kind of diagnostics for `nilang explain`
+type Code struct {
+	ID          string
+	Title       string
+	Explanation string // long description followed by examples
+}
+
+var CODES = []Code{
+	{TABULATION, "tabulation in the source", `
+Blocks of NiLang are set by indentation of 4 whitespaces, a tabulation is not
+allowed anywhere in the source, neither in indentation nor between tokens.
+The rest of the line is checked as if the tabulation were 4 whitespaces.
+
+Erroneous code, the body is indented with a tabulation:
+
+    If True:
+    	bot::Sleep
+
+Fixed code:
+
+    If True:
+        bot::Sleep
+`},
+	{INDENTATION, "indentation is not a multiple of 4 whitespaces", `
+Every level of indentation is exactly 4 whitespaces. A line indented by
+another number of whitespaces is read as if it were indented to the nearest
+level. The formatter reports this error for a line whose indentation doesn't
+match any enclosing block.
+
+Erroneous code:
+
+    While True:
+      bot::Sleep
+
+Fixed code:
+
+    While True:
+        bot::Sleep
+`},
+	{UNEXPECTED_INDENT, "unexpected level of indentation", `
+A statement ending with a colon is followed by a block indented by one level
+deeper than the statement, other lines continue the current block or close
+it and never start a deeper one.
+
+Erroneous code, the block is indented by two levels:
+
+    Fun Eat:
+            bot::Eat$ dir::front
+
+Erroneous code, the line is not a part of any block:
+
+    Int x = 1
+        Int y = 2
+
+Fixed code:
+
+    Fun Eat:
+        bot::Eat$ dir::front
+`},
+	{UNEXPECTED_TOKEN, "unexpected token", `
+The parser has found a token which can't continue the statement. The rest of
+the line is skipped and the next statement of the block is checked.
+
+Erroneous code, the arguments of a call must follow the dollar sign and a
+declaration must have a value:
+
+    bot::Move dir::front
+    Int x 1
+
+Fixed code:
+
+    bot::Move$ dir::front
+    Int x = 1
+`},
+	{INVALID_LITERAL, "literal is out of range", `
+An integer literal must fit into the integer of the virtual machine.
+
+Erroneous code:
+
+    Int x = 99999999999999999999
+
+Fixed code:
+
+    Int x = 9999
+`},
+	{UNDECLARED, "undeclared name", `
+A variable, a function, a type, a scope or an alias is used but it is not
+declared in the current block, in an enclosing block or in a scope made
+visible by a Using statement. Names are case-sensitive and a variable is
+visible only after its declaration.
+
+Erroneous code:
+
+    Int x = y + 1
+    bot::Mov$ dir::front
+
+Fixed code:
+
+    Int y = 1
+    Int x = y + 1
+    bot::Move$ dir::front
+`},
+	{REDECLARATION, "redeclaration of a name", `
+A block can't declare two variables, functions, scopes or aliases with the
+same name, the error points at the previous declaration as well. Inner blocks
+may declare a name of an enclosing block, see NL0104.
+
+Erroneous code:
+
+    Int x = 1
+    Int x = 2
+
+    Fun Move:
+        bot::Move$ dir::front
+    Fun Move:
+        bot::Move$ dir::back
+
+Fixed code:
+
+    Int x = 1
+    x = 2
+
+    Fun Move:
+        bot::Move$ dir::front
+    Fun MoveBack:
+        bot::Move$ dir::back
+`},
+	{TYPE_MISMATCH, "mismatched types", `
+An expression has another type than the place it is used in expects: the
+declared type of a variable, the type of a parameter, the return type of a
+function, a condition of If, Elif and While, or an operand of an operator.
+NiLang never converts types implicitly: arithmetic and comparison work on
+Int, And and Or work on Bool and == compares values of the same type.
+
+Erroneous code:
+
+    Int x = True
+    If x:
+        bot::Sleep
+    Bool done = x + False
+
+Fixed code:
+
+    Int x = 1
+    If x == 1:
+        bot::Sleep
+    Bool done = x > 0 And False
+`},
+	{ARGUMENT_COUNT, "wrong number of arguments", `
+A function is called with more or fewer arguments than it has parameters.
+Arguments follow the dollar sign and are separated with commas, a function
+without parameters is called without the dollar sign.
+
+Erroneous code:
+
+    Fun Add::Int$ a Int, b Int:
+        Return a + b
+
+    Int x = Add$ 1
+    bot::Move
+
+Fixed code:
+
+    Int x = Add$ 1, 2
+    bot::Move$ dir::front
+`},
+	{MISSING_RETURN, "missing return", `
+A function declared with a return type must end with a Return statement on
+every path, including the one where no If or Elif branch is taken.
+
+Erroneous code:
+
+    Fun Sign::Int$ x Int:
+        If x < 0:
+            Return -1
+
+Fixed code:
+
+    Fun Sign::Int$ x Int:
+        If x < 0:
+            Return -1
+        Return 1
+`},
+	{MISPLACED_STATEMENT, "statement is not allowed here", `
+Return is allowed inside of a function only, Break and Continue inside of a
+While loop only and Domain must be the first statement of a file.
+
+Erroneous code:
+
+    Int x = 1
+    Domain food
+    Break
+
+Fixed code:
+
+    Domain food
+
+    While True:
+        Break
+`},
+	{INVALID_ALIAS, "invalid alias", `
+An alias names constant values of a primitive type, Int or Bool, and every
+value must be a literal of that type.
+
+Erroneous code:
+
+    Alias Speed::Dir:
+        fast = dir::front
+
+    Int x = 1
+    Alias Limit::Int:
+        low = x
+
+Fixed code:
+
+    Alias Limit::Int:
+        low = 1
+        high = 10
+`},
+	{INVALID_USING, "invalid Using statement", `
+Using makes names of a scope or an alias visible in the current block, it
+takes a name or a path of scopes separated with double colons and nothing
+else.
+
+Erroneous code:
+
+    Using food + 1
+
+Fixed code:
+
+    Using food::berry
+`},
+	{CONSTANT_ASSIGNMENT, "assignment to a constant", `
+Values of aliases and directions of dir are constants, the compiler puts
+them right into expressions made of constants, so they can't be assigned.
+
+Erroneous code:
+
+    Alias Limit::Int:
+        low = 1
+
+    Using limit
+    low = 5
+
+Fixed code:
+
+    Int low = 1
+    low = 5
+`},
+	{IMPORT_CYCLE, "import cycle", `
+Files of domains which use each other form a cycle, the order of their
+compilation is undefined. Move the shared declarations to a third domain used
+by both of them.
+
+Erroneous code, in food.nil:
+
+    Domain food
+    Using water
+
+and in water.nil:
+
+    Domain water
+    Using food
+`},
+	{INVALID_DOMAIN, "invalid domain", `
+The name of a domain is a name or a path of names separated with double
+colons, the file found for a Using statement must begin with the Domain
+statement of the used name, e.g. food/berry.nil with Domain food::berry.
+
+Erroneous code, in food.nil found for Using food:
+
+    Domain drink
+
+Erroneous code, the name is not a path:
+
+    Domain food + 1
+
+Fixed code:
+
+    Domain food
+`},
+	{STACK_OVERFLOW, "stack overflow", `
+Operands of an operator wait for each other on the stack at the beginning of
+memory, a statement with nested operators needs a byte of the stack for every
+operand waiting at once. The stack is sized for the deepest statement of the
+program unless its size is set with -s, the error points at the expression
+which doesn't fit into the stack of the set size.
+
+Erroneous code compiled with -s 1, the left operand of + waits for 2 * 3:
+
+    Int x = 1 + 2 * 3
+
+Fixed code, or compile without -s:
+
+    Int y = 2 * 3
+    Int x = 1 + y
+`},
+	{MEMORY_OVERFLOW, "memory overflow", `
+Every variable, parameter and direction of dir takes a byte of memory after
+the stack for the whole run of the bot, a program taking more memory than the
+target bot has set with -m doesn't fit into it. The error points at the first
+declaration which doesn't fit and tells the size of the whole program, see
+the table of addresses written by -memory-map.
+
+Erroneous code compiled with -m 16, the directions take addresses up to 8,
+h doesn't fit after the other variables:
+
+    Int a = 1
+    Int b = 2
+    Int c = 3
+    Int d = 4
+    Int e = 5
+    Int f = 6
+    Int g = 7
+    Int h = 8
+
+Fixed code reusing a variable:
+
+    Int a = 1
+    a = 2
+`},
+	{RECURSION, "recursion without frames", `
+A function calls itself, directly or through other functions, while frames
+are disabled with -frames 0. Variables and parameters of a function have
+fixed addresses, so the inner call would overwrite the values of the outer
+one. With frames, a call which may run its caller again copies the memory of
+the caller to a frame and back after the return, -frames sets the number of
+calls of a function which may run at once. The error points at every call
+which may run its caller again.
+
+Erroneous code compiled with -frames 0:
+
+    Fun Steps::Int$ n Int:
+        If n == 0:
+            Return 0
+        Return 1 + Steps$ n - 1
+
+Fixed code, or compile without -frames 0:
+
+    Fun Steps::Int$ n Int:
+        Int steps = 0
+        While n > 0:
+            n = n - 1
+            steps = steps + 1
+        Return steps
+`},
+	{DIVISION_BY_ZERO, "division by constant zero", `
+The divisor of / or % is an expression of constants equal to zero, the
+division would stop the bot with a runtime error. Expressions made of
+literals, values of aliases and directions are computed by the compiler.
+
+Erroneous code:
+
+    Int x = 10 / 0
+    Int y = x % 0
+
+Fixed code:
+
+    Int x = 10 / 2
+    Int y = x % 2
+`},
+	{INTERNAL_ERROR, "internal compiler error", `
+The compiler has failed on the statement the error points at, it is a bug of
+the compiler rather than an error of the program. No code is emitted, other
+errors found before the failure are reported as usual. Please report the bug
+with the statement and the message, the message names what the compiler
+hasn't handled. Writing the statement in another way often avoids the failure.
+
+Code failing the compiler:
+
+    1$ 2
+
+Fixed code:
+
+    Int x = 2
+`},
+	{UNUSED_VARIABLE, "unused variable", `
+A variable or a parameter is declared but its value is never read, it is
+reported by vet and switched off with -unusedvar=false.
+
+Code with the warning:
+
+    Fun Move$ d Dir:
+        Int steps = 1
+        bot::Move$ dir::front
+
+Fixed code:
+
+    Fun Move$ d Dir:
+        bot::Move$ d
+`},
+	{UNUSED_FUNCTION, "unused function", `
+A function or an alias is declared but never used, it is reported by vet and
+switched off with -unusedfunc=false. Functions whose names begin with Test are
+called by the test command.
+
+Code with the warning:
+
+    Fun Helper:
+        bot::Sleep
+
+    bot::Move$ dir::front
+`},
+	{UNREACHABLE, "unreachable code", `
+A statement follows Return, Break or Continue in the same block and is never
+executed, it is reported by vet and switched off with -unreachable=false.
+
+Code with the warning:
+
+    While True:
+        Break
+        bot::Sleep
+`},
+	{SHADOW, "shadowed declaration", `
+A declaration of an inner block hides a variable, a parameter or a function of
+an enclosing block, the outer one can't be used within the block anymore. It
+is reported by vet, points at the hidden declaration and is switched off with
+-shadow=false.
+
+Code with the warning:
+
+    Int x = 1
+    If True:
+        Int x = 2
+        bot::Sleep
+
+Fixed code:
+
+    Int x = 1
+    If True:
+        x = 2
+    bot::WriteMemory$ x
+`},
+	{UNUSED_RESULT, "unused result", `
+A function returning a value is called as a statement and its result is
+dropped, it is reported by vet and switched off with -unusedresult=false.
+
+Code with the warning:
+
+    bot::IsEmpty$ dir::front
+
+Fixed code:
+
+    If bot::IsEmpty$ dir::front:
+        bot::Move$ dir::front
+`},
+	{UNUSED_USING, "unused Using", `
+Nothing is found through a Using statement, it is reported by vet and switched
+off with -unusedusing=false.
+
+Code with the warning:
+
+    Using dir
+    bot::Move$ dir::front
+
+Fixed code:
+
+    Using dir
+    bot::Move$ front
+`},
+	{CONSTANT_CONDITION, "condition is always false", `
+The condition of If, Elif or While is an expression of constants equal to
+False, so its block never runs. The compiler reports it while compiling, the
+warning is dropped with -Wno=NL0110 and fails the compilation with -Werror.
+
+Code with the warning:
+
+    Alias Mode::Int:
+        quiet = 1
+        loud = 2
+    If mode::quiet == mode::loud:
+        bot::Move$ dir::front
+
+Fixed code:
+
+    Alias Mode::Int:
+        quiet = 1
+        loud = 2
+    Mode current = mode::quiet
+    If current == mode::loud:
+        bot::Move$ dir::front
+`},
+}
+
+// Lookup finds the code case-insensitively
+func Lookup(id string) (Code, bool) {
+	for _, code := range CODES {
+		if strings.EqualFold(code.ID, id) {
+			return code, true
+		}
+	}
+	return Code{}, false
+}
+
+// Explain returns the text printed by `nilang explain`
+func (code Code) Explain() string {
+	return fmt.Sprintf("%s: %s\n%s", code.ID, code.Title, code.Explanation)
+}
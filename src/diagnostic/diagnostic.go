@@ -0,0 +1,148 @@
+package diagnostic
+
+import (
+	"NiLang/src/helper"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	TEXT  = "text"
+	JSON  = "json"
+	SARIF = "sarif"
+)
+
+var FORMATS = []string{TEXT, JSON, SARIF}
+
+// Diagnostic is an error or a warning in the form for tools, columns are counted from zero as in error messages
+type Diagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndColumn int    `json:"endColumn"` // column after the marked source
+	Severity  string `json:"severity"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+
+	Related []Related `json:"related,omitempty"`
+}
+
+// Related is another place the diagnostic is about, e.g. the previous declaration
+type Related struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func New(err helper.Error) Diagnostic {
+	d := Diagnostic{
+		File:      err.File,
+		Line:      err.Line,
+		Column:    err.Offset,
+		EndColumn: err.Offset + max(err.Length, 1),
+		Severity:  err.Level(),
+		Code:      err.Code,
+		Message:   err.Description,
+	}
+	for _, related := range err.Related {
+		file := related.File
+		if file == "" {
+			file = d.File
+		}
+		d.Related = append(d.Related, Related{File: file, Line: related.Line, Column: related.Offset, Message: related.Description})
+	}
+	return d
+}
+
+// Report writes diagnostics in the chosen format, the text is printed at once
+// while JSON and SARIF documents are written by Flush when all diagnostics are known
+type Report struct {
+	format      string
+	output      io.Writer
+	diagnostics []Diagnostic
+
+	werror   bool
+	disabled map[string]bool // codes of warnings which are not reported
+	count    int
+	errors   int
+}
+
+func NewReport(format string, output io.Writer) (*Report, error) {
+	if !slices.Contains(FORMATS, format) {
+		return nil, fmt.Errorf("unknown format of diagnostics %q, expected one of %v", format, FORMATS)
+	}
+	return &Report{format: format, output: output, diagnostics: make([]Diagnostic, 0), disabled: make(map[string]bool)}, nil
+}
+
+// TreatWarningsAsErrors reports warnings with the severity of errors
+func (r *Report) TreatWarningsAsErrors() {
+	r.werror = true
+}
+
+// Disable drops warnings of the codes, errors are always reported
+func (r *Report) Disable(codes ...string) error {
+	for _, code := range codes {
+		c, ok := Lookup(code)
+		if !ok {
+			return fmt.Errorf("unknown code %q", code)
+		}
+		r.disabled[c.ID] = true
+	}
+	return nil
+}
+
+// Add reports the error found in the source, the source is used for the text only
+func (r *Report) Add(err helper.Error, source []byte) {
+	if err.Level() == helper.WARNING {
+		if r.disabled[err.Code] {
+			return
+		}
+		if r.werror {
+			err.Severity = helper.ERROR
+		}
+	}
+
+	r.count++
+	if err.Level() == helper.ERROR {
+		r.errors++
+	}
+
+	if r.format == TEXT {
+		fmt.Fprintln(r.output, helper.FormatError(err, source))
+		return
+	}
+	r.diagnostics = append(r.diagnostics, New(err))
+}
+
+// Count returns the number of reported diagnostics, disabled warnings are not counted
+func (r *Report) Count() int {
+	return r.count
+}
+
+// Errors returns the number of reported errors including warnings treated as errors
+func (r *Report) Errors() int {
+	return r.errors
+}
+
+func (r *Report) Flush() error {
+	switch r.format {
+	case JSON:
+		return r.write(r.diagnostics)
+	case SARIF:
+		return r.write(makeSarif(r.diagnostics))
+	default:
+		return nil
+	}
+}
+
+func (r *Report) write(document any) error {
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = r.output.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,208 @@
+package diagnostic_test
+
+import (
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var source = []byte("Int x = y\nInt unused = 1\nInt x = 2\n")
+
+func report(t *testing.T, format string, configure func(r *diagnostic.Report)) *bytes.Buffer {
+	var output bytes.Buffer
+	r, err := diagnostic.NewReport(format, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configure(r)
+
+	undeclared := helper.MakeError(tokens.Token{Type: tokens.IDENT, Literal: "y", Line: 1, Offset: 8}, diagnostic.UNDECLARED, "undeclared identifier")
+	undeclared.File = "bot.nil"
+	r.Add(undeclared, source)
+
+	unused := helper.MakeError(tokens.Token{Type: tokens.IDENT, Literal: "unused", Line: 2, Offset: 4}, diagnostic.UNUSED_VARIABLE, "variable is never used")
+	unused.File = "bot.nil"
+	unused.Severity = helper.WARNING
+	r.Add(unused, source)
+
+	redeclared := helper.MakeError(tokens.Token{Type: tokens.IDENT, Literal: "x", Line: 3, Offset: 4}, diagnostic.REDECLARATION, "redeclaration of variable")
+	redeclared.File = "bot.nil"
+	redeclared.Related = []helper.Related{{Line: 1, Offset: 4, Description: "previously declared here"}}
+	r.Add(redeclared, source)
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return &output
+}
+
+func defaults(r *diagnostic.Report) {}
+
+func TestText(t *testing.T) {
+	output := report(t, diagnostic.TEXT, defaults)
+
+	for _, expected := range []string{
+		"bot.nil:1:8: undeclared identifier [NL0010]\n",
+		"bot.nil:2:4: warning: variable is never used [NL0101]\n",
+		"bot.nil:3:4: redeclaration of variable [NL0011]\nbot.nil:1:4: note: previously declared here\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, output.String())
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	output := report(t, diagnostic.JSON, defaults)
+
+	var diagnostics []diagnostic.Diagnostic
+	if err := json.Unmarshal(output.Bytes(), &diagnostics); err != nil {
+		t.Fatalf("output is not JSON: %s\n%s", err, output.String())
+	}
+
+	expected := []diagnostic.Diagnostic{
+		{File: "bot.nil", Line: 1, Column: 8, EndColumn: 9, Severity: "error", Code: "NL0010", Message: "undeclared identifier"},
+		{File: "bot.nil", Line: 2, Column: 4, EndColumn: 10, Severity: "warning", Code: "NL0101", Message: "variable is never used"},
+		{File: "bot.nil", Line: 3, Column: 4, EndColumn: 5, Severity: "error", Code: "NL0011", Message: "redeclaration of variable",
+			Related: []diagnostic.Related{{File: "bot.nil", Line: 1, Column: 4, Message: "previously declared here"}}},
+	}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diagnostics)
+	}
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	output := report(t, diagnostic.TEXT, func(r *diagnostic.Report) { r.TreatWarningsAsErrors() })
+
+	if !strings.Contains(output.String(), "bot.nil:2:4: variable is never used [NL0101]\n") {
+		t.Errorf("expected the warning to be reported as error:\n%s", output.String())
+	}
+}
+
+func TestDisable(t *testing.T) {
+	var counted *diagnostic.Report
+	output := report(t, diagnostic.TEXT, func(r *diagnostic.Report) {
+		if err := r.Disable("nl0101", diagnostic.UNDECLARED); err != nil {
+			t.Fatal(err)
+		}
+		counted = r
+	})
+
+	if strings.Contains(output.String(), "NL0101") {
+		t.Errorf("expected the warning to be dropped:\n%s", output.String())
+	}
+	if !strings.Contains(output.String(), "NL0010") {
+		t.Errorf("expected errors to be reported even if disabled:\n%s", output.String())
+	}
+	if counted.Count() != 2 || counted.Errors() != 2 {
+		t.Errorf("expected 2 errors only, got %d diagnostics with %d errors", counted.Count(), counted.Errors())
+	}
+
+	r, _ := diagnostic.NewReport(diagnostic.TEXT, &bytes.Buffer{})
+	if err := r.Disable("NL9999"); err == nil {
+		t.Errorf("expected error for unknown code")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, code := range diagnostic.CODES {
+		if seen[code.ID] {
+			t.Errorf("code %s is used twice", code.ID)
+		}
+		seen[code.ID] = true
+
+		if code.Title == "" || !strings.Contains(code.Explanation, "\n    ") {
+			t.Errorf("code %s has no title or examples", code.ID)
+		}
+	}
+
+	code, ok := diagnostic.Lookup("nl0012")
+	if !ok || !strings.HasPrefix(code.Explain(), "NL0012: mismatched types\n") {
+		t.Errorf("expected explanation of NL0012, got %q", code.Explain())
+	}
+}
+
+func TestEmptyJSON(t *testing.T) {
+	var output bytes.Buffer
+	r, _ := diagnostic.NewReport(diagnostic.JSON, &output)
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(output.String()) != "[]" {
+		t.Errorf("expected empty array, got %q", output.String())
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	output := report(t, diagnostic.SARIF, defaults)
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn, EndColumn int }
+					}
+				}
+				RelatedLocations []struct {
+					PhysicalLocation struct {
+						Region struct{ StartLine, StartColumn int }
+					}
+					Message struct{ Text string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(output.Bytes(), &log); err != nil {
+		t.Fatalf("output is not JSON: %s", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected SARIF 2.1.0 with one run, got version %q with %d runs", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "nilang" || len(run.Tool.Driver.Rules) != 3 || run.Tool.Driver.Rules[1].ID != "NL0101" {
+		t.Errorf("unexpected driver %+v", run.Tool.Driver)
+	}
+
+	if len(run.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(run.Results))
+	}
+	warning := run.Results[1]
+	location := warning.Locations[0].PhysicalLocation
+	if warning.RuleID != "NL0101" || warning.Level != "warning" || location.ArtifactLocation.URI != "bot.nil" {
+		t.Errorf("unexpected result %+v", warning)
+	}
+	if region := location.Region; region.StartLine != 2 || region.StartColumn != 5 || region.EndColumn != 11 {
+		t.Errorf("expected region 2:5-11 counted from one, got %+v", region)
+	}
+
+	related := run.Results[2].RelatedLocations
+	if len(related) != 1 || related[0].Message.Text != "previously declared here" ||
+		related[0].PhysicalLocation.Region.StartLine != 1 || related[0].PhysicalLocation.Region.StartColumn != 5 {
+		t.Errorf("expected the previous declaration at 1:5, got %+v", related)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := diagnostic.NewReport("xml", &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
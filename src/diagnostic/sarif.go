@@ -0,0 +1,140 @@
+package diagnostic
+
+import (
+	"NiLang/src/common"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const SARIF_VERSION = "2.1.0"
+const SARIF_SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarif is the subset of Static Analysis Results Interchange Format needed for code scanning
+type sarif struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"` // of related locations
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion counts columns from one
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+func makeSarif(diagnostics []Diagnostic) sarif {
+	driver := sarifDriver{
+		Name:           "nilang",
+		Version:        common.VERSION,
+		InformationURI: "https://github.com/nikonru/NiLang",
+		Rules:          make([]sarifRule, 0),
+	}
+
+	results := make([]sarifResult, 0, len(diagnostics))
+	codes := make(map[string]bool)
+	for _, d := range diagnostics {
+		if d.Code != "" && !codes[d.Code] {
+			codes[d.Code] = true
+			rule := sarifRule{ID: d.Code}
+			if code, ok := Lookup(d.Code); ok {
+				rule.ShortDescription = &sarifMessage{Text: code.Title}
+			}
+			driver.Rules = append(driver.Rules, rule)
+		}
+
+		region := sarifRegion{StartLine: d.Line, StartColumn: d.Column + 1, EndColumn: d.EndColumn + 1}
+		result := sarifResult{
+			RuleID:  d.Code,
+			Level:   d.Severity,
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: fileURI(d.File)},
+				Region:           region,
+			}}},
+		}
+		for _, related := range d.Related {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: fileURI(related.File)},
+					Region:           sarifRegion{StartLine: related.Line, StartColumn: related.Column + 1},
+				},
+				Message: &sarifMessage{Text: related.Message},
+			})
+		}
+		results = append(results, result)
+	}
+
+	return sarif{
+		Version: SARIF_VERSION,
+		Schema:  SARIF_SCHEMA,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+// fileURI is relative to the working directory if the file is inside of it, dashboards resolve it against the repository
+func fileURI(file string) string {
+	if wd, err := os.Getwd(); err == nil && filepath.IsAbs(file) {
+		if relative, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(relative, "..") {
+			file = relative
+		}
+	}
+
+	path := filepath.ToSlash(file)
+	if !filepath.IsAbs(file) {
+		return (&url.URL{Path: path}).String()
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // drive letter on Windows
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,34 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/diagnostic"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// explain prints long descriptions of the codes of errors and warnings, the list of codes without arguments
+func explain(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	ids := parseFlags(flags, args)
+
+	if len(ids) == 0 {
+		for _, code := range diagnostic.CODES {
+			fmt.Printf("%s\t%s\n", code.ID, code.Title)
+		}
+		return
+	}
+
+	for i, id := range ids {
+		code, ok := diagnostic.Lookup(id)
+		if !ok {
+			log.Fatalf("Unknown code %q, run `nilang explain` to list all of them", id)
+		}
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Print(code.Explain())
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/format"
+	"NiLang/src/helper"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// formatFiles prints formatted code, rewrites the files with -w or lists unformatted files with --check
+func formatFiles(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the source file instead of stdout")
+	check := flags.Bool("check", false, "list files whose formatting differs and exit with status 1 if there are any")
+	fileNames := parseFlags(flags, args)
+
+	if len(fileNames) == 0 {
+		log.Fatal("Expected argument with path to code to format")
+	}
+
+	failed := false
+	for _, fileName := range fileNames {
+		input := readFile(fileName)
+
+		output, errors := format.Format(input)
+		if len(errors) != 0 {
+			for _, err := range errors {
+				err.File = absolute(fileName)
+				helper.PrintError(err, input)
+			}
+			failed = true
+			continue
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(input, output) {
+				fmt.Println(fileName)
+				failed = true
+			}
+		case *write:
+			if !bytes.Equal(input, output) {
+				if err := os.WriteFile(fileName, output, 0644); err != nil {
+					log.Fatal(err)
+				}
+			}
+		default:
+			if _, err := os.Stdout.Write(output); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,342 @@
+package format
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/lexer"
+	"NiLang/src/parser"
+	"NiLang/src/tokens"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type errors = []helper.Error
+
+// Format reprints the code in the canonical layout: indentation of 4 whitespaces, no spaces around `::`,
+// a space after `$` and `,`, one blank line around `Fun` and `Scope` blocks. Comments are kept,
+// other blank lines are kept but squashed to one
+func Format(input []byte) ([]byte, errors) {
+	normalized, errs := normalize(input)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	l := lexer.New(normalized)
+	p := parser.New(&l)
+	program := p.Parse()
+	if errs := p.Errors(); len(errs) != 0 {
+		return nil, errs
+	}
+
+	printer := &printer{
+		source:       strings.Split(string(normalized), "\n"),
+		comments:     program.Comments,
+		atBlockStart: true,
+	}
+
+	printer.statements(program.Statements, 0)
+	printer.flushComments(len(printer.source)+1, 0, false)
+
+	return printer.out.Bytes(), nil
+}
+
+// normalize makes the code acceptable for the lexer: tabs become whitespaces and
+// indentation of any width becomes levels of tokens.INDENT_LENGTH whitespaces
+func normalize(input []byte) ([]byte, errors) {
+	lines := strings.Split(strings.ReplaceAll(string(input), "\r\n", "\n"), "\n")
+	widths := []int{0} // widths of open levels of indentation
+	errs := make(errors, 0)
+
+	for i, line := range lines {
+		content := strings.TrimRight(strings.TrimLeft(line, " \t"), " \t\r")
+		width := indentationWidth(line[:len(line)-len(strings.TrimLeft(line, " \t"))])
+
+		if content == "" {
+			lines[i] = ""
+			continue
+		}
+
+		if strings.HasPrefix(content, "#") {
+			level := 0
+			for j, w := range widths {
+				if w <= width {
+					level = j
+				}
+			}
+			lines[i] = indentation(level) + content
+			continue
+		}
+
+		if width > widths[len(widths)-1] {
+			widths = append(widths, width)
+		}
+		for width < widths[len(widths)-1] {
+			widths = widths[:len(widths)-1]
+		}
+		if width != widths[len(widths)-1] {
+			errs = append(errs, helper.Error{Line: i + 1, Offset: 0, Description: "indentation does not match any outer level", Code: diagnostic.INDENTATION})
+			widths = append(widths, width)
+		}
+
+		code, comment, hasComment := strings.Cut(content, "#")
+		content = strings.ReplaceAll(code, "\t", " ")
+		if hasComment {
+			content += "#" + comment
+		}
+		lines[i] = indentation(len(widths)-1) + content
+	}
+
+	return []byte(strings.Join(lines, "\n")), errs
+}
+
+func indentationWidth(whitespaces string) int {
+	width := 0
+	for _, char := range whitespaces {
+		if char == '\t' {
+			width += tokens.INDENT_LENGTH - width%tokens.INDENT_LENGTH
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+func indentation(level int) string {
+	return strings.Repeat(" ", level*tokens.INDENT_LENGTH)
+}
+
+type printer struct {
+	out    bytes.Buffer
+	source []string // normalized code, used to find blank lines
+
+	comments []tokens.Token
+	next     int // index of the first comment not printed yet
+
+	atBlockStart bool // nothing has been printed in the current block
+	maxDepth     int  // the deepest level a comment may have at the current line
+}
+
+func (p *printer) statements(statements []ast.Statement, depth int) {
+	for i, statement := range statements {
+		separate := i > 0 && (isBlock(statement) || isBlock(statements[i-1]))
+		if p.flushComments(line(statement), depth, separate) {
+			separate = false // the blank line is put above comments attached to the statement
+		}
+		p.separate(line(statement), separate)
+		p.statement(statement, depth)
+	}
+}
+
+func (p *printer) statement(statement ast.Statement, depth int) {
+	at := line(statement)
+
+	switch s := statement.(type) {
+	case *ast.DeclarationStatement:
+		p.line(depth, at, fmt.Sprintf("%s %s = %s", expression(s.Var.Type), s.Var.Name, expression(s.Value)), false)
+	case *ast.AssignmentStatement:
+		p.line(depth, at, fmt.Sprintf("%s = %s", s.Name.Value, expression(s.Value)), false)
+	case *ast.ExpressionStatement:
+		p.line(depth, at, expression(s.Expression), false)
+	case *ast.ReturnStatement:
+		if s.Value == nil {
+			p.line(depth, at, "Return", false)
+		} else {
+			p.line(depth, at, "Return "+expression(s.Value), false)
+		}
+	case *ast.UsingStatement:
+		p.line(depth, at, "Using "+expression(s.Name), false)
+	case *ast.DomainStatement:
+		p.line(depth, at, "Domain "+expression(s.Name), false)
+	case *ast.BreakStatement:
+		p.line(depth, at, "Break", false)
+	case *ast.ContinueStatement:
+		p.line(depth, at, "Continue", false)
+	case *ast.ScopeStatement:
+		p.line(depth, at, fmt.Sprintf("Scope %s:", s.Name.Value), true)
+		p.statements(s.Body.Statements, depth+1)
+	case *ast.WhileStatement:
+		p.line(depth, at, fmt.Sprintf("While %s:", expression(s.Condition)), true)
+		p.statements(s.Body.Statements, depth+1)
+	case *ast.AliasStatement:
+		p.line(depth, at, fmt.Sprintf("Alias %s::%s:", s.Var.Name, expression(s.Var.Type)), true)
+		for _, value := range s.Values {
+			p.flushComments(value.Var.Token.Line, depth+1, false)
+			p.separate(value.Var.Token.Line, false)
+			p.line(depth+1, value.Var.Token.Line, fmt.Sprintf("%s = %s", value.Var.Name, expression(value.Value)), false)
+		}
+	case *ast.FunctionStatement:
+		p.line(depth, at, function(s), true)
+		p.statements(s.Body.Statements, depth+1)
+	case *ast.IfStatement:
+		p.line(depth, at, fmt.Sprintf("If %s:", expression(s.Condition)), true)
+		p.statements(s.Consequence.Statements, depth+1)
+
+		for _, elif := range s.Elifs {
+			p.flushComments(elif.Token.Line, depth, false)
+			p.line(depth, elif.Token.Line, fmt.Sprintf("Elif %s:", expression(elif.Condition)), true)
+			p.statements(elif.Consequence.Statements, depth+1)
+		}
+
+		if s.Alternative != nil && len(s.Alternative.Statements) != 0 {
+			at := p.findElse(line(s.Alternative.Statements[0]))
+			p.flushComments(at, depth, false)
+			p.line(depth, at, "Else:", true)
+			p.statements(s.Alternative.Statements, depth+1)
+		}
+	}
+}
+
+// line prints the code with the comment placed at the end of its source line
+func (p *printer) line(depth int, at int, code string, opensBlock bool) {
+	p.out.WriteString(indentation(depth) + code)
+	if p.next < len(p.comments) && p.comments[p.next].Line == at {
+		p.out.WriteString(" " + p.comments[p.next].Literal)
+		p.next++
+	}
+	p.out.WriteString("\n")
+
+	p.atBlockStart = opensBlock
+	p.maxDepth = depth
+	if opensBlock {
+		p.maxDepth++
+	}
+}
+
+// flushComments prints comments from lines before the given one, comments at the end of a block stay in it.
+// The required blank line is put above the first comment attached to the next line
+func (p *printer) flushComments(before int, depth int, separate bool) bool {
+	attached := false // a comment at the level of the next line has been printed
+	for ; p.next < len(p.comments) && p.comments[p.next].Line < before; p.next++ {
+		comment := p.comments[p.next]
+
+		level := min(max(comment.Offset/tokens.INDENT_LENGTH, depth), max(p.maxDepth, depth))
+		if level == depth {
+			p.separate(comment.Line, separate && !attached)
+			attached = true
+		} else {
+			p.separate(comment.Line, false) // the comment stays at the end of the previous block
+		}
+
+		p.out.WriteString(indentation(level) + comment.Literal + "\n")
+		p.atBlockStart = false
+	}
+	return attached
+}
+
+// separate puts blank line where the source has one or where it's required
+func (p *printer) separate(at int, required bool) {
+	if p.atBlockStart {
+		return
+	}
+
+	if required || (at >= 2 && at-2 < len(p.source) && p.source[at-2] == "") {
+		p.out.WriteString("\n")
+		p.atBlockStart = true
+	}
+}
+
+// findElse returns the line of the Else keyword above the first statement of the alternative
+func (p *printer) findElse(first int) int {
+	for at := first - 1; at >= 1; at-- {
+		if strings.HasPrefix(strings.TrimSpace(p.source[at-1]), "Else") {
+			return at
+		}
+	}
+	return first
+}
+
+func function(fs *ast.FunctionStatement) string {
+	var out strings.Builder
+
+	out.WriteString("Fun " + fs.Var.Name)
+	if fs.Var.Type != nil {
+		out.WriteString("::" + expression(fs.Var.Type))
+	}
+
+	for i, parameter := range fs.Parameters {
+		if i == 0 {
+			out.WriteString("$ ")
+		} else {
+			out.WriteString(", ")
+		}
+		out.WriteString(parameter.Name + " " + expression(parameter.Type))
+	}
+
+	out.WriteString(":")
+	return out.String()
+}
+
+func expression(node ast.Expression) string {
+	switch e := node.(type) {
+	case *ast.Identifier:
+		return e.Value
+	case *ast.IntegralLiteral:
+		return e.Token.Literal
+	case *ast.BooleanLiteral:
+		return e.Token.Literal
+	case *ast.PrefixExpression:
+		if e.Operator == tokens.NOT {
+			return e.Operator + " " + expression(e.Right)
+		}
+		return e.Operator + expression(e.Right)
+	case *ast.InfixExpression:
+		return expression(e.Left) + " " + e.Operator + " " + expression(e.Right)
+	case *ast.ScopeExpression:
+		return expression(e.Scope) + "::" + e.Value.Value
+	case *ast.CallExpression:
+		if len(e.Arguments) == 0 {
+			return expression(e.Function)
+		}
+
+		arguments := make([]string, len(e.Arguments))
+		for i, argument := range e.Arguments {
+			arguments[i] = expression(argument)
+		}
+		return expression(e.Function) + "$ " + strings.Join(arguments, ", ")
+	default:
+		return ""
+	}
+}
+
+func isBlock(statement ast.Statement) bool {
+	switch statement.(type) {
+	case *ast.FunctionStatement, *ast.ScopeStatement:
+		return true
+	}
+	return false
+}
+
+func line(statement ast.Statement) int {
+	switch s := statement.(type) {
+	case *ast.DeclarationStatement:
+		return s.Var.Token.Line
+	case *ast.AssignmentStatement:
+		return s.Name.Token.Line
+	case *ast.ExpressionStatement:
+		return s.Token.Line
+	case *ast.ReturnStatement:
+		return s.Token.Line
+	case *ast.UsingStatement:
+		return s.Token.Line
+	case *ast.DomainStatement:
+		return s.Token.Line
+	case *ast.BreakStatement:
+		return s.Token.Line
+	case *ast.ContinueStatement:
+		return s.Token.Line
+	case *ast.ScopeStatement:
+		return s.Token.Line
+	case *ast.WhileStatement:
+		return s.Token.Line
+	case *ast.AliasStatement:
+		return s.Token.Line
+	case *ast.FunctionStatement:
+		return s.Token.Line
+	case *ast.IfStatement:
+		return s.Token.Line
+	default:
+		return 0
+	}
+}
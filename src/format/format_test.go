@@ -0,0 +1,129 @@
+package format_test
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/format"
+	"bytes"
+	"os"
+	"testing"
+)
+
+const stackSize = 128
+
+var sources = []string{"../compiler/bot.nil", "../parser/bot.nil", "../lexer/bot.nil"}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			"domain",
+			"Domain  utils::math\nUsing   helpers\nInt y = helpers::x\n",
+			"Domain utils::math\nUsing helpers\nInt y = helpers::x\n",
+		},
+		{
+			"spacing",
+			"Int x=1+2*  3\nbot::Move$dir::front\nFun F::Int$a Int,b Int:\n    Return a-b\nx = F$x,-x\nBool y = Not  x>2\n",
+			"Int x = 1 + 2 * 3\nbot::Move$ dir::front\n\nFun F::Int$ a Int, b Int:\n    Return a - b\n\nx = F$ x, -x\nBool y = Not x > 2\n",
+		},
+		{
+			"indentation",
+			"If True:\n\tInt x = 1\n\tWhile x < 3:\n\t  x = x + 1\nElse:\n  Int y = 2\r\n",
+			"If True:\n    Int x = 1\n    While x < 3:\n        x = x + 1\nElse:\n    Int y = 2\n",
+		},
+		{
+			"comments",
+			"# header\nInt x = 1   # trailing\nIf x == 1:\n    x = 2\n        # end of block\n# before else\nElse:\n  # inside\n  x = 3\n# last",
+			"# header\nInt x = 1 # trailing\nIf x == 1:\n    x = 2\n    # end of block\n# before else\nElse:\n    # inside\n    x = 3\n# last\n",
+		},
+		{
+			"blank lines",
+			"\n\nInt x = 1\n\n\n\nx = 2\n# about scope\nScope s:\n\n    Int y = 1\nFun F:\n    x = 3\nx = 4\n\n",
+			"Int x = 1\n\nx = 2\n\n# about scope\nScope s:\n    Int y = 1\n\nFun F:\n    x = 3\n\nx = 4\n",
+		},
+		{
+			"comment at the end of function",
+			"Fun A:\n    Int x = 1\n    # end of A\nFun B:\n    Int y = 2\n",
+			"Fun A:\n    Int x = 1\n    # end of A\n\nFun B:\n    Int y = 2\n",
+		},
+		{
+			"alias",
+			"Alias State::Int:\n  healthy=1\n\n  ill = 2 # worse\n",
+			"Alias State::Int:\n    healthy = 1\n\n    ill = 2 # worse\n",
+		},
+	}
+
+	for _, test := range tests {
+		output, errs := format.Format([]byte(test.input))
+		if len(errs) != 0 {
+			t.Fatalf("%s: unexpected errors %v", test.name, errs)
+		}
+		if string(output) != test.output {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.output, output)
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	inputs := []string{
+		"If True:\n        Int x = 1\n    Int y = 2\n", // unindent to unknown level
+		"Int x = \n",
+	}
+
+	for _, input := range inputs {
+		if _, errs := format.Format([]byte(input)); len(errs) == 0 {
+			t.Errorf("expected errors for %q", input)
+		}
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	for _, source := range sources {
+		input, err := os.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		once, errs := format.Format(input)
+		if len(errs) != 0 {
+			t.Fatalf("%s: unexpected errors %v", source, errs)
+		}
+
+		twice, errs := format.Format(once)
+		if len(errs) != 0 {
+			t.Fatalf("%s: unexpected errors %v after formatting", source, errs)
+		}
+
+		if !bytes.Equal(once, twice) {
+			t.Errorf("%s: formatting is not stable, first\n%s\nsecond\n%s", source, once, twice)
+		}
+	}
+}
+
+func TestSameCode(t *testing.T) {
+	input, err := os.ReadFile("../compiler/bot.nil")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, errs := format.Format(input)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	expected, errs := compiler.New(stackSize).Compile(input)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	actual, errs := compiler.New(stackSize).Compile(output)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v in formatted code", errs)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("formatted code compiles to different program")
+	}
+}
@@ -8,18 +8,44 @@ import (
 	"strings"
 )
 
+const (
+	ERROR   = "error"
+	WARNING = "warning"
+	NOTE    = "note"
+)
+
 type Error struct {
 	Line        int
 	Offset      int
 	Description string
+	File        string
+	Length      int    // of the marked token, zero if the error points at a position only
+	Severity    string // ERROR if empty
+	Code        string // kind of the error, e.g. NL0012, see `nilang explain`
+	Related     []Related
+}
+
+// Related is another place of the source the error is about, e.g. the previous declaration of a redeclared name
+type Related struct {
+	File        string // empty for the file of the error
+	Line        int
+	Offset      int
+	Description string
 }
 
 func PrintError(error Error, input []byte) {
 	fmt.Printf("%s\n", FormatError(error, input))
 }
 
-func MakeError(token tokens.Token, description string) Error {
-	return Error{Line: token.Line, Offset: token.Offset, Description: description}
+func MakeError(token tokens.Token, code string, description string) Error {
+	return Error{Line: token.Line, Offset: token.Offset, Description: description, Length: len(token.Literal), Code: code}
+}
+
+func (error Error) Level() string {
+	if error.Severity == "" {
+		return ERROR
+	}
+	return error.Severity
 }
 
 func FormatError(error Error, input []byte) (str string) {
@@ -31,18 +57,23 @@ func FormatError(error Error, input []byte) (str string) {
 		pointer = pointer[:index] + "^" + pointer[index+1:]
 	}
 
-	str = fmt.Sprintf("%s\n%s\n%s:%d:%d: %s", string(line), pointer, getFilename(), error.Line, error.Offset, error.Description)
-	return str
-}
-
-var filename string
-
-func SetFilename(name string) {
-	filename = name
-}
+	description := error.Description
+	if error.Level() != ERROR {
+		description = error.Level() + ": " + description
+	}
+	if error.Code != "" {
+		description += " [" + error.Code + "]"
+	}
 
-func getFilename() string {
-	return filename
+	str = fmt.Sprintf("%s\n%s\n%s:%d:%d: %s", string(line), pointer, error.File, error.Line, error.Offset, description)
+	for _, related := range error.Related {
+		file := related.File
+		if file == "" {
+			file = error.File
+		}
+		str += fmt.Sprintf("\n%s:%d:%d: %s: %s", file, related.Line, related.Offset, NOTE, related.Description)
+	}
+	return str
 }
 
 func getLine(line int, input []byte) (value []byte) {
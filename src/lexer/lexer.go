@@ -1,13 +1,16 @@
 package lexer
 
 import (
+	"NiLang/src/diagnostic"
 	"NiLang/src/helper"
 	"NiLang/src/tokens"
 	"fmt"
+	"strings"
 )
 
 type Lexer interface {
 	NextToken() (*helper.Error, tokens.Token)
+	Comments() []tokens.Token // comments skipped so far
 }
 
 type lexer struct {
@@ -17,12 +20,12 @@ type lexer struct {
 	current int
 	next    int
 
-	indentation             bool
-	indentationError        bool
-	indentationErrorMessage string
+	indentation bool
 
 	shouldSkipNewlines bool
 
+	comments []tokens.Token
+
 	line   int
 	pos    int
 	offset int
@@ -37,8 +40,6 @@ func New(input []byte) Lexer {
 		line:   0,
 		offset: 0,
 
-		indentationError: false,
-
 		shouldSkipNewlines: false,
 	}
 	l.startNewline()
@@ -55,22 +56,10 @@ func (l *lexer) NextToken() (*helper.Error, tokens.Token) {
 		flag := l.offset == 0
 		l.skipComment()
 
-		if flag || l.indentationError || l.shouldSkipNewlines {
+		if flag || l.shouldSkipNewlines {
 			l.skipNewlines()
 			l.shouldSkipNewlines = false
 		}
-
-		l.indentationError = false
-	}
-
-	if l.indentationError {
-		if !(isNewline(l.char) || (l.char == 0)) {
-			err := helper.Error{Line: l.line, Offset: l.offset, Description: l.indentationErrorMessage}
-			return &err, l.newToken(tokens.EOF)
-		}
-		l.skipNewlines()
-		l.indentationError = false
-		return l.NextToken()
 	}
 
 	var tok tokens.Token
@@ -79,13 +68,11 @@ func (l *lexer) NextToken() (*helper.Error, tokens.Token) {
 		tok = l.newToken(tokens.NEWLINE)
 		l.skipNewlines()
 		return nil, tok
-	case ' ':
+	case ' ', '\t':
 		if l.indentation {
-			offset, ok := l.readIndent()
-			if !ok {
-				return l.NextToken()
-			}
+			offset, err := l.readIndent()
 
+			// wrong indentation of empty lines and comments doesn't matter
 			if isNewline(l.char) {
 				l.skipNewlines()
 				return l.NextToken()
@@ -94,11 +81,24 @@ func (l *lexer) NextToken() (*helper.Error, tokens.Token) {
 				l.shouldSkipNewlines = true
 				return l.NextToken()
 			}
-			return nil, tokens.Token{Type: tokens.INDENT, Literal: "indentation", Line: l.line, Offset: offset}
-		} else {
-			l.read()
-			return l.NextToken()
+			if err != nil && l.char == 0 {
+				return l.NextToken()
+			}
+			return err, tokens.Token{Type: tokens.INDENT, Literal: "indentation", Line: l.line, Offset: offset}
+		}
+
+		var err *helper.Error
+		if l.char == '\t' {
+			err = l.tabulationError()
 		}
+		l.read()
+
+		// the error is reported with the next token, so lexing goes on
+		next, tok := l.NextToken()
+		if err == nil {
+			err = next
+		}
+		return err, tok
 	case ',':
 		tok = l.newToken(tokens.COMMA)
 	case '$':
@@ -150,10 +150,6 @@ func (l *lexer) NextToken() (*helper.Error, tokens.Token) {
 	case 0:
 		tok = l.newToken(tokens.EOF)
 		tok.Literal = ""
-	case '\t':
-		desc := fmt.Sprintf("tabulation is not allowed, use %d whitespaces only", tokens.INDENT_LENGTH)
-		err := helper.Error{Line: l.line, Offset: l.offset, Description: desc}
-		return &err, l.newToken(tokens.EOF)
 	default:
 		if isDigit(l.char) {
 			return nil, l.readNumberToken()
@@ -190,7 +186,7 @@ func (l *lexer) read() {
 	}
 	l.current = l.next
 	l.next++
-	l.indentation = l.indentation && l.char == ' '
+	l.indentation = l.indentation && (l.char == ' ' || l.char == '\t')
 }
 
 func (l *lexer) readSequence(check func(byte) bool) []byte {
@@ -215,34 +211,57 @@ func (l *lexer) readIdent() []byte {
 	return l.readSequence(check)
 }
 
-func (l *lexer) readIndent() (int, bool) {
+// readIndent returns width of the indentation, a tabulation counts as one level. Wrong indentation is rounded
+// to the nearest level and reported
+func (l *lexer) readIndent() (int, *helper.Error) {
 	counter := 0
+	var err *helper.Error
 
 	l.readSequence(func(char byte) bool {
-		if char == ' ' {
+		switch char {
+		case ' ':
 			counter++
 			return true
+		case '\t':
+			if err == nil {
+				err = l.tabulationError()
+			}
+			counter += tokens.INDENT_LENGTH
+			return true
 		}
 		return false
 	})
 
-	if counter%tokens.INDENT_LENGTH != 0 {
-		l.indentationError = true
-		l.indentationErrorMessage = fmt.Sprintf("expected indentation to be multiple of %d, got=%d whitespaces", tokens.INDENT_LENGTH, counter)
-		return 0, false
+	if err == nil && counter%tokens.INDENT_LENGTH != 0 {
+		desc := fmt.Sprintf("expected indentation to be multiple of %d, got=%d whitespaces", tokens.INDENT_LENGTH, counter)
+		err = &helper.Error{Line: l.line, Offset: l.offset, Description: desc, Code: diagnostic.INDENTATION}
 	}
 
-	return counter, true
+	level := (counter + tokens.INDENT_LENGTH/2) / tokens.INDENT_LENGTH
+	return level * tokens.INDENT_LENGTH, err
+}
+
+func (l *lexer) tabulationError() *helper.Error {
+	desc := fmt.Sprintf("tabulation is not allowed, use %d whitespaces only", tokens.INDENT_LENGTH)
+	return &helper.Error{Line: l.line, Offset: l.offset, Description: desc, Code: diagnostic.TABULATION}
 }
 
 func (l *lexer) readNumberToken() tokens.Token {
 	return l.makeToken(tokens.NUMBER, string(l.readNumber()))
 }
 
+func (l *lexer) Comments() []tokens.Token {
+	return l.comments
+}
+
 func (l *lexer) skipComment() {
-	for !isNewline(l.char) && l.char != 0 {
-		l.read()
-	}
+	line, offset := l.line, l.offset
+	text := l.readSequence(func(char byte) bool {
+		return !isNewline(char) && char != 0
+	})
+
+	comment := tokens.Token{Type: tokens.COMMENT, Literal: strings.TrimRight(string(text), " "), Line: line, Offset: offset}
+	l.comments = append(l.comments, comment)
 }
 
 func (l *lexer) skipNewlines() {
@@ -177,3 +177,46 @@ func TestLexerLines(t *testing.T) {
 		}
 	}
 }
+
+func TestLexerErrors(t *testing.T) {
+	input := []byte("If x:\n\ty = 2\n  z = 3\nw\t= 4\n")
+
+	tests := []struct {
+		Type   tokens.TokenType
+		Line   int
+		Offset int
+		err    bool
+	}{
+		{tokens.IF, 1, 0, false},
+		{tokens.IDENT, 1, 3, false},
+		{tokens.COLON, 1, 4, false},
+		{tokens.NEWLINE, 1, 5, false},
+		{tokens.INDENT, 2, 4, true},
+		{tokens.IDENT, 2, 1, false},
+		{tokens.ASSIGN, 2, 3, false},
+		{tokens.NUMBER, 2, 5, false},
+		{tokens.NEWLINE, 2, 6, false},
+		{tokens.INDENT, 3, 4, true},
+		{tokens.IDENT, 3, 2, false},
+		{tokens.ASSIGN, 3, 4, false},
+		{tokens.NUMBER, 3, 6, false},
+		{tokens.NEWLINE, 3, 7, false},
+		{tokens.IDENT, 4, 0, false},
+		{tokens.ASSIGN, 4, 2, true},
+		{tokens.NUMBER, 4, 4, false},
+		{tokens.NEWLINE, 4, 5, false},
+		{tokens.EOF, 5, 0, false},
+	}
+
+	Lexer := lexer.New(input)
+
+	for i, test := range tests {
+		err, tok := Lexer.NextToken()
+		if (err != nil) != test.err {
+			t.Fatalf("tests[%d] - expected error=%t, got %v", i, test.err, err)
+		}
+		if tok.Type != test.Type || tok.Line != test.Line || tok.Offset != test.Offset {
+			t.Fatalf("tests[%d] - expected %q at %d:%d, got %q at %d:%d", i, test.Type, test.Line, test.Offset, tok.Type, tok.Line, tok.Offset)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/lsp"
+	"flag"
+	"log"
+	"os"
+)
+
+// languageServer speaks Language Server Protocol over stdin and stdout, so nothing else may be printed there
+func languageServer(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	parseFlags(flags, args)
+
+	if err := lsp.New().Serve(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,207 @@
+package lsp_test
+
+import (
+	"NiLang/src/lsp"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///bot.nil"
+
+const source = `Scope food:
+    Int calories = 10
+Using food
+Int x = calories + 1
+bot::Mo
+`
+
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+func frame(messages ...any) io.Reader {
+	var out bytes.Buffer
+	for _, m := range messages {
+		body, err := json.Marshal(m)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(&out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	return &out
+}
+
+func call(id int, method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func position(line int, character int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": line, "character": character}}
+}
+
+func serve(t *testing.T, messages ...any) (map[int]message, []message) {
+	var output bytes.Buffer
+	if err := lsp.New().Serve(frame(messages...), &output); err != nil {
+		t.Fatalf("Serve() has failed: %s", err)
+	}
+
+	responses := make(map[int]message)
+	notifications := make([]message, 0)
+
+	reader := bufio.NewReader(&output)
+	for {
+		header, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected header: %s", err)
+		}
+
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			t.Fatalf("unexpected body: %s", err)
+		}
+
+		var m message
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("response is not JSON: %s", err)
+		}
+
+		if m.ID != nil {
+			responses[*m.ID] = m
+		} else {
+			notifications = append(notifications, m)
+		}
+	}
+	return responses, notifications
+}
+
+func TestLanguageServer(t *testing.T) {
+	responses, notifications := serve(t,
+		call(1, "initialize", map[string]any{}),
+		notify("initialized", map[string]any{}),
+		notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "nilang", "version": 1, "text": source}}),
+		call(2, "textDocument/hover", position(3, 9)),
+		call(3, "textDocument/definition", position(3, 10)),
+		call(4, "textDocument/completion", position(4, 7)),
+		call(5, "textDocument/hover", position(0, 2)),
+		call(6, "unknown/method", map[string]any{}),
+		call(7, "shutdown", nil),
+		notify("exit", nil),
+	)
+
+	if !strings.Contains(string(responses[1].Result), `"hoverProvider":true`) {
+		t.Errorf("unexpected capabilities %s", responses[1].Result)
+	}
+
+	if len(notifications) != 1 || notifications[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics of the opened document, got=%v", notifications)
+	}
+
+	var diagnostics struct {
+		Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(notifications[0].Params, &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics.Diagnostics) == 0 {
+		t.Errorf("expected an error about the unfinished call")
+	} else if d := diagnostics.Diagnostics[0]; d.Severity != lsp.SEVERITY_ERROR || d.Code == "" {
+		t.Errorf("expected an error with a code, got %+v", d)
+	}
+
+	var hover lsp.Hover
+	if err := json.Unmarshal(responses[2].Result, &hover); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(hover.Contents.Value, "Int calories") || !strings.Contains(hover.Contents.Value, "food::calories") {
+		t.Errorf("unexpected hover %q", hover.Contents.Value)
+	}
+
+	var location lsp.Location
+	if err := json.Unmarshal(responses[3].Result, &location); err != nil {
+		t.Fatal(err)
+	}
+	expected := lsp.Range{Start: lsp.Position{Line: 1, Character: 8}, End: lsp.Position{Line: 1, Character: 16}}
+	if location.URI != uri || location.Range != expected {
+		t.Errorf("expected definition at %v, got=%v", expected, location)
+	}
+
+	var items []lsp.CompletionItem
+	if err := json.Unmarshal(responses[4].Result, &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Label != "Move" {
+		t.Errorf("expected completion Move, got=%v", items)
+	}
+
+	if string(responses[5].Result) != "null" {
+		t.Errorf("expected no hover for a keyword, got=%s", responses[5].Result)
+	}
+
+	if responses[6].Error == nil || responses[6].Error.Code != -32601 {
+		t.Errorf("expected error for unknown method, got=%+v", responses[6])
+	}
+}
+
+func TestDomainFiles(t *testing.T) {
+	root := t.TempDir()
+	helpers := "Domain helpers\nFun Double::Int$ x Int:\n    Return x * 2\n"
+	if err := os.WriteFile(filepath.Join(root, "helpers.nil"), []byte(helpers), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	document := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(root, "bot.nil"))}).String()
+	text := "Using helpers\nInt x = Double$ 2\nbot::WriteMemory$ x\n"
+	responses, notifications := serve(t,
+		call(1, "initialize", map[string]any{}),
+		notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": document, "languageId": "nilang", "version": 1, "text": text}}),
+		call(2, "textDocument/definition", map[string]any{"textDocument": map[string]any{"uri": document}, "position": map[string]any{"line": 1, "character": 9}}),
+		call(3, "shutdown", nil),
+		notify("exit", nil),
+	)
+
+	var diagnostics struct {
+		Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("expected diagnostics of the opened document, got=%v", notifications)
+	}
+	if err := json.Unmarshal(notifications[0].Params, &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics.Diagnostics) != 0 {
+		t.Errorf("expected no errors with the domain in the directory of the document, got %+v", diagnostics.Diagnostics)
+	}
+
+	var location lsp.Location
+	if err := json.Unmarshal(responses[2].Result, &location); err != nil {
+		t.Fatal(err)
+	}
+	expected := lsp.Range{Start: lsp.Position{Line: 1, Character: 4}, End: lsp.Position{Line: 1, Character: 10}}
+	if !strings.HasSuffix(location.URI, "/helpers.nil") || location.Range != expected {
+		t.Errorf("expected definition at helpers.nil %v, got=%v", expected, location)
+	}
+}
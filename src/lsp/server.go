@@ -0,0 +1,366 @@
+package lsp
+
+import (
+	"NiLang/src/common"
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/lexer"
+	"NiLang/src/parser"
+	"NiLang/src/test"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Server answers requests of an editor about NiLang documents it has opened
+type Server struct {
+	documents map[string]*document
+	output    io.Writer
+	shutdown  bool
+}
+
+type document struct {
+	text       []byte
+	references []compiler.Reference // kept from the last document without syntax errors
+}
+
+func New() *Server {
+	return &Server{documents: make(map[string]*document)}
+}
+
+// Serve handles messages until the client sends exit notification or closes the input
+func (s *Server) Serve(input io.Reader, output io.Writer) error {
+	s.output = output
+	reader := bufio.NewReader(input)
+
+	for {
+		body, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var message request
+		if err := json.Unmarshal(body, &message); err != nil {
+			if err := s.reply(nil, nil, &responseError{PARSE_ERROR, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if message.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit before shutdown request")
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(message.Method, message.Params)
+		if message.ID == nil {
+			continue // notifications have no response
+		}
+
+		if err := s.reply(message.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (any, *responseError) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // documents are synchronized by sending the full content
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{":"}},
+			},
+			"serverInfo": map[string]any{"name": "nilang", "version": common.VERSION},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{INVALID_PARAMS, err.Error()}
+		}
+		s.update(p.TextDocument.URI, []byte(p.TextDocument.Text))
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{INVALID_PARAMS, err.Error()}
+		}
+		if len(p.ContentChanges) != 0 {
+			s.update(p.TextDocument.URI, []byte(p.ContentChanges[len(p.ContentChanges)-1].Text))
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{INVALID_PARAMS, err.Error()}
+		}
+		delete(s.documents, p.TextDocument.URI)
+		s.publish(p.TextDocument.URI, []Diagnostic{})
+		return nil, nil
+	case "textDocument/hover", "textDocument/definition", "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &responseError{INVALID_PARAMS, err.Error()}
+		}
+
+		doc, ok := s.documents[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+
+		switch method {
+		case "textDocument/hover":
+			return doc.hover(p.Position), nil
+		case "textDocument/definition":
+			return doc.definition(p.TextDocument.URI, p.Position), nil
+		default:
+			return doc.complete(p.Position), nil
+		}
+	default:
+		if strings.HasPrefix(method, "$/") {
+			return nil, nil // optional notifications may be ignored
+		}
+		return nil, &responseError{METHOD_NOT_FOUND, fmt.Sprintf("method %q is not supported", method)}
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rerr *responseError) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	message := response{JSONRPC: "2.0", ID: id, Error: rerr}
+	if rerr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		message.Result = data
+	}
+	return writeMessage(s.output, message)
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) {
+	params := publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics}
+	// the client is gone if writing fails, reading of the next message reports it
+	_ = writeMessage(s.output, notification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+// update recompiles the document and publishes its errors
+func (s *Server) update(uri string, text []byte) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		doc = &document{}
+		s.documents[uri] = doc
+	}
+	doc.text = text
+
+	name := documentPath(uri)
+	errs, references, ok := analyze(name, text, strings.HasSuffix(uri, test.SUFFIX))
+	if ok {
+		doc.references = references
+	}
+
+	diagnostics := make([]Diagnostic, 0, len(errs))
+	for _, err := range errs {
+		if err.File != name {
+			continue // other files are reported when they are opened
+		}
+
+		diagnostic := Diagnostic{
+			Range:    doc.word(err.Line, err.Offset),
+			Severity: severity(err.Level()),
+			Code:     err.Code,
+			Source:   "nilang",
+			Message:  err.Description,
+		}
+		for _, related := range err.Related {
+			if related.File == "" || related.File == name {
+				location := Location{URI: uri, Range: doc.word(related.Line, related.Offset)}
+				diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, DiagnosticRelatedInformation{Location: location, Message: related.Description})
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	s.publish(uri, diagnostics)
+}
+
+func severity(level string) int {
+	switch level {
+	case helper.WARNING:
+		return SEVERITY_WARNING
+	case helper.NOTE:
+		return SEVERITY_INFORMATION
+	default:
+		return SEVERITY_ERROR
+	}
+}
+
+// analyze runs the parser and then the compiler, the latter only when the syntax is correct. Domains are looked for
+// in the directory of the document, references are kept only for names in the document. Tests may use the Assert builtin
+func analyze(name string, text []byte, isTest bool) ([]helper.Error, []compiler.Reference, bool) {
+	l := lexer.New(text)
+	p := parser.New(&l)
+	p.Parse()
+	if errs := p.Errors(); len(errs) != 0 {
+		for i := range errs {
+			errs[i].File = name
+		}
+		return errs, nil, false
+	}
+
+	var directories []string
+	if name != "" {
+		directories = []string{filepath.Dir(name)}
+	}
+
+	c := compiler.New(common.DefaultStackSize)
+	if isTest {
+		c.EnableAssertions()
+	}
+	_, errs := c.CompileFiles(name, text, directories)
+	errs = append(errs, c.Warnings()...)
+
+	references := make([]compiler.Reference, 0)
+	for _, reference := range c.References() {
+		if reference.File == name {
+			references = append(references, reference)
+		}
+	}
+	return errs, references, true
+}
+
+// documentPath is the name of the file of the document, empty for documents which are not files, e.g. untitled ones
+func documentPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// documentURI is the inverse of documentPath
+func documentURI(name string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(name)}).String()
+}
+
+// find returns the reference covering the position, the end of the name counts as well
+func (d *document) find(position Position) (compiler.Reference, bool) {
+	for _, reference := range d.references {
+		if reference.Line == position.Line+1 &&
+			reference.Offset <= position.Character && position.Character <= reference.Offset+reference.Length {
+			return reference, true
+		}
+	}
+	return compiler.Reference{}, false
+}
+
+func (d *document) hover(position Position) *Hover {
+	reference, ok := d.find(position)
+	if !ok {
+		return nil
+	}
+
+	definition := reference.Definition
+	value := fmt.Sprintf("```nilang\n%s\n```\n%s `%s`", definition.Signature, definition.Kind, definition.Name)
+	if definition.Line == 0 {
+		value += " (builtin)"
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    referenceRange(reference.Line, reference.Offset, reference.Length),
+	}
+}
+
+func (d *document) definition(uri string, position Position) *Location {
+	reference, ok := d.find(position)
+	if !ok || reference.Definition.Line == 0 {
+		return nil
+	}
+
+	definition := reference.Definition
+	if definition.File != reference.File {
+		uri = documentURI(definition.File) // declared by a domain the document uses
+	}
+	length := len(definition.Name[strings.LastIndex(definition.Name, ":")+1:])
+	return &Location{URI: uri, Range: referenceRange(definition.Line, definition.Offset, length)}
+}
+
+var completionContext = regexp.MustCompile(`\b(bot|dir)::([A-Za-z_0-9]*)$`)
+
+// complete suggests builtin functions after `bot::` and directions after `dir::`
+func (d *document) complete(position Position) []CompletionItem {
+	items := make([]CompletionItem, 0)
+
+	line := d.line(position.Line + 1)
+	prefix := line[:min(position.Character, len(line))]
+
+	match := completionContext.FindStringSubmatch(prefix)
+	if match == nil {
+		return items
+	}
+
+	switch match[1] {
+	case "bot":
+		for _, builtin := range compiler.BUILTIN_FUNCTIONS {
+			if strings.HasPrefix(builtin.Name, match[2]) {
+				items = append(items, CompletionItem{Label: builtin.Name, Kind: COMPLETION_FUNCTION, Detail: builtin.Signature})
+			}
+		}
+	case "dir":
+		for _, direction := range compiler.DIRECTION_NAMES[compiler.DIR_BEGIN+1:] {
+			if strings.HasPrefix(direction, match[2]) {
+				items = append(items, CompletionItem{Label: direction, Kind: COMPLETION_ENUM_MEMBER, Detail: compiler.Dir})
+			}
+		}
+	}
+	return items
+}
+
+func (d *document) line(line int) string {
+	lines := strings.Split(string(d.text), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// word returns range of the name starting at the offset, errors point at the first character of a token
+func (d *document) word(line int, offset int) Range {
+	text := d.line(line)
+	end := offset
+	for end < len(text) && isNameCharacter(text[end]) {
+		end++
+	}
+	return referenceRange(line, offset, max(end-offset, 1))
+}
+
+func isNameCharacter(char byte) bool {
+	return char == '_' || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z') || ('0' <= char && char <= '9')
+}
+
+func referenceRange(line int, offset int, length int) Range {
+	return Range{
+		Start: Position{Line: max(line-1, 0), Character: offset},
+		End:   Position{Line: max(line-1, 0), Character: offset + length},
+	}
+}
@@ -5,20 +5,55 @@ package main
 import (
 	"NiLang/src/common"
 	"NiLang/src/compiler"
+	"NiLang/src/diagnostic"
 	"NiLang/src/helper"
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 )
 
+var commands = map[string]func(args []string){
+	"run":     run,
+	"sim":     simulate,
+	"debug":   debug,
+	"lsp":     languageServer,
+	"fmt":     formatFiles,
+	"vet":     vetFiles,
+	"repl":    repl,
+	"test":    testFiles,
+	"build":   buildFiles,
+	"explain": explain,
+}
+
 func main() {
-	stackSize := flag.Int("s", common.DefaultStackSize, "stack size in bytes")
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	stackSize := flag.Int("s", compiler.AUTO_STACK_SIZE, "stack size in bytes, measured from the program if 0")
+	memorySize := flag.Int("m", compiler.TARGET_MEMORY_SIZE, "memory size of the target bot in bytes, a program taking more is an error, 0 for no limit")
+	optimization := flag.Int("O", compiler.DEFAULT_OPTIMIZATION, "optimization level, 0 keeps code as emitted, 1 removes redundant commands, 2 also keeps operands in registers")
+	frames := flag.Int("frames", compiler.DEFAULT_FRAMES, "calls of a recursive function which may run at once, each copies the memory of the function, 0 makes recursion an error")
 	outputFilename := flag.String("o", "bot.tor", "output file name")
 	printAST := flag.Bool("AST", false, "print abstract syntax tree in a human readable form (pseudo-code), use it for debugging the compiler")
 	printVersion := flag.Bool("version", false, "print current version of the compiler")
+	writeSourceMap := flag.Bool("map", false, "write source map linking output instructions to source lines into <output>.map")
+	printSummary := flag.Bool("summary", false, "print stack, memory and commands of frame routines taken by the program")
+	memoryMap := flag.String("memory-map", "", "write a table of addresses of the stack and variables with their scopes and types into the file")
+	directories := domainFlags(flag.CommandLine)
+	report := diagnosticsFlag(flag.CommandLine)
+	flag.Usage = usage
 	flag.Parse()
 
 	if *printVersion {
@@ -33,12 +68,89 @@ func main() {
 		fileName = flag.Arg(0)
 	}
 
+	c, code, ok := compileFile(fileName, directories(fileName), *stackSize, *memorySize, *optimization, *frames, *printAST, report())
+	if !ok {
+		os.Exit(1)
+	}
+
+	output, err := os.Create(*outputFilename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer func() {
+		if err := output.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	_, err = output.Write(code)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *writeSourceMap {
+		writeMap(c, fileName, *outputFilename+".map")
+	}
+	if *memoryMap != "" {
+		writeMemoryMap(c, *memoryMap)
+	}
+	if *printSummary {
+		fmt.Printf("%s -> %s: %s\n", fileName, *outputFilename, summary(c.Usage()))
+	}
+}
+
+func summary(usage compiler.Usage) string {
+	figures := fmt.Sprintf("stack %d of %d bytes, memory %d bytes", usage.StackUsed, usage.Stack, usage.Memory)
+	if usage.FrameCode != 0 {
+		figures += fmt.Sprintf(", frame routines %d commands", usage.FrameCode)
+	}
+	return figures
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file.nil\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(flag.CommandLine.Output(), "       %s <command> [flags] file\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  run\texecute a bot on the local virtual machine\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  sim\tsimulate a population of bots in a headless world\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  debug\tstep through a bot on the local virtual machine\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  lsp\tserve Language Server Protocol over stdin and stdout\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  fmt\treformat code in the canonical layout\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  vet\treport suspicious code which compiles\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  repl\tevaluate code typed line by line\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  test\trun test functions of *_test.nil files\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  build\tcompile every bot of directories in parallel\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  explain\tdescribe a code of errors and warnings, e.g. NL0012\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// parseFlags allows flags to follow positional arguments, e.g. `nilang run bot.nil -trace`
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+	for {
+		if err := flags.Parse(args); err != nil {
+			os.Exit(2)
+		}
+		if flags.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// absolute is the name of the file in errors
+func absolute(fileName string) string {
 	abs, err := filepath.Abs(fileName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	helper.SetFilename(abs)
+	return abs
+}
 
+func readFile(fileName string) []byte {
 	file, err := os.Open(fileName)
 	if err != nil {
 		log.Fatal(err)
@@ -54,29 +166,198 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	return input
+}
+
+// domainFlags adds flags telling where files of domains are looked for
+func domainFlags(flags *flag.FlagSet) func(fileName string) []string {
+	root := flags.String("root", "", "root of the project, where files of domains are looked for first, the directory of the file by default")
+	path := flags.String("path", os.Getenv("NILANGPATH"), "more directories with files of domains separated by "+string(os.PathListSeparator))
 
-	c := compiler.New(*stackSize)
-	code, errors := c.Compile(input, *printAST)
-	if len(errors) != 0 {
-		for _, err := range errors {
-			helper.PrintError(err, input)
+	return func(fileName string) []string {
+		directories := []string{*root}
+		if *root == "" {
+			directories[0] = filepath.Dir(fileName)
 		}
-		return
+		directories = append(directories, filepath.SplitList(*path)...)
+
+		// files are named in errors the same way as the main file
+		for i, directory := range directories {
+			if abs, err := filepath.Abs(directory); err == nil {
+				directories[i] = abs
+			}
+		}
+		return directories
 	}
+}
 
-	output, err := os.Create(*outputFilename)
+// diagnosticsFlag adds flags choosing the format of errors and the warnings to report, the report prints to standard output
+func diagnosticsFlag(flags *flag.FlagSet) func() *diagnostic.Report {
+	format := flags.String("diagnostics", diagnostic.TEXT, fmt.Sprintf("format of errors and warnings, one of %v", diagnostic.FORMATS))
+	werror := flags.Bool("Werror", false, "treat warnings as errors")
+	disabled := flags.String("Wno", "", "comma separated codes of warnings not to report, e.g. NL0101,NL0104")
+
+	return func() *diagnostic.Report {
+		report, err := diagnostic.NewReport(*format, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *werror {
+			report.TreatWarningsAsErrors()
+		}
+		if *disabled != "" {
+			codes := strings.Split(*disabled, ",")
+			for i := range codes {
+				codes[i] = strings.TrimSpace(codes[i])
+			}
+			if err := report.Disable(codes...); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return report
+	}
+}
+
+// compileFile compiles the file with domains it uses and reports errors of compilation if there are any
+func compileFile(fileName string, directories []string, stackSize int, memorySize int, optimization int, frames int, printAST bool, report *diagnostic.Report) (*compiler.Compiler, []byte, bool) {
+	input := readFile(fileName)
+	name := absolute(fileName)
+
+	c := compiler.New(stackSize)
+	c.LimitMemory(memorySize)
+	c.Optimize(optimization)
+	c.LimitFrames(frames)
+	code, errors := c.CompileFiles(name, input, directories)
+	if printAST {
+		fmt.Println("PROGRAM TREE")
+		for _, program := range c.Programs() {
+			for _, statement := range program.Statements {
+				fmt.Println(statement.String())
+			}
+		}
+		fmt.Println("END")
+	}
+
+	reportErrors(report, append(errors, c.Warnings()...), name, input)
+
+	if err := report.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	return c, code, report.Errors() == 0
+}
+
+// reportErrors adds errors of compilation of the file to the report, sources of other files are read for the text
+func reportErrors(report *diagnostic.Report, errors []helper.Error, name string, input []byte) {
+	for _, err := range errors {
+		source := input
+		if err.File != name {
+			source, _ = os.ReadFile(err.File)
+		}
+		report.Add(err, source)
+	}
+}
+
+// writeMap stores source map of the compiled file, source paths are relative to the map
+func writeMap(c *compiler.Compiler, fileName string, mapFilename string) {
+	source, err := filepath.Abs(fileName)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer func() {
-		if err := output.Close(); err != nil {
+	dir, err := filepath.Abs(filepath.Dir(mapFilename))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sourceMap := c.SourceMap(source)
+	for i, mapping := range sourceMap.Mappings {
+		if relative, err := filepath.Rel(dir, mapping.File); err == nil {
+			sourceMap.Mappings[i].File = filepath.ToSlash(relative)
+		}
+	}
+
+	data, err := json.MarshalIndent(sourceMap, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(mapFilename, append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// findFiles expands directories to the matching files in them, dir/... stands for files of the directory and its
+// subdirectories, the files are sorted within a directory
+func findFiles(patterns []string, match func(path string) bool) []string {
+	fileNames := make([]string, 0)
+	for _, pattern := range patterns {
+		if root, ok := strings.CutSuffix(pattern, "..."); ok {
+			if root == "" {
+				root = "."
+			}
+			err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() && match(path) {
+					fileNames = append(fileNames, path)
+				}
+				return err
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+
+		info, err := os.Stat(pattern)
+		if err != nil {
 			log.Fatal(err)
 		}
-	}()
+		if !info.IsDir() {
+			fileNames = append(fileNames, pattern)
+			continue
+		}
 
-	_, err = output.Write(code)
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range entries {
+			if path := filepath.Join(pattern, entry.Name()); !entry.IsDir() && match(path) {
+				fileNames = append(fileNames, path)
+			}
+		}
+	}
+	return fileNames
+}
+
+// relative shortens the path of a source file for messages if it is inside the working directory
+func relative(path string) string {
+	wd, err := os.Getwd()
 	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// writeMemoryMap stores the table of addresses taken by the compiled program
+func writeMemoryMap(c *compiler.Compiler, fileName string) {
+	var output bytes.Buffer
+	w := tabwriter.NewWriter(&output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tSCOPE\tNAME\tTYPE")
+	for _, allocation := range c.MemoryMap() {
+		addr := strconv.Itoa(allocation.Addr)
+		if allocation.Size > 1 {
+			addr = fmt.Sprintf("%d-%d", allocation.Addr, allocation.Addr+allocation.Size-1)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", addr, allocation.Scope, allocation.Name, allocation.Type)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(fileName, output.Bytes(), 0644); err != nil {
 		log.Fatal(err)
 	}
 }
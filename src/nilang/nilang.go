@@ -0,0 +1,102 @@
+// Package nilang compiles NiLang programs for services embedding the compiler,
+// it never writes to the standard output and never exits the process
+package nilang
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/compiler"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"context"
+	"errors"
+	"fmt"
+)
+
+type File struct {
+	Name   string // used in diagnostics, files of domains are named after their domain, e.g. food/berry.nil
+	Source []byte
+}
+
+type Options struct {
+	Files        []File   // the first one is the program, the others are files of domains it may use
+	Directories  []string // searched for domains missing in Files, nil to never read the file system
+	StackSize    int      // measured from the program if zero
+	MemorySize   int      // bytes of memory of the target bot, a program taking more is an error, no limit if zero
+	Optimization int      // level of compiler.Optimize, the code is kept as emitted if zero
+	Frames       int      // of compiler.LimitFrames, recursion is an error if zero
+	AST          bool     // keep syntax trees in the result
+}
+
+// DefaultOptions returns the options of the command line compiler for the files: the memory of a bot, the default
+// optimization and frames. The zero Options don't limit memory, keep the code as emitted and make recursion an error
+func DefaultOptions(files ...File) Options {
+	return Options{Files: files, MemorySize: compiler.TARGET_MEMORY_SIZE, Optimization: compiler.DEFAULT_OPTIMIZATION, Frames: compiler.DEFAULT_FRAMES}
+}
+
+type Result struct {
+	Code        []byte // assembly of TorLand, nil if there are errors
+	Diagnostics []diagnostic.Diagnostic
+	AST         []*ast.Program // syntax trees of the compiled files, the program is the last one
+	StackSize   int            // bytes of the stack reserved by the code, the measured one if Options.StackSize is zero
+}
+
+// Failed reports whether the program has errors
+func (r Result) Failed() bool {
+	for _, d := range r.Diagnostics {
+		if d.Severity == helper.ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Compile compiles the program, errors of the program are diagnostics of the result, while the returned error
+// means the compilation has not been done: the options are wrong or the context is done
+func Compile(ctx context.Context, options Options) (result Result, err error) {
+	if len(options.Files) == 0 {
+		return Result{}, errors.New("no files to compile")
+	}
+	if options.StackSize < 0 {
+		return Result{}, fmt.Errorf("negative stack size %d", options.StackSize)
+	}
+	if options.MemorySize < 0 {
+		return Result{}, fmt.Errorf("negative memory size %d", options.MemorySize)
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = Result{}, fmt.Errorf("internal compiler error: %v", r)
+		}
+	}()
+
+	c := compiler.New(options.StackSize)
+	c.LimitMemory(options.MemorySize)
+	c.Optimize(options.Optimization)
+	c.LimitFrames(options.Frames)
+	for _, f := range options.Files[1:] {
+		c.AddSource(f.Name, f.Source)
+	}
+
+	program := options.Files[0]
+	code, errs := c.CompileFiles(program.Name, program.Source, options.Directories)
+	errs = append(errs, c.Warnings()...)
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	result.Diagnostics = make([]diagnostic.Diagnostic, 0, len(errs))
+	for _, err := range errs {
+		result.Diagnostics = append(result.Diagnostics, diagnostic.New(err))
+	}
+	if !result.Failed() {
+		result.Code = code
+		result.StackSize = c.Usage().Stack
+	}
+	if options.AST {
+		result.AST = c.Programs()
+	}
+	return result, nil
+}
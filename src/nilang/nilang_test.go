@@ -0,0 +1,154 @@
+package nilang_test
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/diagnostic"
+	"NiLang/src/nilang"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func compile(t *testing.T, options nilang.Options) nilang.Result {
+	t.Helper()
+
+	result, err := nilang.Compile(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestCompile(t *testing.T) {
+	options := nilang.DefaultOptions(
+		nilang.File{Name: "main.nil", Source: []byte("Using food::berry\nInt x = calories\nbot::WriteMemory$ x\n")},
+		nilang.File{Name: "food/berry.nil", Source: []byte("Domain food::berry\nInt calories = 10\n")},
+	)
+	options.AST = true
+	result := compile(t, options)
+
+	if result.Failed() || len(result.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %+v", result.Diagnostics)
+	}
+	if !strings.Contains(string(result.Code), "ldv") {
+		t.Errorf("expected code, got %q", result.Code)
+	}
+	if len(result.AST) != 2 || !strings.HasPrefix(result.AST[1].Statements[0].String(), "Using") {
+		t.Errorf("expected trees of the domain and of the program, got %v", result.AST)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	result := compile(t, nilang.DefaultOptions(
+		nilang.File{Name: "main.nil", Source: []byte("Using food\nInt x = True\n")},
+		nilang.File{Name: "food.nil", Source: []byte("Domain food\nbot::Mov\n")},
+	))
+
+	if !result.Failed() || result.Code != nil || result.AST != nil {
+		t.Fatalf("expected errors without code, got %+v", result)
+	}
+
+	expected := []diagnostic.Diagnostic{
+		{File: "food.nil", Line: 2, Column: 5, Code: diagnostic.UNDECLARED, Message: `undeclared function "Mov"`},
+		{File: "main.nil", Line: 2, Column: 4, Code: diagnostic.TYPE_MISMATCH},
+	}
+	if len(result.Diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(expected), result.Diagnostics)
+	}
+	for i, e := range expected {
+		d := result.Diagnostics[i]
+		if d.File != e.File || d.Line != e.Line || d.Column != e.Column || d.Code != e.Code || e.Message != "" && d.Message != e.Message {
+			t.Errorf("expected %+v, got %+v", e, d)
+		}
+	}
+}
+
+func TestNoOutput(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+
+	for _, source := range []string{"Int x = \n", "Int x = y\n", "Int x = 1 + 2 * 3 - 4 * 5\n"} {
+		_, _ = nilang.Compile(context.Background(), nilang.Options{Files: []nilang.File{{Name: "bot.nil", Source: []byte(source)}}, StackSize: 1, AST: true})
+	}
+
+	os.Stdout = stdout
+	writer.Close()
+	if output, _ := io.ReadAll(reader); len(output) != 0 {
+		t.Errorf("expected no output, got %q", output)
+	}
+}
+
+func TestStackSize(t *testing.T) {
+	source := []byte("Int e = bot::GetEnergy\nInt x = e + e * e - e * e\n")
+
+	options := nilang.DefaultOptions(nilang.File{Name: "bot.nil", Source: source})
+	result := compile(t, options)
+	if result.Failed() || result.StackSize != 4 || !strings.Contains(string(result.Code), "ldr [5] AX") {
+		t.Errorf("expected the stack of 4 bytes followed by variables, got %d bytes and %+v", result.StackSize, result.Diagnostics)
+	}
+
+	options.StackSize = 1
+	result = compile(t, options)
+	expected := diagnostic.Diagnostic{File: "bot.nil", Line: 2, Column: 14, EndColumn: 15, Severity: "error", Code: diagnostic.STACK_OVERFLOW,
+		Message: "stack overflow, the statement needs 4 bytes of stack, StackSize=1"}
+	if len(result.Diagnostics) != 1 || !reflect.DeepEqual(result.Diagnostics[0], expected) || result.Code != nil {
+		t.Errorf("expected %+v, got %+v", expected, result.Diagnostics)
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	source := []byte(`Fun Fib::Int$ n Int:
+    If n < 2:
+        Return n
+    Int previous = Fib$ n - 1
+    Return previous + Fib$ n - 2
+bot::WriteMemory$ Fib$ 10
+`)
+
+	// defaults of the flags of the command line compiler
+	c := compiler.New(compiler.AUTO_STACK_SIZE)
+	c.LimitMemory(compiler.TARGET_MEMORY_SIZE)
+	c.Optimize(compiler.DEFAULT_OPTIMIZATION)
+	c.LimitFrames(compiler.DEFAULT_FRAMES)
+	expected, errors := c.Compile(source)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	result := compile(t, nilang.DefaultOptions(nilang.File{Name: "bot.nil", Source: source}))
+	if result.Failed() || !reflect.DeepEqual(result.Code, expected) {
+		t.Errorf("expected the code of the command line compiler, got %+v", result.Diagnostics)
+	}
+
+	result = compile(t, nilang.Options{Files: []nilang.File{{Name: "bot.nil", Source: source}}})
+	if len(result.Diagnostics) != 2 || result.Diagnostics[0].Code != diagnostic.RECURSION || result.Diagnostics[1].Code != diagnostic.RECURSION {
+		t.Errorf("expected both calls of Fib to be errors with the zero options, got %+v", result.Diagnostics)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files := []nilang.File{{Name: "bot.nil", Source: []byte("bot::Sleep\n")}}
+	for _, tt := range []struct {
+		ctx     context.Context
+		options nilang.Options
+	}{
+		{context.Background(), nilang.Options{}},
+		{context.Background(), nilang.Options{Files: files, StackSize: -1}},
+		{context.Background(), nilang.Options{Files: files, MemorySize: -1}},
+		{ctx, nilang.Options{Files: files}},
+	} {
+		if _, err := nilang.Compile(tt.ctx, tt.options); err == nil {
+			t.Errorf("expected error for %+v", tt.options)
+		}
+	}
+}
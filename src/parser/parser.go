@@ -2,6 +2,7 @@ package parser
 
 import (
 	"NiLang/src/ast"
+	"NiLang/src/diagnostic"
 	"NiLang/src/helper"
 	"NiLang/src/lexer"
 	"NiLang/src/tokens"
@@ -66,6 +67,8 @@ type Parser struct {
 	pleaseDontParseCallExpr bool //TODO: don't use global state, maybe more elegant solutions is achievable,
 	// Such that we don't need to use workaround with call expression
 	allowToGoToTheNextLevel bool
+
+	panicking bool // the statement has failed, its following errors are consequences of the first one
 }
 
 func New(lexer *lexer.Lexer) *Parser {
@@ -118,9 +121,9 @@ func (p *Parser) nextToken() {
 	var err *helper.Error
 	err, p.next = (*p.lexer).NextToken()
 
+	// the lexer goes on after its errors, so does the parser, they don't depend on the statement
 	if err != nil {
-		p.addError(*err)
-		return
+		p.errors = append(p.errors, *err)
 	}
 
 	if p.isCurrent(tokens.INDENT) {
@@ -134,7 +137,7 @@ func (p *Parser) nextToken() {
 			return
 		}
 
-		error := helper.MakeError(p.current, fmt.Sprintf("illegal indentation, expected=%d, got=%d", old_level, p.level))
+		error := helper.MakeError(p.current, diagnostic.UNEXPECTED_INDENT, fmt.Sprintf("illegal indentation, expected=%d, got=%d", old_level, p.level))
 		p.addError(error)
 	}
 }
@@ -144,9 +147,14 @@ func (p *Parser) Parse() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.isCurrent(tokens.EOF) {
+		// too deep indentation has been reported on the way to the statement
+		line, errors, indented := p.current.Line, len(p.errors), p.level > 0
+		p.panicking = false
 		ok, statement := p.parseStatement()
-		if ok {
+		if ok && len(p.errors) == errors && !indented {
 			program.Statements = append(program.Statements, statement)
+		} else {
+			p.recover(ok, line, 0)
 		}
 
 		if p.pleaseDontSkipToken {
@@ -156,6 +164,8 @@ func (p *Parser) Parse() *ast.Program {
 		}
 		p.nextToken()
 	}
+
+	program.Comments = (*p.lexer).Comments()
 	return program
 }
 
@@ -163,6 +173,8 @@ func (p *Parser) parseStatement() (bool, ast.Statement) {
 	switch p.current.Type {
 	case tokens.USING:
 		return p.parseUsingStatement()
+	case tokens.DOMAIN:
+		return p.parseDomainStatement()
 	case tokens.RETURN:
 		return p.parseReturnStatement()
 	case tokens.SCOPE:
@@ -182,7 +194,7 @@ func (p *Parser) parseStatement() (bool, ast.Statement) {
 		res := &ast.ContinueStatement{Token: p.current}
 		return p.expectNext(tokens.NEWLINE), res
 	case tokens.COLON, tokens.EOF, tokens.INDENT, tokens.NEWLINE, tokens.ELIF:
-		err := helper.MakeError(p.current, fmt.Sprintf("attempt to parse invalid token %s", p.current.Type))
+		err := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, fmt.Sprintf("attempt to parse invalid token %s", p.current.Type))
 		p.addError(err)
 		return false, nil
 	default:
@@ -244,7 +256,7 @@ func (p *Parser) parseType() ast.Expression {
 		ok, exp := p.parseExpressionStatement()
 		p.pleaseDontParseCallExpr = false
 		if !ok {
-			error := helper.MakeError(p.current, "couldn't parse type expression")
+			error := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, "couldn't parse type expression")
 			p.addError(error)
 		}
 
@@ -257,12 +269,12 @@ func (p *Parser) parseType() ast.Expression {
 				return scope
 			}
 		default:
-			error := helper.MakeError(p.current, fmt.Sprintf("expected scope expression, got=%T", exp.Expression))
+			error := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, fmt.Sprintf("expected scope expression, got=%T", exp.Expression))
 			p.addError(error)
 		}
 	}
 
-	error := helper.MakeError(p.current, fmt.Sprintf("type starts with the wrong token expected %q or %q, got=%q",
+	error := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, fmt.Sprintf("type starts with the wrong token expected %q or %q, got=%q",
 		tokens.PIDENT, tokens.IDENT, p.current))
 	p.addError(error)
 
@@ -281,6 +293,18 @@ func (p *Parser) parseUsingStatement() (bool, *ast.UsingStatement) {
 	return true, statement
 }
 
+func (p *Parser) parseDomainStatement() (bool, *ast.DomainStatement) {
+	statement := &ast.DomainStatement{Token: p.current}
+
+	if !p.expectNext(tokens.IDENT) {
+		return false, nil
+	}
+
+	statement.Name = p.parseExpression(LOWEST)
+
+	return true, statement
+}
+
 func (p *Parser) parseReturnStatement() (bool, *ast.ReturnStatement) {
 	statement := &ast.ReturnStatement{Token: p.current}
 
@@ -421,7 +445,7 @@ func (p *Parser) parseExpressionStatement() (bool, *ast.ExpressionStatement) {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix, ok := p.prefixParseFns[p.current.Type]
 	if !ok {
-		error := helper.MakeError(p.current, fmt.Sprintf("no prefix parse function for %s found", p.current.Type))
+		error := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, fmt.Sprintf("no prefix parse function for %s found", p.current.Type))
 		p.addError(error)
 		return nil
 	}
@@ -478,12 +502,16 @@ func (p *Parser) Errors() errors { return p.errors }
 
 func (p *Parser) error(token tokens.TokenType, expected tokens.Token, name string) {
 	desc := fmt.Sprintf("expected %s token to be %s, got %s instead", name, token, expected.Type)
-	error := helper.MakeError(expected, desc)
+	error := helper.MakeError(expected, diagnostic.UNEXPECTED_TOKEN, desc)
 	p.addError(error)
 }
 
 func (p *Parser) addError(error helper.Error) {
+	if p.panicking {
+		return
+	}
 	p.errors = append(p.errors, error)
+	p.panicking = true
 }
 
 func (p *Parser) nextError(token tokens.TokenType) {
@@ -507,7 +535,7 @@ func (p *Parser) parseIntegralLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.current.Literal, 0, 64)
 	if err != nil {
-		error := helper.MakeError(p.current, fmt.Sprintf("could not parse %q as integer", p.current.Literal))
+		error := helper.MakeError(p.current, diagnostic.INVALID_LITERAL, fmt.Sprintf("could not parse %q as integer", p.current.Literal))
 		p.addError(error)
 		return nil
 	}
@@ -526,7 +554,7 @@ func (p *Parser) parseBooleanLiteral() ast.Expression {
 	} else if tokenType == tokens.FALSE {
 		lit.Value = false
 	} else {
-		error := helper.MakeError(p.current, fmt.Sprintf("could not parse %q as boolean", p.current.Literal))
+		error := helper.MakeError(p.current, diagnostic.INVALID_LITERAL, fmt.Sprintf("could not parse %q as boolean", p.current.Literal))
 		p.addError(error)
 		return nil
 	}
@@ -622,9 +650,29 @@ func (p *Parser) parseIfStatement() (bool, ast.Statement) {
 	return true, statement
 }
 
+// recover skips the rest of the statement which has begun at the line and has failed, including its block if it has one.
+// It stops at the end of a line followed by a line of the given level or an outer one, the next statement begins there.
+// A statement which has parsed its block to the end is left as it is, errors inside the block have been recovered already
+func (p *Parser) recover(ok bool, line int, level int) {
+	if ok && p.pleaseDontSkipToken {
+		return
+	}
+	p.pleaseDontSkipToken = false
+	p.panicking = true
+
+	for !p.isCurrent(tokens.EOF) {
+		if p.isCurrent(tokens.NEWLINE) && p.current.Line >= line && p.IsNextLevel() <= level {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+// gotoNextLine moves to the next line of the block, a deeper line belongs to the block as well, its statement is
+// skipped after the error of indentation
 func (p *Parser) gotoNextLine(level int) bool {
 	if p.isCurrent(tokens.NEWLINE) {
-		if p.IsNextLevel() == level {
+		if p.IsNextLevel() >= level {
 			p.nextToken()
 			return true
 		}
@@ -642,7 +690,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	if level != p.level {
 		desc := fmt.Sprintf("expected one level of indentation after expression, got %d instead", p.level)
-		error := helper.MakeError(p.current, desc)
+		error := helper.MakeError(p.current, diagnostic.UNEXPECTED_INDENT, desc)
 		p.addError(error)
 		return nil
 	}
@@ -652,9 +700,13 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	}
 
 	for isInBlock() {
+		line, errors, indented := p.current.Line, len(p.errors), p.level > level
+		p.panicking = false
 		ok, statement := p.parseStatement()
-		if ok {
+		if ok && len(p.errors) == errors && !indented {
 			block.Statements = append(block.Statements, statement)
+		} else {
+			p.recover(ok, line, level)
 		}
 
 		if !isInBlock() {
@@ -683,7 +735,7 @@ func (p *Parser) parseAliasValues(t ast.Expression) []*ast.DeclarationStatement
 
 	if level != p.level {
 		desc := fmt.Sprintf("expected one level of indentation after expression, got %d instead", p.level)
-		error := helper.MakeError(p.current, desc)
+		error := helper.MakeError(p.current, diagnostic.UNEXPECTED_INDENT, desc)
 		p.addError(error)
 		return nil
 	}
@@ -731,7 +783,7 @@ func (p *Parser) parseCallExpressionPrefix() ast.Expression {
 	exp.Arguments = nil
 
 	if !p.isCurrent(tokens.NEWLINE) && (p.isNext(tokens.IDENT) || p.isNext(tokens.PIDENT)) {
-		error := helper.MakeError(p.current, fmt.Sprintf("unexpected identity %q on the same line with call expression", p.next.Literal))
+		error := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, fmt.Sprintf("unexpected identity %q on the same line with call expression", p.next.Literal))
 		p.addError(error)
 	}
 	return exp
@@ -742,7 +794,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp.Arguments = p.parseCallArguments()
 
 	if !p.isCurrent(tokens.NEWLINE) && (p.isNext(tokens.IDENT) || p.isNext(tokens.PIDENT)) {
-		error := helper.MakeError(p.current, fmt.Sprintf("unexpected identity %q on the same line with call expression", p.next.Literal))
+		error := helper.MakeError(p.current, diagnostic.UNEXPECTED_TOKEN, fmt.Sprintf("unexpected identity %q on the same line with call expression", p.next.Literal))
 		p.addError(error)
 	}
 	return exp
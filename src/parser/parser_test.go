@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -305,6 +306,34 @@ Using world::compass`)
 	}
 }
 
+func TestDomainStatement(test *testing.T) {
+	input := []byte(`Domain helpers::math
+Using helpers`)
+
+	lexer := lexer.New(input)
+	parser := parser.New(&lexer)
+
+	program := parser.Parse()
+	if program == nil {
+		test.Fatalf("parser.Parse() has returned nil")
+	}
+	checkParseErrors(test, parser, input)
+
+	length := 2
+	if len(program.Statements) != length {
+		test.Fatalf("program.Statements doesn't contain %d statements: got=%v", length, len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.DomainStatement)
+	if !ok {
+		test.Fatalf("program.Statements[0] is not ast.DomainStatement, got=%T", program.Statements[0])
+	}
+
+	if statement.String() != "Domain helpers::math" {
+		test.Errorf("statement.String() is not %q, got=%q", "Domain helpers::math", statement.String())
+	}
+}
+
 func TestReturnStatement(test *testing.T) {
 	input := []byte(`Return 2`)
 
@@ -1950,3 +1979,73 @@ func testTypedIdentifier(test *testing.T, ident ast.Variable, t string, value st
 
 	return true
 }
+
+func TestErrorRecovery(test *testing.T) {
+	input := []byte(`Int x = 1
+Int y = = 2
+Fun F::Int$ a Int:
+    Return a
+If x > :
+    x = 2
+    x = 3
+Scope s:
+    Int q = *
+    Int r = 1
+While x <
+    x = 3
+Alias Code::Int:
+    ok = 1
+    bad = +
+Fun G:
+    Int a = 1
+        Int b = 2
+    Int c =
+Int last = )
+`)
+
+	lexer := lexer.New(input)
+	parser := parser.New(&lexer)
+	program := parser.Parse()
+
+	expected := []struct {
+		line   int
+		offset int
+	}{
+		{2, 8},
+		{5, 7},
+		{9, 12},
+		{11, 9},
+		{15, 10},
+		{18, 8},
+		{19, 11},
+		{20, 11},
+	}
+
+	errors := parser.Errors()
+	if len(errors) != len(expected) {
+		for _, err := range errors {
+			helper.PrintError(err, input)
+		}
+		test.Fatalf("expected %d parsing errors, got=%d", len(expected), len(errors))
+	}
+
+	for i, tt := range expected {
+		if errors[i].Line != tt.line || errors[i].Offset != tt.offset {
+			test.Errorf("errors[%d] expected at %d:%d, got %d:%d: %s", i, tt.line, tt.offset, errors[i].Line, errors[i].Offset, errors[i].Description)
+		}
+	}
+
+	// statements without errors are kept
+	names := make([]string, 0)
+	for _, statement := range program.Statements {
+		switch s := statement.(type) {
+		case *ast.DeclarationStatement:
+			names = append(names, s.Var.Name)
+		case *ast.FunctionStatement:
+			names = append(names, s.Var.Name)
+		}
+	}
+	if strings.Join(names, " ") != "x F" {
+		test.Errorf("expected statements x and F to be parsed, got %v", names)
+	}
+}
@@ -0,0 +1,230 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/botlang"
+	"NiLang/src/common"
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/lexer"
+	"NiLang/src/parser"
+	"NiLang/src/vm"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func repl(args []string) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	stackSize := flags.Int("s", common.DefaultStackSize, "stack size in bytes")
+	memorySize := flags.Int("m", vm.DefaultMemorySize, "memory size of the virtual machine")
+	steps := flags.Int("steps", 100000, "maximum number of instructions executed for one input")
+	energy := flags.Int("energy", 1000, "energy level reported to the bot")
+	showCode := flags.Bool("botlang", false, "print botlang emitted for every input")
+	parseFlags(flags, args)
+
+	s := &session{stackSize: *stackSize, memorySize: *memorySize, steps: *steps, energy: *energy, showCode: *showCode}
+	s.reset()
+	s.serve(os.Stdin)
+}
+
+const replHelp = `Type declarations, statements and expressions, a line ending with ':' opens a block
+which is finished by an empty line. Names declared earlier stay visible.
+  :botlang  switch printing of emitted botlang
+  :reset    forget everything declared so far
+  :help     print this help
+  :quit     leave the REPL`
+
+// session compiles inputs one by one in the same global scope and runs them on the same virtual machine
+type session struct {
+	stackSize  int
+	memorySize int
+	steps      int
+	energy     int
+	showCode   bool
+
+	compiler *compiler.Compiler
+	machine  *vm.VM
+	history  [][]byte // inputs compiled without errors, they are compiled again after a failed input
+}
+
+func (s *session) reset() {
+	s.compiler = compiler.New(s.stackSize)
+	s.history = nil
+
+	program, err := vm.Load(nil)
+	if err != nil {
+		panic(err)
+	}
+	s.machine = vm.New(program, &consoleWorld{energy: s.energy}, s.memorySize)
+}
+
+func (s *session) serve(input io.Reader) {
+	fmt.Println("NiLang " + common.VERSION + common.VERSION_NAME + ", type :help for help")
+
+	scanner := bufio.NewScanner(input)
+	for {
+		text, ok := s.read(scanner)
+		if !ok {
+			fmt.Println()
+			return
+		}
+
+		switch strings.TrimSpace(text) {
+		case "":
+		case ":quit", ":q":
+			return
+		case ":help", ":h":
+			fmt.Println(replHelp)
+		case ":reset":
+			s.reset()
+		case ":botlang":
+			s.showCode = !s.showCode
+		default:
+			s.evaluate([]byte(text + "\n"))
+		}
+	}
+}
+
+// read returns the next input, a block is read until an empty line
+func (s *session) read(scanner *bufio.Scanner) (string, bool) {
+	fmt.Print(">>> ")
+	if !scanner.Scan() {
+		return "", false
+	}
+
+	lines := []string{scanner.Text()}
+	if !opensBlock(lines[0]) {
+		return lines[0], true
+	}
+
+	for {
+		fmt.Print("... ")
+		if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+			return strings.Join(lines, "\n"), true
+		}
+		lines = append(lines, scanner.Text())
+	}
+}
+
+func opensBlock(line string) bool {
+	code, _, _ := strings.Cut(line, "#")
+	return strings.HasSuffix(strings.TrimSpace(code), ":")
+}
+
+func (s *session) evaluate(input []byte) {
+	l := lexer.New(input)
+	p := parser.New(&l)
+	program := p.Parse()
+	if s.printErrors(p.Errors(), input) {
+		return
+	}
+
+	compiled := len(s.compiler.Instructions())
+	t, register, errs := s.compiler.Extend(input)
+	if s.printErrors(errs, input) {
+		s.rebuild()
+		return
+	}
+
+	code := s.compiler.Instructions()
+	loaded, err := vm.Load(code)
+	if err != nil {
+		fmt.Println(err)
+		s.rebuild()
+		return
+	}
+	s.history = append(s.history, input)
+
+	if s.showCode {
+		fmt.Print(string(botlang.Format(code[compiled:])))
+	}
+
+	s.machine.Reload(loaded)
+	if !s.run() {
+		return
+	}
+
+	if register != "" && t != compiler.VOID.String() {
+		fmt.Printf("%s = %s\n", t, formatValue(s.machine, s.compiler.Symbols(), t, s.machine.Register(register)))
+		return
+	}
+
+	if len(program.Statements) != 0 {
+		s.describe(program.Statements[len(program.Statements)-1])
+	}
+}
+
+// run executes the code of the last input, the rest of the input is skipped if it fails or takes too long
+func (s *session) run() bool {
+	err := s.machine.Run(s.machine.Steps() + s.steps)
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, vm.ErrStepLimit) {
+		fmt.Printf("stopped after %d steps\n", s.steps)
+	} else {
+		fmt.Println(err)
+	}
+	s.machine.SetPC(len(s.machine.Program().Instructions))
+	return false
+}
+
+// describe prints the variable or the function declared by the statement
+func (s *session) describe(statement ast.Statement) {
+	var name string
+	switch stm := statement.(type) {
+	case *ast.DeclarationStatement:
+		name = stm.Var.Name
+	case *ast.AssignmentStatement:
+		name = stm.Name.Value
+	case *ast.FunctionStatement:
+		references := s.compiler.References()
+		for i := len(references) - 1; i >= 0; i-- {
+			definition := references[i].Definition
+			if definition.Kind == compiler.FUNCTION_DEFINITION && definition.Name == stm.Var.Name {
+				fmt.Println(definition.Signature)
+				return
+			}
+		}
+		return
+	default:
+		return
+	}
+
+	symbols := s.compiler.Symbols()
+	for i := len(symbols) - 1; i >= 0; i-- {
+		symbol := symbols[i]
+		if symbol.Name != name || symbol.Scope != "" {
+			continue
+		}
+
+		value, _ := s.machine.Memory(symbol.Addr)
+		fmt.Printf("%s %s = %s\n", symbol.Type, symbol.Name, formatValue(s.machine, symbols, symbol.Type, value))
+		return
+	}
+}
+
+// rebuild compiles the accepted inputs again, the failed input might have left a half of its declarations.
+// The code is the same as before, so the state of the virtual machine is still valid
+func (s *session) rebuild() {
+	s.compiler = compiler.New(s.stackSize)
+	for _, input := range s.history {
+		s.compiler.Extend(input)
+	}
+}
+
+func (s *session) printErrors(errs []helper.Error, input []byte) bool {
+	for _, err := range errs {
+		err.File = "repl"
+		helper.PrintError(err, input)
+	}
+	return len(errs) != 0
+}
@@ -0,0 +1,119 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/compiler"
+	"NiLang/src/diagnostic"
+	"NiLang/src/vm"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func run(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	stackSize := flags.Int("s", compiler.AUTO_STACK_SIZE, "stack size in bytes, measured from the program if 0")
+	memorySize := flags.Int("m", vm.DefaultMemorySize, "memory size of the virtual machine")
+	optimization := flags.Int("O", compiler.DEFAULT_OPTIMIZATION, "optimization level, 0 keeps code as emitted, 1 removes redundant commands, 2 also keeps operands in registers")
+	frames := flags.Int("frames", compiler.DEFAULT_FRAMES, "calls of a recursive function which may run at once, each copies the memory of the function, 0 makes recursion an error")
+	steps := flags.Int("steps", 10000, "maximum number of instructions to execute")
+	energy := flags.Int("energy", 1000, "energy level reported to the bot")
+	trace := flags.Bool("trace", false, "print every executed instruction")
+	directories := domainFlags(flags)
+	report := diagnosticsFlag(flags)
+	files := parseFlags(flags, args)
+
+	if len(files) != 1 {
+		log.Fatal("Expected argument with path to .nil or .tor file to run")
+	}
+
+	program, ok := loadProgram(files[0], directories(files[0]), *stackSize, *memorySize, *optimization, *frames, report())
+	if !ok {
+		os.Exit(1)
+	}
+
+	world := &consoleWorld{energy: *energy}
+	machine := vm.New(program, world, *memorySize)
+
+	for !machine.Halted() && machine.Steps() < *steps {
+		pc := machine.PC()
+		instruction, err := machine.Step()
+		if *trace {
+			fmt.Printf("%6d: %s\n", pc, instruction)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	printState(machine, world)
+}
+
+// loadProgram compiles .nil file or reads already compiled .tor file
+func loadProgram(fileName string, directories []string, stackSize int, memorySize int, optimization int, frames int, report *diagnostic.Report) (*vm.Program, bool) {
+	var code []byte
+	if filepath.Ext(fileName) == ".tor" {
+		code = readFile(fileName)
+	} else {
+		var ok bool
+		_, code, ok = compileFile(fileName, directories, stackSize, memorySize, optimization, frames, false, report)
+		if !ok {
+			return nil, false
+		}
+	}
+
+	program, err := vm.Parse(code)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return program, true
+}
+
+func printState(machine *vm.VM, world *consoleWorld) {
+	if machine.Halted() {
+		fmt.Printf("finished after %d steps\n", machine.Steps())
+	} else {
+		fmt.Printf("stopped after %d steps\n", machine.Steps())
+	}
+	fmt.Printf("cycles: %d\n", world.age)
+	for _, register := range []string{botlang.AX, botlang.BX, botlang.CX, botlang.DX, botlang.SD, botlang.MD} {
+		fmt.Printf("%s=%d ", register, machine.Register(register))
+	}
+	fmt.Println()
+}
+
+// consoleWorld prints actions of the bot and answers that all cells around it are empty
+type consoleWorld struct {
+	energy int
+	age    int
+}
+
+func (w *consoleWorld) act(format string, args ...any) {
+	w.age++
+	fmt.Printf("[cycle %d] %s\n", w.age, fmt.Sprintf(format, args...))
+}
+
+func (w *consoleWorld) Move(direction botlang.Direction) { w.act("move %s", direction) }
+func (w *consoleWorld) Face(direction botlang.Direction) { w.act("face %s", direction) }
+func (w *consoleWorld) Bite(direction botlang.Direction) { w.act("bite %s", direction) }
+func (w *consoleWorld) ConsumeSunlight()                 { w.act("consume sunlight") }
+func (w *consoleWorld) AbsorbMinerals()                  { w.act("absorb minerals") }
+func (w *consoleWorld) Sleep()                           { w.act("sleep") }
+func (w *consoleWorld) Energy() int                      { return w.energy }
+func (w *consoleWorld) Age() int                         { return w.age }
+
+func (w *consoleWorld) Split(direction botlang.Direction, entry int) {
+	w.act("split %s", direction)
+}
+
+func (w *consoleWorld) Fork(direction botlang.Direction, entry int) {
+	w.act("fork %s", direction)
+}
+
+func (w *consoleWorld) Check(direction botlang.Direction) vm.Cell {
+	return vm.Cell{Empty: true}
+}
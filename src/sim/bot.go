@@ -0,0 +1,152 @@
+package sim
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/vm"
+)
+
+// offsets of the neighbouring cells listed clockwise starting from the north
+var offsets = [botlang.DIRECTIONS]struct{ x, y int }{
+	{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1},
+}
+
+// bot is the world for its own virtual machine
+type bot struct {
+	simulation *Simulation
+	machine    *vm.VM
+
+	x      int
+	y      int
+	facing int // absolute direction, index in offsets
+
+	energy  int
+	age     int
+	colony  int
+	species int
+	alive   bool
+}
+
+func (b *bot) target(direction botlang.Direction) (int, int, int) {
+	absolute := (b.facing + int(direction)) % int(botlang.DIRECTIONS)
+	return b.x + offsets[absolute].x, b.y + offsets[absolute].y, absolute
+}
+
+func (b *bot) neighbour(direction botlang.Direction) (*cell, bool) {
+	x, y, _ := b.target(direction)
+	if !b.simulation.contains(x, y) {
+		return nil, false
+	}
+	return b.simulation.cell(x, y), true
+}
+
+func (b *bot) gain(energy int) {
+	b.energy = min(b.energy+energy, MAX_ENERGY)
+}
+
+func (b *bot) Move(direction botlang.Direction) {
+	x, y, _ := b.target(direction)
+	b.energy -= MOVE_COST
+	if !b.simulation.contains(x, y) || b.simulation.cell(x, y).bot != nil {
+		return
+	}
+
+	b.simulation.cell(b.x, b.y).bot = nil
+	b.x, b.y = x, y
+	b.simulation.cell(x, y).bot = b
+}
+
+func (b *bot) Face(direction botlang.Direction) {
+	_, _, b.facing = b.target(direction)
+}
+
+func (b *bot) Check(direction botlang.Direction) vm.Cell {
+	c, ok := b.neighbour(direction)
+	if !ok {
+		// the edge of the world is a wall
+		return vm.Cell{}
+	}
+
+	current := b.simulation.cell(b.x, b.y)
+	result := vm.Cell{
+		Empty:          c.bot == nil,
+		Luminosity:     c.luminosity - current.luminosity,
+		Mineralization: c.mineralization - current.mineralization,
+	}
+	if c.bot != nil {
+		result.Friend = c.bot.colony == b.colony
+		result.Sibling = c.bot.species == b.species
+	}
+	return result
+}
+
+func (b *bot) Bite(direction botlang.Direction) {
+	c, ok := b.neighbour(direction)
+	if !ok || c.bot == nil {
+		return
+	}
+
+	victim := c.bot
+	stolen := min(BITE_AMOUNT, victim.energy)
+	victim.energy -= stolen
+	b.gain(stolen)
+
+	if victim.energy <= 0 {
+		b.simulation.kill(victim)
+	}
+}
+
+func (b *bot) Split(direction botlang.Direction, entry int) {
+	b.reproduce(direction, entry)
+}
+
+// Fork is similar to Split, but the child starts a new colony and might mutate into a new specie
+func (b *bot) Fork(direction botlang.Direction, entry int) {
+	child := b.reproduce(direction, entry)
+	if child == nil {
+		return
+	}
+
+	s := b.simulation
+	s.lastColony++
+	child.colony = s.lastColony
+	if s.random.Float64() < MUTATION_CHANCE {
+		s.lastSpecies++
+		child.species = s.lastSpecies
+	}
+}
+
+func (b *bot) reproduce(direction botlang.Direction, entry int) *bot {
+	x, y, _ := b.target(direction)
+	s := b.simulation
+	if b.energy < SPLIT_ENERGY || !s.contains(x, y) || s.cell(x, y).bot != nil {
+		return nil
+	}
+
+	energy := b.energy / 2
+	b.energy -= energy
+
+	child := s.newBot(x, y, b.facing, energy, b.colony, b.species)
+	child.machine.SetPC(entry)
+	child.machine.SetRegister(botlang.CX, b.machine.Register(botlang.CX))
+	child.machine.SetRegister(botlang.DX, b.machine.Register(botlang.DX))
+	s.births++
+	return child
+}
+
+func (b *bot) ConsumeSunlight() {
+	b.gain(b.simulation.cell(b.x, b.y).luminosity)
+}
+
+func (b *bot) AbsorbMinerals() {
+	b.gain(b.simulation.cell(b.x, b.y).mineralization)
+}
+
+func (b *bot) Sleep() {}
+
+func (b *bot) Energy() int {
+	return b.energy
+}
+
+func (b *bot) Age() int {
+	return b.age
+}
@@ -0,0 +1,222 @@
+package sim
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/vm"
+	"math/rand"
+)
+
+const (
+	MAX_LUMINOSITY     = 10
+	MAX_MINERALIZATION = 10
+
+	MAX_ENERGY = 1000
+	MAX_AGE    = 2000
+
+	CYCLE_COST  = 1 // energy spent by a bot each cycle just to stay alive
+	MOVE_COST   = 1
+	BITE_AMOUNT = 20 // the most energy stolen by a single bite
+
+	SPLIT_ENERGY    = 20 // a bot needs at least this much energy to split or fork
+	MUTATION_CHANCE = 0.1
+)
+
+type Config struct {
+	Width  int
+	Height int
+	Seed   int64
+
+	Bots       int // number of bots at the start
+	Energy     int // initial energy of each bot
+	MemorySize int
+	CycleLimit int // maximum number of instructions a bot executes per cycle
+}
+
+var DefaultConfig = Config{
+	Width:      64,
+	Height:     32,
+	Seed:       1,
+	Bots:       1,
+	Energy:     100,
+	MemorySize: vm.DefaultMemorySize,
+	CycleLimit: 64,
+}
+
+type Statistics struct {
+	Step        int
+	Population  int
+	Colonies    int
+	Species     int
+	TotalEnergy int
+	MaxEnergy   int
+	Births      int // over the whole simulation
+	Deaths      int // over the whole simulation
+	Errors      int // bots, which died of a runtime error
+}
+
+func (s Statistics) AverageEnergy() int {
+	if s.Population == 0 {
+		return 0
+	}
+	return s.TotalEnergy / s.Population
+}
+
+type cell struct {
+	luminosity     int
+	mineralization int
+	bot            *bot
+}
+
+type Simulation struct {
+	config  Config
+	program *vm.Program
+	random  *rand.Rand
+
+	cells []cell
+	bots  []*bot
+
+	step        int
+	births      int
+	deaths      int
+	errors      int
+	lastColony  int
+	lastSpecies int
+}
+
+// New creates a world, where luminosity fades and mineralization grows with depth,
+// and places the initial bots at random cells
+func New(program *vm.Program, config Config) *Simulation {
+	s := &Simulation{
+		config:  config,
+		program: program,
+		random:  rand.New(rand.NewSource(config.Seed)),
+		cells:   make([]cell, config.Width*config.Height),
+		bots:    make([]*bot, 0),
+	}
+
+	for y := 0; y < config.Height; y++ {
+		for x := 0; x < config.Width; x++ {
+			c := s.cell(x, y)
+			c.luminosity = clamp(MAX_LUMINOSITY*(config.Height-y)/config.Height+s.random.Intn(3)-1, 0, MAX_LUMINOSITY)
+			c.mineralization = clamp(MAX_MINERALIZATION*y/config.Height+s.random.Intn(3)-1, 0, MAX_MINERALIZATION)
+		}
+	}
+
+	for i := 0; i < config.Bots && i < len(s.cells); i++ {
+		x, y := s.random.Intn(config.Width), s.random.Intn(config.Height)
+		for s.cell(x, y).bot != nil {
+			x, y = s.random.Intn(config.Width), s.random.Intn(config.Height)
+		}
+
+		s.lastColony++
+		s.lastSpecies++
+		s.newBot(x, y, s.random.Intn(int(botlang.DIRECTIONS)), config.Energy, s.lastColony, s.lastSpecies)
+	}
+
+	return s
+}
+
+// Step gives every living bot one cycle, bots born during the step act starting from the next one
+func (s *Simulation) Step() {
+	s.step++
+
+	bots := s.bots
+	for _, b := range bots {
+		if !b.alive {
+			continue
+		}
+
+		if b.machine.Halted() {
+			b.machine.SetPC(0)
+		}
+
+		if err := b.machine.Cycle(s.config.CycleLimit); err != nil {
+			s.errors++
+			s.kill(b)
+			continue
+		}
+
+		b.age++
+		b.energy -= CYCLE_COST
+		if b.energy <= 0 || b.age >= MAX_AGE {
+			s.kill(b)
+		}
+	}
+
+	alive := make([]*bot, 0, len(s.bots))
+	for _, b := range s.bots {
+		if b.alive {
+			alive = append(alive, b)
+		}
+	}
+	s.bots = alive
+}
+
+func (s *Simulation) Run(steps int) {
+	for i := 0; i < steps; i++ {
+		s.Step()
+	}
+}
+
+func (s *Simulation) Population() int {
+	return len(s.bots)
+}
+
+func (s *Simulation) Statistics() Statistics {
+	stats := Statistics{
+		Step:       s.step,
+		Population: len(s.bots),
+		Births:     s.births,
+		Deaths:     s.deaths,
+		Errors:     s.errors,
+	}
+
+	colonies := make(map[int]bool)
+	species := make(map[int]bool)
+	for _, b := range s.bots {
+		stats.TotalEnergy += b.energy
+		stats.MaxEnergy = max(stats.MaxEnergy, b.energy)
+		colonies[b.colony] = true
+		species[b.species] = true
+	}
+	stats.Colonies = len(colonies)
+	stats.Species = len(species)
+
+	return stats
+}
+
+func (s *Simulation) newBot(x int, y int, facing int, energy int, colony int, species int) *bot {
+	b := &bot{
+		simulation: s,
+		x:          x,
+		y:          y,
+		facing:     facing,
+		energy:     energy,
+		colony:     colony,
+		species:    species,
+		alive:      true,
+	}
+	b.machine = vm.New(s.program, b, s.config.MemorySize)
+
+	s.cell(x, y).bot = b
+	s.bots = append(s.bots, b)
+	return b
+}
+
+func (s *Simulation) kill(b *bot) {
+	b.alive = false
+	s.cell(b.x, b.y).bot = nil
+	s.deaths++
+}
+
+func (s *Simulation) cell(x int, y int) *cell {
+	return &s.cells[y*s.config.Width+x]
+}
+
+func (s *Simulation) contains(x int, y int) bool {
+	return x >= 0 && x < s.config.Width && y >= 0 && y < s.config.Height
+}
+
+func clamp(value int, low int, high int) int {
+	return max(low, min(value, high))
+}
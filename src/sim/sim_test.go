@@ -0,0 +1,167 @@
+package sim_test
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/sim"
+	"NiLang/src/vm"
+	"testing"
+)
+
+const stackSize = 128
+
+func compile(test *testing.T, input string) *vm.Program {
+	c := compiler.New(stackSize)
+	code, errors := c.Compile([]byte(input))
+	if len(errors) != 0 {
+		for _, err := range errors {
+			helper.PrintError(err, []byte(input))
+		}
+		test.Fatalf("Failed to compile code")
+	}
+
+	program, err := vm.Parse(code)
+	if err != nil {
+		test.Fatalf("vm.Parse() has failed: %s", err)
+	}
+	return program
+}
+
+const splitter = `
+While True:
+    If bot::GetEnergy < 60:
+        bot::ConsumeSunlight
+    Elif bot::IsEmpty$ dir::front:
+        bot::Split$ dir::front
+    Else:
+        bot::Face$ dir::right
+`
+
+func TestDeterminism(test *testing.T) {
+	program := compile(test, splitter)
+
+	config := sim.DefaultConfig
+	config.Bots = 5
+	config.Seed = 42
+
+	first := sim.New(program, config)
+	second := sim.New(program, config)
+	for i := 0; i < 300; i++ {
+		first.Step()
+		second.Step()
+
+		if first.Statistics() != second.Statistics() {
+			test.Fatalf("step %d: simulations with the same seed diverged: %+v and %+v", i, first.Statistics(), second.Statistics())
+		}
+	}
+}
+
+func TestReproduction(test *testing.T) {
+	program := compile(test, splitter)
+
+	s := sim.New(program, sim.DefaultConfig)
+	s.Run(200)
+
+	stats := s.Statistics()
+	if stats.Population <= 1 || stats.Births == 0 {
+		test.Fatalf("expected population to grow, got %+v", stats)
+	}
+	if stats.Colonies != 1 || stats.Species != 1 {
+		test.Fatalf("expected children of split to stay in the same colony, got %+v", stats)
+	}
+}
+
+func TestFork(test *testing.T) {
+	program := compile(test, `
+While True:
+    If bot::GetEnergy < 60:
+        bot::ConsumeSunlight
+    Elif bot::IsEmpty$ dir::front:
+        bot::Fork$ dir::front
+    Else:
+        bot::Face$ dir::right
+`)
+
+	s := sim.New(program, sim.DefaultConfig)
+	s.Run(200)
+
+	if stats := s.Statistics(); stats.Colonies <= 1 {
+		test.Fatalf("expected children of fork to create new colonies, got %+v", stats)
+	}
+}
+
+func TestStarvation(test *testing.T) {
+	program := compile(test, `
+While True:
+    bot::Sleep
+`)
+
+	config := sim.DefaultConfig
+	config.Energy = 10
+
+	s := sim.New(program, config)
+	s.Run(config.Energy - 1)
+	if s.Population() != 1 {
+		test.Fatalf("bot has died too early")
+	}
+
+	s.Step()
+	if stats := s.Statistics(); stats.Population != 0 || stats.Deaths != 1 {
+		test.Fatalf("expected bot to starve, got %+v", stats)
+	}
+}
+
+func TestEnergySources(test *testing.T) {
+	tests := []string{"bot::ConsumeSunlight", "bot::AbsorbMinerals"}
+
+	for i, t := range tests {
+		program := compile(test, "While True:\n    "+t+"\n")
+
+		config := sim.DefaultConfig
+		config.Bots = 50
+
+		sleepers := sim.New(compile(test, "While True:\n    bot::Sleep\n"), config)
+		eaters := sim.New(program, config)
+		sleepers.Run(50)
+		eaters.Run(50)
+
+		if eaters.Statistics().TotalEnergy <= sleepers.Statistics().TotalEnergy {
+			test.Errorf("tests[%d] - expected %s to increase energy, got %+v", i, t, eaters.Statistics())
+		}
+	}
+}
+
+func TestBite(test *testing.T) {
+	program := compile(test, `
+While True:
+    bot::Bite$ dir::front
+    bot::Face$ dir::frontRight
+`)
+
+	config := sim.DefaultConfig
+	config.Width = 4
+	config.Height = 4
+	config.Bots = 16
+
+	s := sim.New(program, config)
+	s.Run(config.Energy / 2)
+
+	stats := s.Statistics()
+	if stats.Deaths == 0 || stats.MaxEnergy <= config.Energy/2 {
+		test.Fatalf("expected bots to bite each other to death, got %+v", stats)
+	}
+}
+
+func TestRuntimeError(test *testing.T) {
+	program := compile(test, `
+Int x = 0
+bot::WriteMemory$ 1 / x
+`)
+
+	s := sim.New(program, sim.DefaultConfig)
+	s.Step()
+
+	if stats := s.Statistics(); stats.Population != 0 || stats.Errors != 1 {
+		test.Fatalf("expected bot to die of a runtime error, got %+v", stats)
+	}
+}
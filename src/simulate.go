@@ -0,0 +1,72 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/sim"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func simulate(args []string) {
+	config := sim.DefaultConfig
+
+	flags := flag.NewFlagSet("sim", flag.ExitOnError)
+	stackSize := flags.Int("s", compiler.AUTO_STACK_SIZE, "stack size in bytes, measured from the program if 0")
+	optimization := flags.Int("O", compiler.DEFAULT_OPTIMIZATION, "optimization level, 0 keeps code as emitted, 1 removes redundant commands, 2 also keeps operands in registers")
+	frames := flags.Int("frames", compiler.DEFAULT_FRAMES, "calls of a recursive function which may run at once, each copies the memory of the function, 0 makes recursion an error")
+	steps := flags.Int("steps", 1000, "number of world cycles to simulate")
+	report := flags.Int("report", 100, "print statistics every given number of cycles, 0 prints only the final ones")
+	flags.Int64Var(&config.Seed, "seed", config.Seed, "seed of the world generator")
+	flags.IntVar(&config.Width, "width", config.Width, "width of the world in cells")
+	flags.IntVar(&config.Height, "height", config.Height, "height of the world in cells")
+	flags.IntVar(&config.Bots, "bots", config.Bots, "number of bots at the start")
+	flags.IntVar(&config.Energy, "energy", config.Energy, "initial energy of each bot")
+	flags.IntVar(&config.MemorySize, "m", config.MemorySize, "memory size of each bot")
+	flags.IntVar(&config.CycleLimit, "cycle-limit", config.CycleLimit, "maximum number of instructions a bot executes per cycle")
+	directories := domainFlags(flags)
+	diagnostics := diagnosticsFlag(flags)
+	files := parseFlags(flags, args)
+
+	if len(files) != 1 {
+		log.Fatal("Expected argument with path to .nil or .tor file to simulate")
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		log.Fatal("Expected positive width and height of the world")
+	}
+
+	program, ok := loadProgram(files[0], directories(files[0]), *stackSize, config.MemorySize, *optimization, *frames, diagnostics())
+	if !ok {
+		os.Exit(1)
+	}
+
+	world := sim.New(program, config)
+
+	fmt.Printf("%8s %10s %8s %8s %12s %10s %10s %8s %8s\n",
+		"step", "population", "colonies", "species", "total energy", "avg energy", "max energy", "births", "deaths")
+	for i := 1; i <= *steps; i++ {
+		world.Step()
+		extinct := world.Population() == 0
+		if (*report > 0 && i%*report == 0) || i == *steps || extinct {
+			printStatistics(world.Statistics())
+		}
+		if extinct {
+			fmt.Println("population has died out")
+			break
+		}
+	}
+
+	if errors := world.Statistics().Errors; errors != 0 {
+		fmt.Printf("%d bot(s) died of a runtime error\n", errors)
+	}
+}
+
+func printStatistics(stats sim.Statistics) {
+	fmt.Printf("%8d %10d %8d %8d %12d %10d %10d %8d %8d\n",
+		stats.Step, stats.Population, stats.Colonies, stats.Species,
+		stats.TotalEnergy, stats.AverageEnergy(), stats.MaxEnergy, stats.Births, stats.Deaths)
+}
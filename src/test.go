@@ -0,0 +1,112 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/test"
+	"NiLang/src/vm"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// testFiles runs tests of *_test.nil files, a directory stands for its test files and dir/... for the files
+// of the directory and its subdirectories
+func testFiles(args []string) {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	stackSize := flags.Int("s", compiler.AUTO_STACK_SIZE, "stack size in bytes, measured from the program if 0")
+	memorySize := flags.Int("m", vm.DefaultMemorySize, "memory size of the virtual machine")
+	optimization := flags.Int("O", compiler.DEFAULT_OPTIMIZATION, "optimization level, 0 keeps code as emitted, 1 removes redundant commands, 2 also keeps operands in registers")
+	frames := flags.Int("frames", compiler.DEFAULT_FRAMES, "calls of a recursive function which may run at once, each copies the memory of the function, 0 makes recursion an error")
+	steps := flags.Int("steps", 100000, "maximum number of instructions executed by one test")
+	energy := flags.Int("energy", 1000, "energy level reported to the bot")
+	sensors := flags.String("sensors", "", "file with answers of sensors, one checked cell per line, the same for every test")
+	run := flags.String("run", "", "run only tests whose names match the regular expression")
+	verbose := flags.Bool("v", false, "print passed tests as well")
+	directories := domainFlags(flags)
+	patterns := parseFlags(flags, args)
+
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	options := test.Options{StackSize: *stackSize, MemorySize: *memorySize, Optimization: *optimization, Frames: *frames, Steps: *steps, Energy: *energy}
+	if *sensors != "" {
+		for _, answer := range readSensors(*sensors) {
+			options.Sensors = append(options.Sensors, answer.cell)
+		}
+	}
+	if *run != "" {
+		match, err := regexp.Compile(*run)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Match = match.MatchString
+	}
+
+	fileNames := findFiles(patterns, func(path string) bool { return strings.HasSuffix(path, test.SUFFIX) })
+	if len(fileNames) == 0 {
+		log.Fatal("No test files found")
+	}
+
+	failed := false
+	for _, fileName := range fileNames {
+		if !testFile(fileName, options, directories(fileName), *verbose) {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// testFile prints results of tests of the file and a summary line, it returns false if any of them has failed
+func testFile(fileName string, options test.Options, directories []string, verbose bool) bool {
+	input := readFile(fileName)
+	name := absolute(fileName)
+
+	options.Directories = directories
+	results, errors := test.Run(name, input, options)
+	if len(errors) != 0 {
+		for _, err := range errors {
+			source := input
+			if err.File != name {
+				source, _ = os.ReadFile(err.File)
+			}
+			helper.PrintError(err, source)
+		}
+		fmt.Printf("FAIL\t%s\t[build failed]\n", fileName)
+		return false
+	}
+
+	passed := true
+	for _, result := range results {
+		if result.Passed() {
+			if verbose {
+				fmt.Printf("--- PASS: %s (%d steps)\n", result.Name, result.Steps)
+			}
+			continue
+		}
+
+		passed = false
+		failure := *result.Failure
+		fmt.Printf("--- FAIL: %s (%d steps)\n", result.Name, result.Steps)
+		fmt.Printf("    %s:%d:%d: %s\n", relative(failure.File), failure.Line, failure.Offset, failure.Description)
+	}
+
+	switch {
+	case !passed:
+		fmt.Printf("FAIL\t%s\n", fileName)
+	case len(results) == 0:
+		fmt.Printf("ok\t%s\t[no tests to run]\n", fileName)
+	default:
+		fmt.Printf("ok\t%s\t%d passed\n", fileName, len(results))
+	}
+	return passed
+}
@@ -0,0 +1,213 @@
+package test
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/botlang"
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/lexer"
+	"NiLang/src/parser"
+	"NiLang/src/vm"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SUFFIX ends names of files with tests, e.g. math_test.nil
+const SUFFIX = "_test" + compiler.EXTENSION
+
+// PREFIX begins names of test functions, e.g. TestDouble
+const PREFIX = "Test"
+
+type Options struct {
+	StackSize    int
+	MemorySize   int
+	Optimization int // level of compiler.Optimize
+	Frames       int // of compiler.LimitFrames, recursion is an error if zero
+	Steps        int // limit of instructions executed by one test including the top level code of the file
+	Energy       int
+	Sensors      []vm.Cell              // answers of checks of cells in their order, cells are empty once they are over
+	Directories  []string               // where files of domains are looked for
+	Match        func(name string) bool // selects tests to run, all of them are run if it is nil
+}
+
+// Result of one test function, it has passed if there is no failure
+type Result struct {
+	Name    string
+	Line    int // position of the name of the function
+	Offset  int
+	Steps   int
+	Failure *helper.Error
+}
+
+func (r Result) Passed() bool {
+	return r.Failure == nil
+}
+
+type testFunction struct {
+	statement *ast.FunctionStatement
+	path      string // name of the function in the domain of the file
+}
+
+// Run compiles the file with the Assert builtin and runs its test functions one by one. Every test gets a new virtual
+// machine which executes the top level code of the file and then calls the function. Errors of compilation are returned
+// instead of results
+func Run(name string, input []byte, options Options) ([]Result, []helper.Error) {
+	c := compiler.New(options.StackSize)
+	c.EnableAssertions()
+	c.LimitMemory(options.MemorySize)
+	c.Optimize(options.Optimization)
+	c.LimitFrames(options.Frames)
+	code, errs := c.CompileFiles(name, input, options.Directories)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	failures := c.AssertionAddress()
+
+	compiled, err := vm.Parse(code)
+	if err != nil {
+		return nil, []helper.Error{{Line: 1, Offset: 0, Description: err.Error(), File: name}}
+	}
+
+	r := &runner{name: name, options: options, compiler: c, program: compiled, failures: failures}
+	r.overflow, r.recursive = c.FrameOverflowAddress(), c.RecursiveFunctions()
+	r.locations = make(map[int]compiler.Mapping)
+	for _, mapping := range c.SourceMap(name).Mappings {
+		r.locations[mapping.Instruction] = mapping
+	}
+
+	// the syntax is correct once the file has been compiled
+	l := lexer.New(input)
+	program := parser.New(&l).Parse()
+
+	results := make([]Result, 0)
+	for _, test := range tests(program) {
+		if options.Match == nil || options.Match(test.statement.Var.Name) {
+			results = append(results, r.run(test))
+		}
+	}
+	return results, nil
+}
+
+// tests finds functions of the file whose names begin with PREFIX
+func tests(program *ast.Program) []testFunction {
+	domain := ""
+	result := make([]testFunction, 0)
+
+	for _, statement := range program.Statements {
+		switch s := statement.(type) {
+		case *ast.DomainStatement:
+			domain = s.Name.String() + "::"
+		case *ast.FunctionStatement:
+			if strings.HasPrefix(s.Var.Name, PREFIX) {
+				result = append(result, testFunction{statement: s, path: domain + s.Var.Name})
+			}
+		}
+	}
+	return result
+}
+
+type runner struct {
+	name      string
+	options   Options
+	compiler  *compiler.Compiler
+	program   *vm.Program
+	locations map[int]compiler.Mapping // source of instructions by their indexes
+	failures  int                      // address of the number of the failed assertion
+	overflow  int                      // address of the number of the recursive function whose frames are full
+	recursive []string                 // names of recursive functions by their numbers
+}
+
+func (r *runner) run(test testFunction) Result {
+	fs := test.statement
+	result := Result{Name: fs.Var.Name, Line: fs.Var.Token.Line, Offset: fs.Var.Token.Offset}
+
+	fail := func(line int, offset int, description string) Result {
+		result.Failure = &helper.Error{Line: line, Offset: offset, Description: description, File: r.name}
+		return result
+	}
+
+	if len(fs.Parameters) != 0 || fs.Var.Type != nil {
+		return fail(result.Line, result.Offset, "test function must have no parameters and no return type")
+	}
+
+	label, ok := r.compiler.Label(test.path)
+	if !ok {
+		return fail(result.Line, result.Offset, fmt.Sprintf("function %q is not found in the compiled code", test.path))
+	}
+
+	machine := vm.New(r.program, &world{energy: r.options.Energy, sensors: r.options.Sensors}, r.options.MemorySize)
+
+	// the top level code declares global variables, the test is called once it is over
+	err := machine.Run(r.options.Steps)
+	if err == nil && !r.failed(machine) && r.overflowed(machine) == "" {
+		machine.Call(r.program.Labels[label])
+		err = machine.Run(r.options.Steps)
+	}
+	result.Steps = machine.Steps()
+
+	switch {
+	case r.failed(machine):
+		number, _ := machine.Memory(r.failures)
+		assertion := r.compiler.Assertions()[number-1]
+		result.Failure = &helper.Error{Line: assertion.Line, Offset: assertion.Offset, Description: "assertion failed", File: assertion.File}
+	case r.overflowed(machine) != "":
+		return fail(result.Line, result.Offset, fmt.Sprintf("recursion of %q needs more than %d frames", r.overflowed(machine), r.options.Frames))
+	case errors.Is(err, vm.ErrStepLimit):
+		return fail(result.Line, result.Offset, fmt.Sprintf("test has not finished in %d steps", r.options.Steps))
+	case err != nil:
+		// the failed instruction has been counted already
+		mapping, ok := r.locations[machine.PC()-1]
+		if !ok {
+			return fail(result.Line, result.Offset, err.Error())
+		}
+		result.Failure = &helper.Error{Line: mapping.Line, Offset: mapping.Column, Description: err.Error(), File: mapping.File}
+	}
+	return result
+}
+
+func (r *runner) failed(machine *vm.VM) bool {
+	number, _ := machine.Memory(r.failures)
+	return number != 0
+}
+
+// overflowed returns the name of the recursive function which has stopped the bot needing one more frame
+func (r *runner) overflowed(machine *vm.VM) string {
+	if r.overflow == 0 {
+		return ""
+	}
+	number, _ := machine.Memory(r.overflow)
+	if number == 0 {
+		return ""
+	}
+	return r.recursive[number-1]
+}
+
+// world is the same for every run of a test: the bot does nothing visible, its energy doesn't change
+// and checked cells are taken from the sensors in their order
+type world struct {
+	energy  int
+	age     int
+	sensors []vm.Cell
+	checks  int
+}
+
+func (w *world) Move(direction botlang.Direction)             { w.age++ }
+func (w *world) Face(direction botlang.Direction)             { w.age++ }
+func (w *world) Bite(direction botlang.Direction)             { w.age++ }
+func (w *world) Split(direction botlang.Direction, entry int) { w.age++ }
+func (w *world) Fork(direction botlang.Direction, entry int)  { w.age++ }
+func (w *world) ConsumeSunlight()                             { w.age++ }
+func (w *world) AbsorbMinerals()                              { w.age++ }
+func (w *world) Sleep()                                       { w.age++ }
+func (w *world) Energy() int                                  { return w.energy }
+func (w *world) Age() int                                     { return w.age }
+
+func (w *world) Check(direction botlang.Direction) vm.Cell {
+	cell := vm.Cell{Empty: true}
+	if w.checks < len(w.sensors) {
+		cell = w.sensors[w.checks]
+	}
+	w.checks++
+	return cell
+}
@@ -0,0 +1,152 @@
+package test_test
+
+import (
+	"NiLang/src/compiler"
+	"NiLang/src/test"
+	"NiLang/src/vm"
+	"testing"
+)
+
+const input = `Int limit = 10
+
+Fun Clamp::Int$ x Int:
+    If x > limit:
+        Return limit
+    Return x
+
+Fun TestClamp:
+    Int clamped = Clamp$ 20
+    Assert$ clamped == limit
+
+Fun TestFails:
+    Assert$ limit == 10
+    Assert$ limit == 11
+
+Fun TestDivision:
+    Int zero = 0
+    Int x = limit / zero
+
+Fun TestEndless:
+    While True:
+        bot::Sleep
+
+Fun TestSensors:
+    Bool empty = bot::IsEmpty$ dir::front
+    Assert$ empty == False
+    Int luminosity = bot::GetLuminosity$ dir::left
+    Assert$ luminosity == 7
+    Assert$ bot::IsEmpty$ dir::right
+    Assert$ bot::GetEnergy == 500
+
+Fun TestArguments$ x Int:
+    Assert$ x == 1
+`
+
+func options() test.Options {
+	return test.Options{
+		StackSize:  128,
+		MemorySize: vm.DefaultMemorySize,
+		Steps:      10000,
+		Energy:     500,
+		Sensors:    []vm.Cell{{Empty: false}, {Empty: true, Luminosity: 7}},
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, level := range []int{compiler.NO_OPTIMIZATION, compiler.DEFAULT_OPTIMIZATION} {
+		o := options()
+		o.Optimization = level
+		checkResults(t, o)
+	}
+}
+
+func checkResults(t *testing.T, o test.Options) {
+	results, errors := test.Run("bot_test.nil", []byte(input), o)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	expected := []struct {
+		name   string
+		passed bool
+		line   int
+		offset int
+	}{
+		{"TestClamp", true, 0, 0},
+		{"TestFails", false, 14, 4},
+		{"TestDivision", false, 18, 18},
+		{"TestEndless", false, 20, 4},
+		{"TestSensors", true, 0, 0},
+		{"TestArguments", false, 32, 4},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, tt := range expected {
+		result := results[i]
+		if result.Name != tt.name || result.Passed() != tt.passed {
+			t.Errorf("-O %d: expected %s passed=%t, got %s passed=%t", o.Optimization, tt.name, tt.passed, result.Name, result.Passed())
+			continue
+		}
+		if tt.passed {
+			continue
+		}
+
+		failure := result.Failure
+		if failure.File != "bot_test.nil" || failure.Line != tt.line || failure.Offset != tt.offset {
+			t.Errorf("-O %d, %s: expected failure at bot_test.nil:%d:%d, got %s:%d:%d: %s", o.Optimization, tt.name, tt.line, tt.offset, failure.File, failure.Line, failure.Offset, failure.Description)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	o := options()
+	o.Match = func(name string) bool { return name == "TestFails" }
+
+	results, _ := test.Run("bot_test.nil", []byte(input), o)
+	if len(results) != 1 || results[0].Name != "TestFails" {
+		t.Fatalf("expected only TestFails to run, got %+v", results)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	results, errors := test.Run("bot_test.nil", []byte("Fun TestX:\n    Assert$ 1\n"), options())
+	if len(errors) == 0 || results != nil {
+		t.Fatalf("expected errors of compilation")
+	}
+	if errors[0].File != "bot_test.nil" || errors[0].Line != 2 {
+		t.Errorf("expected error at bot_test.nil:2, got %s:%d: %s", errors[0].File, errors[0].Line, errors[0].Description)
+	}
+}
+
+func TestFrameOverflow(t *testing.T) {
+	input := `Fun Depth::Int$ n Int:
+    If n == 0:
+        Return 0
+    Int depth = Depth$ n - 1
+    Return depth + 1
+
+Fun TestShallow:
+    Int depth = Depth$ 3
+    Assert$ depth == 3
+
+Fun TestDeep:
+    Int depth = Depth$ 10
+    Assert$ depth == 10
+`
+	o := options()
+	o.Frames = 4
+	results, errors := test.Run("bot_test.nil", []byte(input), o)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	if len(results) != 2 || !results[0].Passed() || results[1].Passed() {
+		t.Fatalf("expected TestShallow to pass and TestDeep to fail, got %+v", results)
+	}
+	failure := results[1].Failure
+	if expected := `recursion of "Depth" needs more than 4 frames`; failure.Line != 11 || failure.Offset != 4 || failure.Description != expected {
+		t.Errorf("expected failure at 11:4: %s, got %d:%d: %s", expected, failure.Line, failure.Offset, failure.Description)
+	}
+}
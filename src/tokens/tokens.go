@@ -14,6 +14,7 @@ const INDENT_LENGTH = 4
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // never returned by the lexer, comments are collected aside
 
 	INDENT  = "INDENTATION"
 	NEWLINE = "NEWLINE"
@@ -24,6 +25,7 @@ const (
 	NUMBER = "NUMBER"
 
 	USING  = "USING"
+	DOMAIN = "DOMAIN"
 	IF     = "IF"
 	ELSE   = "ELSE"
 	ELIF   = "ELIF"
@@ -72,6 +74,7 @@ var keywords = map[string]TokenType{
 	"True":     TRUE,
 	"False":    FALSE,
 	"Using":    USING,
+	"Domain":   DOMAIN,
 	"And":      AND,
 	"Or":       OR,
 	"Not":      NOT,
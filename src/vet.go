@@ -0,0 +1,52 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"NiLang/src/vet"
+	"flag"
+	"log"
+	"os"
+)
+
+// vetFiles reports suspicious code, every check has a flag to switch it off, e.g. -shadow=false or -Wno=NL0104
+func vetFiles(args []string) {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	enabled := make(map[string]*bool)
+	for _, check := range vet.CHECKS {
+		enabled[check.Name] = flags.Bool(check.Name, true, check.Description)
+	}
+	directories := domainFlags(flags)
+	diagnostics := diagnosticsFlag(flags)
+	fileNames := parseFlags(flags, args)
+
+	if len(fileNames) == 0 {
+		log.Fatal("Expected argument with path to code to check")
+	}
+
+	checks := make([]string, 0)
+	for _, check := range vet.CHECKS {
+		if *enabled[check.Name] {
+			checks = append(checks, check.Name)
+		}
+	}
+
+	report := diagnostics()
+	for _, fileName := range fileNames {
+		input := readFile(fileName)
+		name := absolute(fileName)
+
+		warnings, errors := vet.Vet(name, input, directories(fileName), checks)
+		reportErrors(report, errors, name, input)
+		for _, warning := range warnings {
+			report.Add(warning.Error, input)
+		}
+	}
+
+	if err := report.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if report.Count() != 0 {
+		os.Exit(1)
+	}
+}
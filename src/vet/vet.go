@@ -0,0 +1,326 @@
+package vet
+
+import (
+	"NiLang/src/ast"
+	"NiLang/src/common"
+	"NiLang/src/compiler"
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/tokens"
+	"fmt"
+	"sort"
+)
+
+const (
+	UNUSED_VARIABLE = "unusedvar"
+	UNUSED_FUNCTION = "unusedfunc"
+	UNREACHABLE     = "unreachable"
+	SHADOW          = "shadow"
+	UNUSED_RESULT   = "unusedresult"
+	UNUSED_USING    = "unusedusing"
+)
+
+type Check struct {
+	Name        string
+	Code        string // of its warnings
+	Description string
+}
+
+// CHECKS are run by default, each of them can be switched off
+var CHECKS = []Check{
+	{UNUSED_VARIABLE, diagnostic.UNUSED_VARIABLE, "report variables and parameters which are never read"},
+	{UNUSED_FUNCTION, diagnostic.UNUSED_FUNCTION, "report functions and aliases which are never used"},
+	{UNREACHABLE, diagnostic.UNREACHABLE, "report statements after Return, Break or Continue"},
+	{SHADOW, diagnostic.SHADOW, "report declarations hiding a variable, a parameter or a function of an enclosing block"},
+	{UNUSED_RESULT, diagnostic.UNUSED_RESULT, "report calls of functions returning a value which is dropped"},
+	{UNUSED_USING, diagnostic.UNUSED_USING, "report Using statements no name is found through"},
+}
+
+// Warning is a suspicious piece of code which is nevertheless compiled
+type Warning struct {
+	helper.Error
+	Check string
+}
+
+type position struct {
+	line, offset int
+}
+
+func at(token tokens.Token) position {
+	return position{token.Line, token.Offset}
+}
+
+// Vet compiles the file with domains it uses, the same way as the compiler finds them in the directories, and runs
+// the listed checks on the file, errors of the parser or the compiler stop the analysis
+func Vet(name string, input []byte, directories []string, checks []string) ([]Warning, []helper.Error) {
+	c := compiler.New(common.DefaultStackSize)
+	if _, errs := c.CompileFiles(name, input, directories); len(errs) != 0 {
+		return nil, errs
+	}
+	programs := c.Programs()
+	program := programs[len(programs)-1] // the file follows domains it uses
+
+	v := &vetter{
+		file:       name,
+		enabled:    make(map[string]bool),
+		assigned:   make(map[position]bool),
+		parameters: make(map[position]bool),
+		aliases:    make(map[position][]position),
+		constants:  make(map[position]bool),
+		frames:     []map[string]tokens.Token{make(map[string]tokens.Token)},
+	}
+	for _, check := range checks {
+		v.enabled[check] = true
+	}
+
+	v.walk(program.Statements)
+	v.count(c.References())
+
+	v.unused()
+	v.dropped()
+
+	if v.enabled[UNUSED_USING] {
+		for _, i := range c.Imports() {
+			if i.File == name && !i.Used {
+				v.warn(UNUSED_USING, position{i.Line, i.Offset}, fmt.Sprintf("nothing is used from %q", i.Name))
+			}
+		}
+	}
+
+	sort.SliceStable(v.warnings, func(i, j int) bool {
+		if v.warnings[i].Line != v.warnings[j].Line {
+			return v.warnings[i].Line < v.warnings[j].Line
+		}
+		return v.warnings[i].Offset < v.warnings[j].Offset
+	})
+	return v.warnings, nil
+}
+
+type vetter struct {
+	file     string // the checked one, declarations of domains it uses belong to other files and are not reported
+	enabled  map[string]bool
+	warnings []Warning
+
+	// collected from the tree
+	calls      []tokens.Token    // names of functions called as statements
+	assigned   map[position]bool // names on the left side of assignments, they are not reads
+	parameters map[position]bool
+	aliases    map[position][]position   // values of each alias
+	constants  map[position]bool         // values of aliases, they are used through their alias
+	frames     []map[string]tokens.Token // names declared in enclosing blocks
+
+	// collected from the compiler
+	declarations []compiler.Reference
+	references   map[position]compiler.Reference
+	reads        map[position]int // number of uses of each declaration
+}
+
+func (v *vetter) warn(check string, at position, message string, related ...helper.Related) {
+	code := ""
+	for _, c := range CHECKS {
+		if c.Name == check {
+			code = c.Code
+		}
+	}
+
+	err := helper.Error{File: v.file, Line: at.line, Offset: at.offset, Description: message, Severity: helper.WARNING, Code: code, Related: related}
+	v.warnings = append(v.warnings, Warning{Error: err, Check: check})
+}
+
+// count finds declarations of the program, they refer to themselves, and counts uses of them
+func (v *vetter) count(references []compiler.Reference) {
+	v.references = make(map[position]compiler.Reference)
+	v.reads = make(map[position]int)
+
+	for _, reference := range references {
+		if reference.File != v.file {
+			continue
+		}
+
+		here := position{reference.Line, reference.Offset}
+		declaration := position{reference.Definition.Line, reference.Definition.Offset}
+		v.references[here] = reference
+
+		switch {
+		case declaration.line == 0 || reference.Definition.File != v.file: // builtin or declared by another file
+		case here == declaration:
+			v.declarations = append(v.declarations, reference)
+		case !v.assigned[here]:
+			v.reads[declaration]++
+		}
+	}
+}
+
+func (v *vetter) unused() {
+	for _, declaration := range v.declarations {
+		at := position{declaration.Line, declaration.Offset}
+		definition := declaration.Definition
+
+		switch definition.Kind {
+		case compiler.VARIABLE_DEFINITION:
+			if !v.enabled[UNUSED_VARIABLE] || v.constants[at] || v.reads[at] != 0 {
+				continue
+			}
+			if v.parameters[at] {
+				v.warn(UNUSED_VARIABLE, at, fmt.Sprintf("parameter %q is never used", definition.Name))
+			} else {
+				v.warn(UNUSED_VARIABLE, at, fmt.Sprintf("variable %q is never used", definition.Name))
+			}
+		case compiler.FUNCTION_DEFINITION:
+			if v.enabled[UNUSED_FUNCTION] && v.reads[at] == 0 {
+				v.warn(UNUSED_FUNCTION, at, fmt.Sprintf("function %q is never used", definition.Name))
+			}
+		case compiler.ALIAS_DEFINITION:
+			used := v.reads[at]
+			for _, value := range v.aliases[at] {
+				used += v.reads[value]
+			}
+			if v.enabled[UNUSED_FUNCTION] && used == 0 {
+				v.warn(UNUSED_FUNCTION, at, fmt.Sprintf("alias %q is never used", definition.Name))
+			}
+		}
+	}
+}
+
+// dropped reports calls of functions returning a value, bot::WriteMemory returns its argument, so it may be dropped
+func (v *vetter) dropped() {
+	if !v.enabled[UNUSED_RESULT] {
+		return
+	}
+
+	for _, call := range v.calls {
+		reference, ok := v.references[at(call)]
+		if !ok {
+			continue
+		}
+
+		definition := reference.Definition
+		if definition.Type != compiler.VOID.String() && definition.Name != "bot::WriteMemory" {
+			v.warn(UNUSED_RESULT, at(call), fmt.Sprintf("result of %q is not used", definition.Name))
+		}
+	}
+}
+
+func (v *vetter) walk(statements []ast.Statement) {
+	terminated := false // by Return, Break or Continue
+	reported := false   // only the first unreachable statement of a block is reported
+
+	for _, statement := range statements {
+		if terminated && !reported && v.enabled[UNREACHABLE] {
+			v.warn(UNREACHABLE, start(statement), "unreachable code")
+			reported = true
+		}
+
+		switch s := statement.(type) {
+		case *ast.DeclarationStatement:
+			v.declare("variable", s.Var.Token)
+		case *ast.AssignmentStatement:
+			v.assigned[at(s.Name.Token)] = true
+		case *ast.ExpressionStatement:
+			if call, ok := s.Expression.(*ast.CallExpression); ok {
+				v.calls = append(v.calls, name(call))
+			}
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			terminated = true
+		case *ast.ScopeStatement:
+			v.block(s.Body)
+		case *ast.WhileStatement:
+			v.block(s.Body)
+		case *ast.IfStatement:
+			v.block(s.Consequence)
+			for _, elif := range s.Elifs {
+				v.block(elif.Consequence)
+			}
+			v.block(s.Alternative)
+		case *ast.AliasStatement:
+			for _, value := range s.Values {
+				v.constants[at(value.Var.Token)] = true
+				v.aliases[at(s.Var.Token)] = append(v.aliases[at(s.Var.Token)], at(value.Var.Token))
+			}
+		case *ast.FunctionStatement:
+			v.declare("function", s.Var.Token)
+
+			// parameters and the body share the scope
+			v.frames = append(v.frames, make(map[string]tokens.Token))
+			for _, parameter := range s.Parameters {
+				v.parameters[at(parameter.Token)] = true
+				v.declare("variable", parameter.Token)
+			}
+			if s.Body != nil {
+				v.walk(s.Body.Statements)
+			}
+			v.frames = v.frames[:len(v.frames)-1]
+		}
+	}
+}
+
+func (v *vetter) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+
+	v.frames = append(v.frames, make(map[string]tokens.Token))
+	v.walk(block.Statements)
+	v.frames = v.frames[:len(v.frames)-1]
+}
+
+// declare remembers the name in the innermost block and reports if it hides a name of the same kind
+func (v *vetter) declare(kind string, token tokens.Token) {
+	key := kind + " " + token.Literal
+
+	if v.enabled[SHADOW] {
+		for i := len(v.frames) - 2; i >= 0; i-- {
+			if outer, ok := v.frames[i][key]; ok {
+				v.warn(SHADOW, at(token), fmt.Sprintf("%s %q shadows declaration at line %d", kind, token.Literal, outer.Line),
+					helper.Related{Line: outer.Line, Offset: outer.Offset, Description: "shadowed declaration"})
+				break
+			}
+		}
+	}
+	v.frames[len(v.frames)-1][key] = token
+}
+
+// name returns token of the called function, the last name of a scope expression
+func name(call *ast.CallExpression) tokens.Token {
+	switch f := call.Function.(type) {
+	case *ast.ScopeExpression:
+		return f.Value.Token
+	case *ast.Identifier:
+		return f.Token
+	default:
+		return call.Token
+	}
+}
+
+func start(statement ast.Statement) position {
+	switch s := statement.(type) {
+	case *ast.DeclarationStatement:
+		return at(s.Var.Token)
+	case *ast.AssignmentStatement:
+		return at(s.Name.Token)
+	case *ast.ExpressionStatement:
+		return at(s.Token)
+	case *ast.ReturnStatement:
+		return at(s.Token)
+	case *ast.UsingStatement:
+		return at(s.Token)
+	case *ast.DomainStatement:
+		return at(s.Token)
+	case *ast.BreakStatement:
+		return at(s.Token)
+	case *ast.ContinueStatement:
+		return at(s.Token)
+	case *ast.ScopeStatement:
+		return at(s.Token)
+	case *ast.WhileStatement:
+		return at(s.Token)
+	case *ast.AliasStatement:
+		return at(s.Token)
+	case *ast.FunctionStatement:
+		return at(s.Token)
+	case *ast.IfStatement:
+		return at(s.Token)
+	default:
+		return position{}
+	}
+}
@@ -0,0 +1,144 @@
+package vet_test
+
+import (
+	"NiLang/src/diagnostic"
+	"NiLang/src/helper"
+	"NiLang/src/vet"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func all() []string {
+	checks := make([]string, 0, len(vet.CHECKS))
+	for _, check := range vet.CHECKS {
+		checks = append(checks, check.Name)
+	}
+	return checks
+}
+
+func found(t *testing.T, input string, checks []string) []string {
+	t.Helper()
+
+	warnings, errs := vet.Vet("bot.nil", []byte(input), nil, checks)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	result := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		result = append(result, fmt.Sprintf("%d:%s", warning.Line, warning.Check))
+	}
+	return result
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			"unused variables and parameters",
+			"Int used = 1\nInt unused = used\nunused = 2\nFun F::Int$ a Int, b Int:\n    Return a\nused = F$ used, used\n",
+			[]string{"2:unusedvar", "4:unusedvar"},
+		},
+		{
+			"unused functions and aliases",
+			"Fun Used:\n    bot::Sleep\nFun Unused:\n    Used\nAlias State::Int:\n    ill = 1\nAlias Mood::Int:\n    happy = 1\nBool b = mood::happy == mood::happy\nIf b:\n    Used\n",
+			[]string{"3:unusedfunc", "5:unusedfunc"},
+		},
+		{
+			"unreachable code",
+			"While True:\n    Break\n    bot::Sleep\n    bot::Sleep\nFun F::Int:\n    Return 1\n    Return 2\nInt x = F\nIf x == 1:\n    bot::Sleep\n",
+			[]string{"3:unreachable", "7:unreachable"},
+		},
+		{
+			"shadowing",
+			"Int x = 1\nFun F$ x Int:\n    bot::WriteMemory$ x\nIf x == 1:\n    Int x = 2\n    F$ x\nScope s:\n    Int y = 1\nInt y = s::y\nF$ y\n",
+			[]string{"2:shadow", "5:shadow"},
+		},
+		{
+			"dropped results",
+			"Fun F::Int:\n    Return 1\nF\nbot::GetEnergy\nbot::WriteMemory$ 1\nbot::Sleep\nInt x = F\nIf x == 1:\n    bot::Sleep\n",
+			[]string{"3:unusedresult", "4:unusedresult"},
+		},
+		{
+			"unused imports",
+			"Scope food:\n    Int calories = 10\nScope water:\n    Int volume = 1\nUsing food\nUsing water\nInt x = calories\nIf x == water::volume:\n    bot::Sleep\n",
+			[]string{"6:unusedusing"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings := found(t, test.input, all())
+			if !slices.Equal(warnings, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, warnings)
+			}
+		})
+	}
+}
+
+func TestDisabledChecks(t *testing.T) {
+	input := "Int x = 1\nFun F::Int:\n    Int x = 2\n    Return x\n    Return 3\nF\n"
+
+	if warnings := found(t, input, all()); len(warnings) != 4 {
+		t.Fatalf("expected 4 warnings, got %v", warnings)
+	}
+
+	for _, disabled := range []string{vet.UNUSED_VARIABLE, vet.SHADOW, vet.UNREACHABLE, vet.UNUSED_RESULT} {
+		checks := slices.DeleteFunc(all(), func(check string) bool { return check == disabled })
+		for _, warning := range found(t, input, checks) {
+			if strings.HasSuffix(warning, ":"+disabled) {
+				t.Errorf("check %q has not been disabled", disabled)
+			}
+		}
+	}
+}
+
+func TestWarningCodes(t *testing.T) {
+	input := "Int x = 1\nIf x == 1:\n    Int x = 2\n    bot::WriteMemory$ x\n"
+
+	warnings, _ := vet.Vet("bot.nil", []byte(input), nil, all())
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", warnings)
+	}
+
+	warning := warnings[0]
+	if warning.Level() != helper.WARNING || warning.Code != diagnostic.SHADOW {
+		t.Errorf("expected warning %s, got %s %s", diagnostic.SHADOW, warning.Level(), warning.Code)
+	}
+	if len(warning.Related) != 1 || warning.Related[0].Line != 1 || warning.Related[0].Offset != 4 {
+		t.Errorf("expected related location at 1:4, got %+v", warning.Related)
+	}
+
+	for _, check := range vet.CHECKS {
+		if _, ok := diagnostic.Lookup(check.Code); !ok {
+			t.Errorf("check %s has no explained code", check.Name)
+		}
+	}
+}
+
+func TestDomainFiles(t *testing.T) {
+	root := t.TempDir()
+	helpers := "Domain helpers\nInt unused = 1\nFun Double::Int$ x Int:\n    Return x * 2\n"
+	if err := os.WriteFile(filepath.Join(root, "helpers.nil"), []byte(helpers), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(root, "bot.nil")
+	input := "Using helpers\nInt x = Double$ 2\nInt y = 1\nbot::WriteMemory$ x\n"
+	warnings, errs := vet.Vet(name, []byte(input), []string{root}, all())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	// the unused variable of the domain belongs to another file
+	if len(warnings) != 1 || warnings[0].Line != 3 || warnings[0].Check != vet.UNUSED_VARIABLE || warnings[0].File != name {
+		t.Errorf("expected unused variable at %s:3, got %v", name, warnings)
+	}
+}
@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"NiLang/src/botlang"
+	"fmt"
+)
+
+// Program is a sequence of botlang commands with labels resolved to their indexes
+type Program struct {
+	Instructions []botlang.Instruction
+	Labels       map[string]int
+
+	targets []int // index of the instruction the label operand points to, -1 if there is none
+}
+
+func Parse(code []byte) (*Program, error) {
+	instructions, err := botlang.Parse(code)
+	if err != nil {
+		return nil, err
+	}
+	return Load(instructions)
+}
+
+// Load drops labels from the instructions and resolves label operands
+func Load(instructions []botlang.Instruction) (*Program, error) {
+	program := &Program{
+		Instructions: make([]botlang.Instruction, 0, len(instructions)),
+		Labels:       make(map[string]int),
+	}
+
+	for _, instruction := range instructions {
+		if instruction.IsLabel() {
+			if _, ok := program.Labels[instruction.Label]; ok {
+				return nil, fmt.Errorf("redeclaration of label %q", instruction.Label)
+			}
+			program.Labels[instruction.Label] = len(program.Instructions)
+			continue
+		}
+		program.Instructions = append(program.Instructions, instruction)
+	}
+
+	program.targets = make([]int, len(program.Instructions))
+	for i, instruction := range program.Instructions {
+		program.targets[i] = -1
+
+		label, ok := instruction.Target()
+		if !ok {
+			continue
+		}
+
+		index, ok := program.Labels[label]
+		if !ok {
+			return nil, fmt.Errorf("undeclared label %q", label)
+		}
+		program.targets[i] = index
+	}
+
+	return program, nil
+}
@@ -0,0 +1,346 @@
+package vm
+
+import (
+	"NiLang/src/botlang"
+	"errors"
+	"fmt"
+)
+
+const DefaultMemorySize = 1024
+
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// Cell is what a bot learns about a neighbouring cell with chk command
+type Cell struct {
+	Empty   bool
+	Friend  bool // occupied by a bot of the same colony
+	Sibling bool // occupied by a bot of the same specie
+
+	Luminosity     int // difference with the cell of the bot
+	Mineralization int // difference with the cell of the bot
+}
+
+// World carries out commands, which affect anything beyond the bot's registers and memory
+type World interface {
+	Move(direction botlang.Direction)
+	Face(direction botlang.Direction)
+	Check(direction botlang.Direction) Cell
+	Bite(direction botlang.Direction)
+	Split(direction botlang.Direction, entry int) // entry is an index of the instruction a new bot starts from
+	Fork(direction botlang.Direction, entry int)
+	ConsumeSunlight()
+	AbsorbMinerals()
+	Sleep()
+
+	Energy() int
+	Age() int
+}
+
+func registerIndex(name string) (int, bool) {
+	for i, register := range botlang.Registers {
+		if register == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+type VM struct {
+	program *Program
+	world   World
+
+	registers [len(botlang.Registers)]int
+	memory    []int
+	calls     []int
+
+	pc         int
+	comparison int // sign of a-b from the last cmp/cmpv
+	cell       Cell
+
+	steps int
+}
+
+func New(program *Program, world World, memorySize int) *VM {
+	return &VM{
+		program: program,
+		world:   world,
+		memory:  make([]int, memorySize),
+		calls:   make([]int, 0),
+		pc:      0,
+	}
+}
+
+func (m *VM) Halted() bool {
+	return m.pc >= len(m.program.Instructions)
+}
+
+func (m *VM) PC() int {
+	return m.pc
+}
+
+// SetPC moves execution to the given instruction and drops the call stack
+func (m *VM) SetPC(pc int) {
+	m.pc = pc
+	m.calls = m.calls[:0]
+}
+
+// Call enters the routine at the given instruction the way CALL does, it returns to the current position
+func (m *VM) Call(pc int) {
+	m.calls = append(m.calls, m.pc)
+	m.pc = pc
+}
+
+// Reload replaces the program keeping registers, memory and position, the new program is expected
+// to begin with the old one, e.g. when code is compiled piece by piece
+func (m *VM) Reload(program *Program) {
+	m.program = program
+}
+
+// Depth returns the number of calls waiting for return
+func (m *VM) Depth() int {
+	return len(m.calls)
+}
+
+func (m *VM) Steps() int {
+	return m.steps
+}
+
+func (m *VM) Program() *Program {
+	return m.program
+}
+
+func (m *VM) Register(name string) int {
+	i, ok := registerIndex(name)
+	if !ok {
+		return 0
+	}
+	return m.read(i)
+}
+
+func (m *VM) SetRegister(name string, value int) bool {
+	i, ok := registerIndex(name)
+	if !ok {
+		return false
+	}
+	m.registers[i] = value
+	return true
+}
+
+func (m *VM) Memory(addr int) (int, bool) {
+	if addr < 0 || addr >= len(m.memory) {
+		return 0, false
+	}
+	return m.memory[addr], true
+}
+
+// Run executes instructions until the program ends or limit of steps is reached
+func (m *VM) Run(limit int) error {
+	for !m.Halted() {
+		if m.steps >= limit {
+			return ErrStepLimit
+		}
+		if _, err := m.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cycle executes instructions until the bot performs an action in the world,
+// the program ends or limit of steps per cycle is reached
+func (m *VM) Cycle(limit int) error {
+	for i := 0; i < limit && !m.Halted(); i++ {
+		instruction, err := m.Step()
+		if err != nil {
+			return err
+		}
+		if botlang.IsAction(instruction.Opcode) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Step executes exactly one instruction and returns it
+func (m *VM) Step() (botlang.Instruction, error) {
+	if m.Halted() {
+		return botlang.Instruction{}, fmt.Errorf("program has ended")
+	}
+
+	instruction := m.program.Instructions[m.pc]
+	m.pc++
+	m.steps++
+
+	if err := m.execute(instruction); err != nil {
+		return instruction, fmt.Errorf("instruction %d %q: %s", m.pc-1, instruction.String(), err)
+	}
+	return instruction, nil
+}
+
+func (m *VM) execute(instruction botlang.Instruction) error {
+	args := instruction.Operands
+	target := m.program.targets[m.pc-1]
+
+	reg := func(i int) int {
+		index, _ := registerIndex(args[i].Register)
+		return index
+	}
+
+	jumpIf := func(condition bool) error {
+		if condition {
+			m.pc = target
+		}
+		return nil
+	}
+
+	switch instruction.Opcode {
+	case botlang.COMPARE:
+		m.comparison = sign(m.read(reg(0)) - m.read(reg(1)))
+	case botlang.COMPARE_WITH_VALUE:
+		m.comparison = sign(m.read(reg(0)) - args[1].Value)
+
+	case botlang.JUMP:
+		return jumpIf(true)
+	case botlang.JUMP_IF_EQUAL:
+		return jumpIf(m.comparison == 0)
+	case botlang.JUMP_IF_NOT_EQUAL:
+		return jumpIf(m.comparison != 0)
+	case botlang.JUMP_IF_LESS_THAN:
+		return jumpIf(m.comparison < 0)
+	case botlang.JUMP_IF_GREATER_THAN:
+		return jumpIf(m.comparison > 0)
+	case botlang.JUMP_IF_LESS_EQUAL_THAN:
+		return jumpIf(m.comparison <= 0)
+	case botlang.JUMP_IF_GREATER_EQUAL_THAN:
+		return jumpIf(m.comparison >= 0)
+	case botlang.JUMP_IF_EMPTY:
+		return jumpIf(m.cell.Empty)
+	case botlang.JUMP_IF_FRIEND:
+		return jumpIf(m.cell.Friend)
+	case botlang.JUMP_IF_SIBLING:
+		return jumpIf(m.cell.Sibling)
+
+	case botlang.LOAD_TO_REG_FROM_REG:
+		return m.write(reg(0), m.read(reg(1)))
+	case botlang.LOAD_TO_REG_FROM_VAL:
+		return m.write(reg(0), args[1].Value)
+	case botlang.LOAD_TO_MEM_FROM_REG:
+		if !m.isValidAddress(args[0].Value) {
+			return fmt.Errorf("address %d is out of memory of size %d", args[0].Value, len(m.memory))
+		}
+		m.memory[args[0].Value] = m.read(reg(1))
+	case botlang.LOAD_TO_REG_FROM_MEM:
+		if !m.isValidAddress(args[1].Value) {
+			return fmt.Errorf("address %d is out of memory of size %d", args[1].Value, len(m.memory))
+		}
+		return m.write(reg(0), m.memory[args[1].Value])
+
+	case botlang.CALL:
+		m.calls = append(m.calls, m.pc)
+		m.pc = target
+	case botlang.RETURN:
+		if len(m.calls) == 0 {
+			return fmt.Errorf("return without call")
+		}
+		m.pc = m.calls[len(m.calls)-1]
+		m.calls = m.calls[:len(m.calls)-1]
+
+	case botlang.MOVE:
+		m.world.Move(args[0].Direction)
+	case botlang.FACE:
+		m.world.Face(args[0].Direction)
+	case botlang.FORK:
+		m.world.Fork(args[0].Direction, target)
+	case botlang.SPLIT:
+		m.world.Split(args[0].Direction, target)
+	case botlang.BITE:
+		m.world.Bite(args[0].Direction)
+	case botlang.CONSUME_SUNLIGHT:
+		m.world.ConsumeSunlight()
+	case botlang.ABSORB_MINERALS:
+		m.world.AbsorbMinerals()
+	case botlang.CHECK:
+		m.cell = m.world.Check(args[0].Direction)
+		m.registers[index(botlang.SD)] = m.cell.Luminosity
+		m.registers[index(botlang.MD)] = m.cell.Mineralization
+	case botlang.SKIP_CYCLE:
+		m.world.Sleep()
+
+	case botlang.NEGATE:
+		return m.write(reg(0), -m.read(reg(0)))
+	case botlang.ADD, botlang.SUBTRACT, botlang.DIVIDE, botlang.MULTIPLY, botlang.MOD, botlang.POWER:
+		value, err := arithmetic(instruction.Opcode, m.read(reg(0)), m.read(reg(1)))
+		if err != nil {
+			return err
+		}
+		return m.write(reg(0), value)
+	default:
+		return fmt.Errorf("command is not supported")
+	}
+	return nil
+}
+
+func (m *VM) read(register int) int {
+	switch botlang.Registers[register] {
+	case botlang.EN:
+		return m.world.Energy()
+	case botlang.AG:
+		return m.world.Age()
+	default:
+		return m.registers[register]
+	}
+}
+
+func (m *VM) write(register int, value int) error {
+	switch botlang.Registers[register] {
+	case botlang.EN, botlang.AG:
+		return fmt.Errorf("register %s is read-only", botlang.Registers[register])
+	default:
+		m.registers[register] = value
+		return nil
+	}
+}
+
+func (m *VM) isValidAddress(addr int) bool {
+	return addr >= 0 && addr < len(m.memory)
+}
+
+func arithmetic(op botlang.Opcode, a int, b int) (int, error) {
+	switch op {
+	case botlang.ADD:
+		return a + b, nil
+	case botlang.SUBTRACT:
+		return a - b, nil
+	case botlang.MULTIPLY:
+		return a * b, nil
+	case botlang.DIVIDE:
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	case botlang.MOD:
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a % b, nil
+	case botlang.POWER:
+		return botlang.Power(a, b)
+	}
+	return 0, fmt.Errorf("unknown arithmetic opcode %q", op)
+}
+
+func index(name string) int {
+	i, _ := registerIndex(name)
+	return i
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	default:
+		return 0
+	}
+}
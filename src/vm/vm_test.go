@@ -0,0 +1,695 @@
+package vm_test
+
+import (
+	"NiLang/src/botlang"
+	"NiLang/src/compiler"
+	"NiLang/src/helper"
+	"NiLang/src/vm"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const stackSize = 128
+const stepLimit = 100000
+
+type recordingWorld struct {
+	actions []string
+	cell    vm.Cell
+}
+
+func (w *recordingWorld) record(action string, direction botlang.Direction) {
+	w.actions = append(w.actions, action+" "+direction.String())
+}
+
+func (w *recordingWorld) Move(direction botlang.Direction)             { w.record("mov", direction) }
+func (w *recordingWorld) Face(direction botlang.Direction)             { w.record("rot", direction) }
+func (w *recordingWorld) Bite(direction botlang.Direction)             { w.record("bite", direction) }
+func (w *recordingWorld) Split(direction botlang.Direction, entry int) { w.record("split", direction) }
+func (w *recordingWorld) Fork(direction botlang.Direction, entry int)  { w.record("fork", direction) }
+func (w *recordingWorld) Check(direction botlang.Direction) vm.Cell    { return w.cell }
+func (w *recordingWorld) ConsumeSunlight()                             { w.actions = append(w.actions, "eatsun") }
+func (w *recordingWorld) AbsorbMinerals()                              { w.actions = append(w.actions, "absorb") }
+func (w *recordingWorld) Sleep()                                       { w.actions = append(w.actions, "nop") }
+func (w *recordingWorld) Energy() int                                  { return 500 }
+func (w *recordingWorld) Age() int                                     { return len(w.actions) }
+
+func runSource(test *testing.T, input string, world *recordingWorld) *vm.VM {
+	return runOptimized(test, input, world, compiler.NO_OPTIMIZATION)
+}
+
+func runOptimized(test *testing.T, input string, world *recordingWorld, level int) *vm.VM {
+	c := compiler.New(stackSize)
+	c.Optimize(level)
+	code, errors := c.Compile([]byte(input))
+	if len(errors) != 0 {
+		for _, err := range errors {
+			helper.PrintError(err, []byte(input))
+		}
+		test.Fatalf("Failed to compile code")
+	}
+
+	program, err := vm.Parse(code)
+	if err != nil {
+		test.Fatalf("vm.Parse() has failed: %s", err)
+	}
+
+	machine := vm.New(program, world, vm.DefaultMemorySize)
+	if err := machine.Run(stepLimit); err != nil {
+		test.Fatalf("machine.Run() has failed: %s", err)
+	}
+	return machine
+}
+
+func TestArithmetics(test *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"bot::WriteMemory$ 2 + 3 * 4", 14},
+		{"bot::WriteMemory$ 10 / 3", 3},
+		{"bot::WriteMemory$ 10 % 3", 1},
+		{"bot::WriteMemory$ 5 ** 3", 125},
+		{"bot::WriteMemory$ - 5 - 2", -7},
+		{"Int x = 60\nx = x * 60\nbot::WriteMemory$ x", 3600},
+		{"bot::WriteMemory$ bot::GetEnergy", 500},
+	}
+
+	for i, t := range tests {
+		machine := runSource(test, t.input, &recordingWorld{})
+		if value := machine.Register(botlang.DX); value != t.expected {
+			test.Errorf("tests[%d] - expected=%d, got=%d", i, t.expected, value)
+		}
+	}
+}
+
+func TestConditions(test *testing.T) {
+	input := `
+Fun Check::Int$ x Int, flag Bool:
+    If x < 10 And flag:
+        Return 1
+    Elif x >= 10 Or Not flag:
+        Return 2
+    Return 3
+
+Int a = Check$ 5, True
+Int b = Check$ 5, False
+Int c = Check$ 20, True
+bot::WriteMemory$ a * 100 + b * 10 + c`
+
+	machine := runSource(test, input, &recordingWorld{})
+	if value := machine.Register(botlang.DX); value != 122 {
+		test.Errorf("expected=%d, got=%d", 122, value)
+	}
+}
+
+func TestLoopAndActions(test *testing.T) {
+	input := `
+Int x = 0
+While x < 3:
+    x = x + 1
+    If bot::IsEmpty$ dir::front:
+        bot::Move$ dir::front
+    Else:
+        bot::Bite$ dir::left
+bot::Face$ dir::backRight
+bot::ConsumeSunlight`
+
+	world := &recordingWorld{cell: vm.Cell{Empty: true}}
+	runSource(test, input, world)
+
+	expected := []string{"mov front", "mov front", "mov front", "rot backright", "eatsun"}
+	if len(world.actions) != len(expected) {
+		test.Fatalf("expected actions %v, got %v", expected, world.actions)
+	}
+	for i := range expected {
+		if world.actions[i] != expected[i] {
+			test.Fatalf("expected actions %v, got %v", expected, world.actions)
+		}
+	}
+
+	world = &recordingWorld{cell: vm.Cell{Empty: false}}
+	runSource(test, input, world)
+	if world.actions[0] != "bite left" {
+		test.Fatalf("expected first action %q, got %q", "bite left", world.actions[0])
+	}
+}
+
+func TestBreakAndContinue(test *testing.T) {
+	input := `
+Int sum = 0
+Int i = 0
+While i < 10:
+    i = i + 1
+    If i % 2 == 0:
+        Continue
+    If i > 7:
+        Break
+    sum = sum + i
+bot::WriteMemory$ sum * 100 + i`
+
+	machine := runSource(test, input, &recordingWorld{})
+	if value := machine.Register(botlang.DX); value != 1609 {
+		test.Errorf("expected=%d, got=%d", 1609, value)
+	}
+}
+
+func TestShortCircuit(test *testing.T) {
+	input := `
+Int calls = 0
+
+Fun Touch::Bool:
+    calls = calls + 1
+    Return True
+
+Bool a = False And Touch
+Bool b = True Or Touch
+Bool c = True And Touch
+Bool empty = bot::IsEmpty$ dir::front
+Bool d = empty And Touch
+Bool e = empty Or Touch
+If a Or Not b Or Not c Or d != empty Or Not e:
+    calls = -1
+bot::WriteMemory$ calls`
+
+	for _, tt := range []struct {
+		empty    bool
+		expected int
+	}{{true, 2}, {false, 2}} {
+		machine := runSource(test, input, &recordingWorld{cell: vm.Cell{Empty: tt.empty}})
+		if value := machine.Register(botlang.DX); value != tt.expected {
+			test.Errorf("empty=%t - expected %d calls, got %d", tt.empty, tt.expected, value)
+		}
+	}
+}
+
+func TestConditionBranches(test *testing.T) {
+	conditions := []string{
+		"x < 3",
+		"x >= 3",
+		"Not empty",
+		"x > 1 And x < 4",
+		"x < 1 Or x > 4 Or x == 3",
+		"Not empty And Not bot::IsSibling$ dir::back",
+		"empty And x != 2",
+		"bot::IsEmpty$ dir::front",
+		"Not bot::IsFriend$ dir::left",
+		"x == 0 Or bot::IsSibling$ dir::back",
+		"Not empty Or x % 2 == 0 And x > 2",
+		"True And x > 2",
+		"False Or x < 2",
+	}
+	cells := []vm.Cell{{Empty: true}, {Friend: true, Sibling: true}}
+
+	for _, condition := range conditions {
+		for x := range 6 {
+			for _, cell := range cells {
+				// the value of the condition is compiled as a value of Bool, the branches jump by the condition
+				input := fmt.Sprintf(`
+Int x = %d
+Bool empty = bot::IsEmpty$ dir::front
+Bool value = %[2]s
+Int branch = 0
+If %[2]s:
+    branch = 1
+Int loops = 0
+While %[2]s:
+    loops = loops + 1
+    If loops == 2:
+        Break
+Int elif = 0
+If False:
+    elif = -1
+Elif %[2]s:
+    elif = 1
+If value:
+    bot::WriteMemory$ branch * 100 + loops * 10 + elif
+Else:
+    bot::WriteMemory$ branch * 100 + loops * 10 + elif + 1000`, x, condition)
+
+				machine := runSource(test, input, &recordingWorld{cell: cell})
+				if value := machine.Register(botlang.DX); value != 121 && value != 1000 {
+					test.Errorf("%q, x=%d, cell=%+v - branches disagree with the value: %d", condition, x, cell, value)
+				}
+			}
+		}
+	}
+}
+
+func TestDirections(test *testing.T) {
+	input := `
+Fun Act$ d Dir:
+    bot::Move$ d
+    bot::Face$ d
+    bot::Bite$ d
+
+Dir d = dir::left
+Act$ d
+Act$ dir::back
+bot::Move$ dir::frontRight
+bot::Split$ d
+bot::Fork$ dir::right
+If bot::IsEmpty$ d:
+    bot::Sleep
+d = dir::backLeft
+Bool friend = bot::IsFriend$ d
+If friend Or Not bot::IsEmpty$ d:
+    bot::Sleep
+Else:
+    bot::Face$ d`
+
+	world := &recordingWorld{cell: vm.Cell{Empty: true}}
+	runSource(test, input, world)
+
+	expected := []string{"mov left", "rot left", "bite left", "mov back", "rot back", "bite back", "mov frontright", "split left", "fork right", "nop", "rot backleft"}
+	if !reflect.DeepEqual(world.actions, expected) {
+		test.Errorf("expected actions %v, got %v", expected, world.actions)
+	}
+}
+
+func TestSensors(test *testing.T) {
+	input := `
+Int luminosity = bot::GetLuminosity$ dir::front
+Int mineralization = bot::GetMineralization$ dir::back
+bot::WriteMemory$ luminosity - mineralization`
+
+	machine := runSource(test, input, &recordingWorld{cell: vm.Cell{Luminosity: 7, Mineralization: 3}})
+	if value := machine.Register(botlang.DX); value != 4 {
+		test.Errorf("expected=%d, got=%d", 4, value)
+	}
+}
+
+func TestCycle(test *testing.T) {
+	program, err := vm.Parse([]byte("BEGIN:\nldv AX 1\nmov front\nldv AX 2\nsplit back BEGIN\n"))
+	if err != nil {
+		test.Fatalf("vm.Parse() has failed: %s", err)
+	}
+
+	world := &recordingWorld{}
+	machine := vm.New(program, world, vm.DefaultMemorySize)
+
+	if err := machine.Cycle(10); err != nil {
+		test.Fatalf("machine.Cycle() has failed: %s", err)
+	}
+	if machine.PC() != 2 || machine.Register(botlang.AX) != 1 {
+		test.Fatalf("expected to stop after the first action, got pc=%d, AX=%d", machine.PC(), machine.Register(botlang.AX))
+	}
+
+	if err := machine.Cycle(10); err != nil {
+		test.Fatalf("machine.Cycle() has failed: %s", err)
+	}
+	if !machine.Halted() || len(world.actions) != 2 {
+		test.Fatalf("expected to execute the whole program, got pc=%d, actions=%v", machine.PC(), world.actions)
+	}
+}
+
+func TestRuntimeErrors(test *testing.T) {
+	tests := []string{
+		"ldv AX 1\nldv BX 0\ndiv AX BX",
+		"ret",
+		"ldv AX 1\nldr [5000] AX",
+		"ldv EN 1",
+	}
+
+	for i, t := range tests {
+		program, err := vm.Parse([]byte(t))
+		if err != nil {
+			test.Fatalf("tests[%d] - vm.Parse() has failed: %s", i, err)
+		}
+
+		machine := vm.New(program, &recordingWorld{}, vm.DefaultMemorySize)
+		if err := machine.Run(stepLimit); err == nil {
+			test.Errorf("tests[%d] - expected runtime error for %q", i, t)
+		}
+	}
+}
+
+func TestReload(test *testing.T) {
+	c := compiler.New(stackSize)
+	machine := vm.New(&vm.Program{}, &recordingWorld{}, vm.DefaultMemorySize)
+
+	inputs := []struct {
+		input    string
+		t        string
+		expected int
+	}{
+		{"Int x = 20\n", "void", 0},
+		{"Fun Twice::Int$ a Int:\n    Return a * 2\n", "void", 0},
+		{"x = Twice$ x\n", "void", 0},
+		{"x + 2\n", "Int", 42},
+		{"x > 100\n", "Bool", 0},
+	}
+
+	for _, tt := range inputs {
+		t, register, errors := c.Extend([]byte(tt.input))
+		if len(errors) != 0 {
+			test.Fatalf("%q: unexpected errors %v", tt.input, errors)
+		}
+
+		program, err := vm.Load(c.Instructions())
+		if err != nil {
+			test.Fatalf("vm.Load() has failed: %s", err)
+		}
+
+		machine.Reload(program)
+		if err := machine.Run(machine.Steps() + stepLimit); err != nil {
+			test.Fatalf("machine.Run() has failed: %s", err)
+		}
+
+		if t != tt.t {
+			test.Errorf("%q: expected type %q, got %q", tt.input, tt.t, t)
+		}
+		if register != "" && machine.Register(register) != tt.expected {
+			test.Errorf("%q: expected value %d, got %d", tt.input, tt.expected, machine.Register(register))
+		}
+	}
+
+	if _, _, errors := c.Extend([]byte("Int x = 1\n")); len(errors) == 0 {
+		test.Errorf("expected redeclaration of a variable from the previous input")
+	}
+}
+
+func TestCall(test *testing.T) {
+	c := compiler.New(stackSize)
+	code, errors := c.Compile([]byte("Int x = 1\nFun Triple:\n    x = x * 3\n"))
+	if len(errors) != 0 {
+		test.Fatalf("Failed to compile code")
+	}
+
+	program, err := vm.Parse(code)
+	if err != nil {
+		test.Fatalf("vm.Parse() has failed: %s", err)
+	}
+
+	label, ok := c.Label("Triple")
+	if !ok {
+		test.Fatalf("label of function Triple is not found")
+	}
+
+	machine := vm.New(program, &recordingWorld{}, vm.DefaultMemorySize)
+	if err := machine.Run(stepLimit); err != nil {
+		test.Fatalf("machine.Run() has failed: %s", err)
+	}
+
+	for range 2 {
+		machine.Call(program.Labels[label])
+		if err := machine.Run(stepLimit); err != nil {
+			test.Fatalf("machine.Run() has failed after call: %s", err)
+		}
+	}
+
+	for _, symbol := range c.Symbols() {
+		if symbol.Name == "x" {
+			if value, _ := machine.Memory(symbol.Addr); value != 9 {
+				test.Errorf("expected x=9, got %d", value)
+			}
+		}
+	}
+}
+
+func TestArguments(test *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`
+Fun Pair::Int$ a Int, b Int:
+    Return a * 10 + b
+
+bot::WriteMemory$ Pair$ 1, Pair$ 2, 3`, 33},
+		// G calls F while the first argument of F waits for G
+		{`
+Fun F::Int$ a Int, b Int:
+    Return a * 10 + b
+
+Fun G::Int$ n Int:
+    Return F$ n, n
+
+bot::WriteMemory$ F$ 1, G$ 2`, 32},
+	}
+
+	for i, tt := range tests {
+		for _, level := range []int{compiler.NO_OPTIMIZATION, compiler.PEEPHOLE_OPTIMIZATION, compiler.REGISTER_OPTIMIZATION} {
+			machine := runOptimized(test, tt.input, &recordingWorld{}, level)
+			if value := machine.Register(botlang.DX); value != tt.expected {
+				test.Errorf("tests[%d], level %d - expected %d, got %d", i, level, tt.expected, value)
+			}
+		}
+	}
+}
+
+func TestRecursion(test *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`
+Fun Fib::Int$ n Int:
+    If n < 2:
+        Return n
+    Int a = Fib$ n - 1
+    Return a + Fib$ n - 2
+
+Fun Ack::Int$ m Int, n Int:
+    If m == 0:
+        Return n + 1
+    If n == 0:
+        Return Ack$ m - 1, 1
+    Int inner = Ack$ m, n - 1
+    Return Ack$ m - 1, inner
+
+Int fib = Fib$ 10
+bot::WriteMemory$ fib * 100 + Ack$ 2, 2`, 5507},
+		// Half runs Bits again while Bits waits for it
+		{`
+Fun Bits::Int$ n Int:
+    Fun Half::Int$ m Int:
+        Return Bits$ m / 2
+    If n == 0:
+        Return 0
+    Int bits = Half$ n
+    Return bits + n % 2
+
+bot::WriteMemory$ Bits$ 23`, 4},
+		// values of expressions waiting for calls are kept by the caller
+		{`
+Fun Square::Int$ x Int:
+    Return x * x + 0 * x
+
+Fun Add::Int$ a Int, b Int:
+    Return a * 10 + Square$ b
+
+Int add = Add$ 2, 3
+bot::WriteMemory$ 1000 + Add$ 1, add`, 1851},
+		// the first argument of Ack waits on the stack for the recursive call of the second one
+		{`
+Fun Ack::Int$ m Int, n Int:
+    If m == 0:
+        Return n + 1
+    If n == 0:
+        Return Ack$ m - 1, 1
+    Return Ack$ m - 1, Ack$ m, n - 1
+
+bot::WriteMemory$ Ack$ 2, 2`, 7},
+		// the inner call of Add is an argument of the outer one
+		{`
+Fun Square::Int$ x Int:
+    Return x * x + 0 * x
+
+Fun Add::Int$ a Int, b Int:
+    Return a * 10 + Square$ b
+
+bot::WriteMemory$ 1000 + Add$ 1, Add$ 2, 3`, 1851},
+	}
+
+	for i, tt := range tests {
+		for _, level := range []int{compiler.NO_OPTIMIZATION, compiler.PEEPHOLE_OPTIMIZATION, compiler.REGISTER_OPTIMIZATION} {
+			machine := runOptimized(test, tt.input, &recordingWorld{}, level)
+			if value := machine.Register(botlang.DX); value != tt.expected {
+				test.Errorf("tests[%d], level %d - expected %d, got %d", i, level, tt.expected, value)
+			}
+		}
+	}
+
+	// the push of a frame stores the number of the function and stops the bot if every frame is in use
+	input := `
+Fun Up$ n Int:
+    If n > 0:
+        Up$ n - 1
+
+Fun Down$ n Int:
+    bot::Sleep
+    If n > 0:
+        Down$ n - 1
+
+Up$ 3
+Down$ %d
+bot::Move$ dir::front`
+
+	for _, depth := range []int{compiler.DEFAULT_FRAMES, compiler.DEFAULT_FRAMES + 1} {
+		world := &recordingWorld{}
+		machine := runSource(test, fmt.Sprintf(input, depth), world)
+
+		c := compiler.New(stackSize)
+		if _, errors := c.Compile([]byte(fmt.Sprintf(input, depth))); len(errors) != 0 {
+			test.Fatalf("unexpected errors %v", errors)
+		}
+		if functions := c.RecursiveFunctions(); !reflect.DeepEqual(functions, []string{"Up", "Down"}) {
+			test.Errorf("expected recursive functions Up and Down, got %v", functions)
+		}
+		number := 0
+		if depth > compiler.DEFAULT_FRAMES {
+			number = 2 // of Down
+		}
+		if overflow, _ := machine.Memory(c.FrameOverflowAddress()); overflow != number {
+			test.Errorf("depth %d - expected %d at the frame overflow address, got %d", depth, number, overflow)
+		}
+
+		expected := make([]string, 0)
+		for range compiler.DEFAULT_FRAMES + 1 {
+			expected = append(expected, "nop")
+		}
+		if depth == compiler.DEFAULT_FRAMES {
+			expected = append(expected, "mov front")
+		}
+		if !reflect.DeepEqual(world.actions, expected) {
+			test.Errorf("depth %d - expected actions %v, got %v", depth, expected, world.actions)
+		}
+	}
+}
+
+func TestMemoryReuse(test *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		// variables of blocks which have ended share addresses
+		{`
+Int total = 0
+Int i = 0
+While i < 3:
+    Int a = i * 10
+    If a > 5:
+        Int b = a + 1
+        total = total + b
+    i = i + 1
+If total > 0:
+    Int c = 100
+    total = total + c
+bot::WriteMemory$ total`, 132},
+		// Twice and Inc share their memory, Both calls them and keeps its own
+		{`
+Fun Twice::Int$ x Int:
+    Int t = x * 2
+    Return t
+
+Fun Inc::Int$ y Int:
+    Int u = y + 1
+    Return u
+
+Fun Both::Int$ z Int:
+    Int v = Twice$ z
+    Int w = Inc$ z
+    Return v * 100 + w
+
+Int p = Twice$ 3
+Int q = Inc$ 4
+Int r = Both$ 5
+Int s = Twice$ Inc$ 7
+bot::WriteMemory$ p * 1000000 + q * 100000 + r * 100 + s`, 6600616},
+	}
+
+	for i, tt := range tests {
+		for _, level := range []int{compiler.NO_OPTIMIZATION, compiler.PEEPHOLE_OPTIMIZATION, compiler.REGISTER_OPTIMIZATION} {
+			machine := runOptimized(test, tt.input, &recordingWorld{}, level)
+			if value := machine.Register(botlang.DX); value != tt.expected {
+				test.Errorf("tests[%d], level %d - expected %d, got %d", i, level, tt.expected, value)
+			}
+		}
+	}
+}
+
+func TestOptimization(test *testing.T) {
+	inputs := []string{`
+Int x = 7
+Int y = x * 3 - x + 2 * x - 1 / 4 + x % 5 * -x
+y = y - x ** 2
+bot::WriteMemory$ y`, `
+Fun Sum::Int$ a Int, b Int:
+    Int s = 0
+    While a <= b:
+        s = s + a
+        a = a + 1
+    Return s
+
+Fun Choose::Int$ x Int:
+    If x < 10 And x != 5:
+        Return 1
+    Elif x == 5 Or x > 100:
+        Return 2
+    Else:
+        Return 3
+
+bot::WriteMemory$ Sum$ 1, 10
+Int c = Choose$ 3
+Int d = Choose$ 5
+c = c * 10 + d
+d = Choose$ 50
+c = c * 10 + d`, `
+Int steps = 0
+While True:
+    steps = steps + 1
+    If steps > 4:
+        Break
+    If steps % 2 == 0:
+        Continue
+    If bot::IsEmpty$ dir::front:
+        bot::Move$ dir::front
+    Elif bot::IsFriend$ dir::front:
+        bot::Face$ dir::right
+    Else:
+        bot::Bite$ dir::front
+Bool done = Not bot::IsSibling$ dir::left
+If done:
+    bot::ConsumeSunlight
+bot::WriteMemory$ bot::GetEnergy - steps`,
+	}
+	cells := []vm.Cell{{Empty: true}, {Friend: true, Sibling: true}, {}}
+
+	for i, input := range inputs {
+		c := compiler.New(stackSize)
+		c.Compile([]byte(input))
+		memoryMap := c.MemoryMap()
+
+		for _, cell := range cells {
+			world := &recordingWorld{cell: cell}
+			expected := runOptimized(test, input, world, compiler.NO_OPTIMIZATION)
+
+			for _, level := range []int{compiler.PEEPHOLE_OPTIMIZATION, compiler.REGISTER_OPTIMIZATION} {
+				optimized := &recordingWorld{cell: cell}
+				machine := runOptimized(test, input, optimized, level)
+
+				if !reflect.DeepEqual(optimized.actions, world.actions) {
+					test.Errorf("inputs[%d], level %d - expected actions %v, got %v", i, level, world.actions, optimized.actions)
+				}
+				if value := machine.Register(botlang.DX); value != expected.Register(botlang.DX) {
+					test.Errorf("inputs[%d], level %d - expected memory of the bot %d, got %d", i, level, expected.Register(botlang.DX), value)
+				}
+				for _, allocation := range memoryMap {
+					if allocation.Name == compiler.STACK_ALLOCATION {
+						continue // values of expressions may stay in registers
+					}
+					for addr := allocation.Addr; addr < allocation.Addr+allocation.Size; addr++ {
+						want, _ := expected.Memory(addr)
+						if got, _ := machine.Memory(addr); got != want {
+							test.Errorf("inputs[%d], level %d - expected [%d]=%d, got %d", i, level, addr, want, got)
+						}
+					}
+				}
+				if machine.Steps() >= expected.Steps() {
+					test.Errorf("inputs[%d], level %d - expected fewer steps than %d, got %d", i, level, expected.Steps(), machine.Steps())
+				}
+			}
+		}
+	}
+}
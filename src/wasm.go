@@ -31,7 +31,7 @@ func compile(this js.Value, args []js.Value) any {
 	source := args[0].String()
 	input := []byte(source)
 
-	stackSize := common.DefaultStackSize
+	stackSize := compiler.AUTO_STACK_SIZE
 	if len(args) > 1 {
 		arg := args[1].String()
 		val, err := strconv.Atoi(arg)
@@ -42,7 +42,8 @@ func compile(this js.Value, args []js.Value) any {
 	}
 
 	c := compiler.New(stackSize)
-	code, errors := c.Compile(input, false)
+	c.Optimize(compiler.DEFAULT_OPTIMIZATION)
+	code, errors := c.Compile(input)
 	if len(errors) != 0 {
 		output := ""
 		for _, err := range errors {
@@ -2,3 +2,14 @@ go test ./src/lexer/lexer_test.go
 go test ./src/parser/parser_test.go
 go test ./src/ast/ast_test.go
 go test ./src/compiler/compiler_test.go
+go test ./src/botlang/botlang_test.go
+go test ./src/vm/vm_test.go
+go test ./src/sim/sim_test.go
+go test ./src/lsp/lsp_test.go
+go test ./src/format/format_test.go
+go test ./src/vet/vet_test.go
+go test ./src/test/test_test.go
+go test ./src/diagnostic/diagnostic_test.go
+go test ./src/nilang/nilang_test.go
+./exit_status.sh
+./build_domains.sh